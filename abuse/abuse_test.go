@@ -0,0 +1,287 @@
+package abuse
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cerberius.com/go-client/generated/models"
+)
+
+var (
+	t0       = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	reporter = Reporter{Name: "Example SOC", Email: "soc@example.com"}
+)
+
+func report(ip, abuseEmail string, lines ...string) *Report {
+	r := &Report{
+		IP:      &models.IPData{IPAddress: ip, AbuseEmail: abuseEmail, ASN: "AS64500", OrgName: "Example Hosting"},
+		Type:    LoginAttack,
+		Service: "ssh",
+		Port:    22,
+	}
+	for i, l := range lines {
+		r.Evidence = append(r.Evidence, Evidence{Time: t0.Add(time.Duration(i) * time.Minute), Line: l})
+	}
+	return r
+}
+
+// sink is a fake Sink that records messages.
+type sink struct {
+	msgs []*Message
+	err  error
+}
+
+func (s *sink) Send(ctx context.Context, msg *Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.msgs = append(s.msgs, msg)
+	return nil
+}
+
+func TestRecipient(t *testing.T) {
+	if to, err := report("192.0.2.1", " Abuse@Example.net ").Recipient(); err != nil || to != "abuse@example.net" {
+		t.Errorf("Unexpected recipient %q, %v", to, err)
+	}
+	r := &Report{IP: &models.IPData{OrgEmail: "noc@example.net"}}
+	if to, _ := r.Recipient(); to != "noc@example.net" {
+		t.Errorf("Expected the OrgEmail fallback, got %q", to)
+	}
+	if _, err := (&Report{}).Recipient(); !errors.Is(err, ErrNoContact) {
+		t.Errorf("Expected ErrNoContact, got %v", err)
+	}
+
+	// Whois data must not inject headers.
+	r = &Report{IP: &models.IPData{AbuseEmail: "abuse@example.net\r\nBcc: victim@example.com"}}
+	if _, err := r.Recipient(); !errors.Is(err, ErrInvalidContact) {
+		t.Errorf("Expected ErrInvalidContact, got %v", err)
+	}
+	r.IP.OrgEmail = "noc@example.net"
+	if to, err := r.Recipient(); err != nil || to != "noc@example.net" {
+		t.Errorf("Expected the valid OrgEmail, got %q, %v", to, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, r := range []*Report{
+		{IP: &models.IPData{IPAddress: "192.0.2.1\r\nX-Injected: 1"}},
+		{IP: &models.IPData{IPAddress: "192.0.2.1"}, Service: "ssh\nX-Injected: 1"},
+		{IP: &models.IPData{IPAddress: "192.0.2.1"}, Comment: "note\r\n"},
+		{IP: &models.IPData{IPAddress: "192.0.2.1"}, Type: "ddos\r\nSource: 198.51.100.1"},
+	} {
+		if err := r.Validate(); !errors.Is(err, ErrInvalidReport) {
+			t.Errorf("Expected ErrInvalidReport for %+v, got %v", r, err)
+		}
+		if _, err := Compose(FormatXARF, Reporter{Email: "soc@example.com"}, "abuse@example.net", []*Report{r}, time.Now()); !errors.Is(err, ErrInvalidReport) {
+			t.Errorf("Expected Compose to refuse %+v, got %v", r, err)
+		}
+	}
+	if err := report("192.0.2.1", "abuse@example.net").Validate(); err != nil {
+		t.Errorf("Unexpected error for a valid report: %v", err)
+	}
+}
+
+func TestComposeText(t *testing.T) {
+	reports := []*Report{
+		report("192.0.2.2", "abuse@example.net", "failed password for root"),
+		report("192.0.2.1", "abuse@example.net", "failed password for admin", "failed password for test"),
+	}
+	msgs, err := Compose(FormatText, reporter, "abuse@example.net", reports, t0)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("Expected one message, got %v, %v", msgs, err)
+	}
+	m, err := mail.ReadMessage(bytes.NewReader(msgs[0].Raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Header.Get("Subject"); got != "Abuse report: 2 incidents from your network" {
+		t.Errorf("Unexpected subject %q", got)
+	}
+	body, _ := io.ReadAll(m.Body)
+	text := string(body)
+	for _, want := range []string{
+		"IP address:   192.0.2.1",
+		"Network:      AS64500 Example Hosting",
+		"Target:       ssh port 22",
+		"First seen:   2024-03-01T12:00:00Z",
+		"Events:       2",
+		"2024-03-01T12:01:00Z failed password for test",
+		"Example SOC <soc@example.com>",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected the message to contain %q:\n%s", want, text)
+		}
+	}
+	if strings.Index(text, "192.0.2.1") > strings.Index(text, "192.0.2.2") {
+		t.Error("Expected the reports to be sorted")
+	}
+
+	again, _ := Compose(FormatText, reporter, "abuse@example.net", reports[:1], t0)
+	if again[0].ID == msgs[0].ID {
+		t.Error("Expected different reports to have different IDs")
+	}
+}
+
+// parts returns the media type of msg and its parts by content type.
+func parts(t *testing.T, msg *Message) (*mail.Message, string, []string, map[string]string) {
+	t.Helper()
+	m, err := mail.ReadMessage(bytes.NewReader(msg.Raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	contents := make(map[string]string)
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(p)
+		ct := p.Header.Get("Content-Type")
+		types = append(types, ct)
+		contents[ct] = string(b)
+	}
+	return m, mediaType, types, contents
+}
+
+func TestComposeXARF(t *testing.T) {
+	r := report("192.0.2.1", "abuse@example.net", "failed password for admin")
+	msgs, err := Compose(FormatXARF, reporter, "abuse@example.net", []*Report{r, report("2001:db8::1", "abuse@example.net")}, t0)
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("Expected a message per report, got %v, %v", msgs, err)
+	}
+	m, mediaType, types, contents := parts(t, msgs[0])
+	if m.Header.Get("X-XARF") != "PLAIN" || mediaType != "multipart/mixed" || len(types) != 3 {
+		t.Fatalf("Unexpected X-ARF structure %s %v", mediaType, types)
+	}
+	yaml := contents[`text/plain; charset=utf-8; name="report.txt"`]
+	for _, want := range []string{
+		"Reported-From: soc@example.com\r\n",
+		"Report-Type: login-attack\r\n",
+		"Port: 22\r\n",
+		"Source-Type: ipv4\r\n",
+		"Source: 192.0.2.1\r\n",
+		"Schema-URL: http://www.x-arf.org/schema/abuse_login-attack_0.1.2.json\r\n",
+	} {
+		if !strings.Contains(yaml, want) {
+			t.Errorf("Expected the report to contain %q:\n%s", want, yaml)
+		}
+	}
+	if log := contents[`text/plain; charset=utf-8; name="logfile.log"`]; log != "2024-03-01T12:00:00Z failed password for admin\r\n" {
+		t.Errorf("Unexpected log file %q", log)
+	}
+	if _, _, _, contents := parts(t, msgs[1]); !strings.Contains(contents[`text/plain; charset=utf-8; name="report.txt"`], "Source-Type: ipv6") {
+		t.Error("Expected an ipv6 source type")
+	}
+}
+
+func TestComposeARF(t *testing.T) {
+	r := report("192.0.2.1", "abuse@example.net")
+	r.Type = Spam
+	r.Message = []byte("From: spammer@example.org\r\nSubject: buy now\r\n\r\nbuy\r\n")
+	msgs, err := Compose(FormatARF, reporter, "abuse@example.net", []*Report{r}, t0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, mediaType, types, contents := parts(t, msgs[0])
+	if mediaType != "multipart/report" || len(types) != 3 || types[2] != "message/rfc822" {
+		t.Fatalf("Unexpected ARF structure %s %v", mediaType, types)
+	}
+	if fr := contents["message/feedback-report"]; !strings.Contains(fr, "Feedback-Type: abuse\r\n") || !strings.Contains(fr, "Source-IP: 192.0.2.1\r\n") {
+		t.Errorf("Unexpected feedback report %q", fr)
+	}
+
+	if _, err := Compose(FormatARF, Reporter{}, "abuse@example.net", []*Report{r}, t0); err == nil {
+		t.Error("Expected an error without a reporter email")
+	}
+	if _, err := Compose("pdf", reporter, "abuse@example.net", []*Report{r}, t0); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	now := t0
+	s := &sink{}
+	o := &Outbox{Reporter: reporter, Sink: s, DedupWindow: time.Hour, Now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	for _, r := range []*Report{
+		report("192.0.2.1", "abuse@example.net", "a", "b"),
+		report("192.0.2.1", "ABUSE@example.net", "a", "b", "c"),
+		report("192.0.2.2", "abuse@example.net", "x"),
+		report("198.51.100.1", "abuse@example.org", "y"),
+	} {
+		if ok, err := o.Add(r); !ok || err != nil {
+			t.Fatalf("Add: %v, %v", ok, err)
+		}
+	}
+	if _, err := o.Add(&Report{IP: &models.IPData{IPAddress: "192.0.2.9"}}); !errors.Is(err, ErrNoContact) {
+		t.Errorf("Expected ErrNoContact, got %v", err)
+	}
+	if n := o.Pending(); n != 3 {
+		t.Fatalf("Expected duplicates to be merged into 3 reports, got %d", n)
+	}
+
+	n, err := o.Flush(ctx)
+	if err != nil || n != 2 || len(s.msgs) != 2 || s.msgs[0].To != "abuse@example.net" {
+		t.Fatalf("Expected a message per contact, got %d, %v", n, err)
+	}
+	if raw := string(s.msgs[0].Raw); !strings.Contains(raw, "Events:       3") {
+		t.Errorf("Expected merged evidence:\n%s", raw)
+	}
+
+	// Sent reports are not reported again within the window.
+	if ok, _ := o.Add(report("192.0.2.1", "abuse@example.net", "d")); ok {
+		t.Error("Expected a duplicate to be dropped")
+	}
+	now = now.Add(time.Hour)
+	if ok, _ := o.Add(report("192.0.2.1", "abuse@example.net", "d")); !ok {
+		t.Error("Expected the report to be accepted after the window")
+	}
+
+	// Failed batches stay pending.
+	s.err = errors.New("relay down")
+	if _, err := o.Flush(ctx); err == nil || o.Pending() != 1 {
+		t.Fatalf("Expected the batch to stay pending, got %v, %d", err, o.Pending())
+	}
+	s.err = nil
+	if n, err := o.Flush(ctx); n != 1 || err != nil || o.Pending() != 0 {
+		t.Fatalf("Expected the retry to be sent, got %d, %v", n, err)
+	}
+}
+
+func TestDirSink(t *testing.T) {
+	dir := t.TempDir()
+	msgs, _ := Compose(FormatXARF, reporter, "abuse@example.net", []*Report{report("192.0.2.1", "abuse@example.net", "a")}, t0)
+	out := &Outbox{Reporter: reporter, Format: FormatXARF, Sink: DirSink{Dir: dir}}
+	ctx := context.Background()
+	for range 2 {
+		if err := out.Sink.Send(ctx, msgs[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != msgs[0].ID+".eml" {
+		t.Fatalf("Expected one message file, got %v", entries)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, entries[0].Name())); !bytes.Equal(b, msgs[0].Raw) {
+		t.Error("Unexpected message file content")
+	}
+}
@@ -0,0 +1,263 @@
+package abuse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultDedupWindow is the time during which an Outbox does not report
+// the same IP and attack type to the same contact again, when
+// Outbox.DedupWindow is not set.
+const DefaultDedupWindow = 24 * time.Hour
+
+// Sink delivers abuse report messages.
+type Sink interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// DirSink writes every message to an .eml file named after its ID in a
+// directory, for delivery by another process. A message whose file
+// already exists is not written again.
+type DirSink struct {
+	Dir string
+}
+
+// Send writes msg to the directory. The file is created atomically, so a
+// crash never leaves a partial message behind.
+func (s DirSink) Send(ctx context.Context, msg *Message) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(s.Dir, msg.ID+".eml")
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.Dir, msg.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(msg.Raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// SMTPSink sends messages through an SMTP server with net/smtp.
+type SMTPSink struct {
+	Addr string    // Addr is the host:port of the server.
+	Auth smtp.Auth // Auth authenticates with the server, if not nil.
+}
+
+// Send sends msg. The context is only checked before sending, as net/smtp
+// does not support cancellation.
+func (s SMTPSink) Send(ctx context.Context, msg *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := smtp.SendMail(s.Addr, s.Auth, msg.From, []string{msg.To}, msg.Raw); err != nil {
+		return fmt.Errorf("abuse: sending %s to %s: %w", msg.ID, msg.To, err)
+	}
+	return nil
+}
+
+// Outbox batches reports per abuse contact until they are flushed. Reports
+// about the same IP and attack type are merged while pending and not sent
+// again within the dedup window. It is safe for concurrent use.
+type Outbox struct {
+	Reporter Reporter
+	Format   Format // Format is the format of the messages, FormatText if empty.
+	Sink     Sink
+
+	// DedupWindow is the time during which a sent report is not sent
+	// again, DefaultDedupWindow if zero.
+	DedupWindow time.Duration
+
+	// Now returns the current time, time.Now if nil.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]map[string]*Report // pending maps recipients to reports by key.
+	sent    map[string]time.Time          // sent maps report keys to the time they were sent.
+}
+
+func (o *Outbox) now() time.Time {
+	if o.Now != nil {
+		return o.Now()
+	}
+	return time.Now()
+}
+
+func (o *Outbox) window() time.Duration {
+	if o.DedupWindow > 0 {
+		return o.DedupWindow
+	}
+	return DefaultDedupWindow
+}
+
+// Add queues a report. It returns false if the report is a duplicate of a
+// report sent within the dedup window, and the error of Recipient or
+// Validate if its lookup has no valid abuse contact or the report is
+// invalid. The evidence of a report that is already pending is merged
+// into it.
+func (o *Outbox) Add(r *Report) (bool, error) {
+	to, err := r.Recipient()
+	if err != nil {
+		return false, err
+	}
+	if err := r.Validate(); err != nil {
+		return false, err
+	}
+	key := r.Key()
+	now := o.now()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if at, ok := o.sent[key]; ok && now.Sub(at) < o.window() {
+		return false, nil
+	}
+	if o.pending == nil {
+		o.pending = make(map[string]map[string]*Report)
+	}
+	if o.pending[to] == nil {
+		o.pending[to] = make(map[string]*Report)
+	}
+	if p, ok := o.pending[to][key]; ok {
+		p.merge(r)
+		return true, nil
+	}
+	cp := *r
+	cp.Evidence = nil
+	cp.merge(r)
+	o.pending[to][key] = &cp
+	return true, nil
+}
+
+// Pending returns the number of pending reports.
+func (o *Outbox) Pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := 0
+	for _, reports := range o.pending {
+		n += len(reports)
+	}
+	return n
+}
+
+// Flush composes and sends the pending reports, one batch per contact,
+// and returns the number of messages sent. Batches that fail stay pending
+// for the next flush; the errors are joined.
+func (o *Outbox) Flush(ctx context.Context) (int, error) {
+	if o.Sink == nil {
+		return 0, errors.New("abuse: outbox has no sink")
+	}
+
+	o.mu.Lock()
+	batches := o.pending
+	o.pending = nil
+	o.mu.Unlock()
+
+	recipients := make([]string, 0, len(batches))
+	for to := range batches {
+		recipients = append(recipients, to)
+	}
+	sort.Strings(recipients)
+
+	sent := 0
+	var errs []error
+	for _, to := range recipients {
+		reports := make([]*Report, 0, len(batches[to]))
+		for _, r := range batches[to] {
+			reports = append(reports, r)
+		}
+		sort.Slice(reports, func(i, j int) bool { return reports[i].Key() < reports[j].Key() })
+
+		n, err := o.send(ctx, to, reports)
+		sent += n
+		done := reports
+		if err != nil {
+			// Machine readable formats send a message per report, so the
+			// first n reports went out.
+			errs = append(errs, err)
+			if o.Format == FormatText || o.Format == "" {
+				n = 0
+			}
+			done = reports[:n]
+			o.requeue(to, reports[n:])
+		}
+		o.mu.Lock()
+		if o.sent == nil {
+			o.sent = make(map[string]time.Time)
+		}
+		now := o.now()
+		for _, r := range done {
+			o.sent[r.Key()] = now
+		}
+		o.mu.Unlock()
+	}
+	o.forget()
+	return sent, errors.Join(errs...)
+}
+
+// send composes and sends the batch of one contact.
+func (o *Outbox) send(ctx context.Context, to string, reports []*Report) (int, error) {
+	msgs, err := Compose(o.Format, o.Reporter, to, reports, o.now())
+	if err != nil {
+		return 0, err
+	}
+	for i, msg := range msgs {
+		if err := o.Sink.Send(ctx, msg); err != nil {
+			return i, err
+		}
+	}
+	return len(msgs), nil
+}
+
+// requeue puts a failed batch back, merging it with reports added since.
+func (o *Outbox) requeue(to string, reports []*Report) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.pending == nil {
+		o.pending = make(map[string]map[string]*Report)
+	}
+	if o.pending[to] == nil {
+		o.pending[to] = make(map[string]*Report)
+	}
+	for _, r := range reports {
+		if p, ok := o.pending[to][r.Key()]; ok {
+			r.merge(p)
+		}
+		o.pending[to][r.Key()] = r
+	}
+}
+
+// forget drops sent keys older than the dedup window.
+func (o *Outbox) forget() {
+	now := o.now()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for key, at := range o.sent {
+		if now.Sub(at) >= o.window() {
+			delete(o.sent, key)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package abuse
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultUserAgent identifies the reporting software in X-ARF and ARF
+// reports when Reporter.UserAgent is not set.
+const DefaultUserAgent = "cerberius-go-client"
+
+// Format is the format of abuse report messages.
+type Format string
+
+const (
+	// FormatText is a plain text message covering every report for a
+	// recipient.
+	FormatText Format = "text"
+	// FormatXARF is an X-ARF 0.2 message per report.
+	FormatXARF Format = "x-arf"
+	// FormatARF is an ARF (RFC 5965) message per report. Reports without
+	// an original Message attach their evidence as plain text instead.
+	FormatARF Format = "arf"
+)
+
+// Reporter identifies the sender of abuse reports.
+type Reporter struct {
+	Name      string
+	Email     string
+	UserAgent string // UserAgent is the reporting software, DefaultUserAgent if empty.
+}
+
+func (rp Reporter) from() string {
+	return (&mail.Address{Name: rp.Name, Address: rp.Email}).String()
+}
+
+func (rp Reporter) userAgent() string {
+	if rp.UserAgent != "" {
+		return rp.UserAgent
+	}
+	return DefaultUserAgent
+}
+
+// domain returns the domain of the reporter's address, for message IDs.
+func (rp Reporter) domain() string {
+	if i := strings.LastIndexByte(rp.Email, '@'); i >= 0 {
+		return rp.Email[i+1:]
+	}
+	return "localhost"
+}
+
+// Message is a rendered abuse report message.
+type Message struct {
+	// ID identifies the message. It is derived from the content of the
+	// reports, so composing the same reports again yields the same ID.
+	ID      string
+	From    string // From is the address of the reporter.
+	To      string // To is the address of the abuse contact.
+	Subject string
+
+	// Raw is the complete RFC 5322 message, headers included, with CRLF
+	// line endings.
+	Raw []byte
+}
+
+// Compose renders reports addressed to one abuse contact, to, as
+// messages in format: one message for FormatText and one per report for
+// FormatXARF and FormatARF. Reports that fail Validate are refused.
+func Compose(format Format, rp Reporter, to string, reports []*Report, now time.Time) ([]*Message, error) {
+	if rp.Email == "" {
+		return nil, errors.New("abuse: reporter email is required")
+	}
+	if _, err := mail.ParseAddress(to); err != nil {
+		return nil, fmt.Errorf("%w %q: %v", ErrInvalidContact, to, err)
+	}
+	if len(reports) == 0 {
+		return nil, nil
+	}
+	for _, r := range reports {
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	switch format {
+	case FormatText, "":
+		return []*Message{composeText(rp, to, reports, now)}, nil
+	case FormatXARF, FormatARF:
+		msgs := make([]*Message, 0, len(reports))
+		for _, r := range reports {
+			msgs = append(msgs, composeMachine(format, rp, to, r, now))
+		}
+		return msgs, nil
+	}
+	return nil, fmt.Errorf("abuse: unknown format %q", format)
+}
+
+// messageID returns the ID of a message about reports.
+func messageID(format Format, to string, reports []*Report) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", format, to)
+	for _, r := range reports {
+		first, last := r.Span()
+		fmt.Fprintf(h, "%s\n%d\n%d\n%d\n", r.Key(), len(r.Evidence), first.UnixNano(), last.UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+func greeting(reports []*Report) string {
+	what := "an address"
+	if len(reports) > 1 {
+		what = fmt.Sprintf("%d addresses", len(reports))
+	}
+	return fmt.Sprintf("Dear abuse team,\r\n\r\nWe observed abusive activity from %s in your network. "+
+		"Please investigate and take appropriate action.\r\n\r\n", what)
+}
+
+func signature(rp Reporter) string {
+	name := rp.Name
+	if name == "" {
+		name = rp.Email
+	}
+	return fmt.Sprintf("\r\nRegards,\r\n%s <%s>\r\n", name, rp.Email)
+}
+
+func subject(reports []*Report) string {
+	if len(reports) == 1 {
+		return fmt.Sprintf("Abuse report: %s from %s", reports[0].Type, reports[0].address())
+	}
+	return fmt.Sprintf("Abuse report: %d incidents from your network", len(reports))
+}
+
+// header writes the common message headers.
+func header(buf *bytes.Buffer, rp Reporter, to, subj, id string, now time.Time, extra ...string) {
+	fmt.Fprintf(buf, "From: %s\r\n", rp.from())
+	fmt.Fprintf(buf, "To: %s\r\n", to)
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subj))
+	fmt.Fprintf(buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "Message-ID: <%s@%s>\r\n", id, rp.domain())
+	buf.WriteString("Auto-Submitted: auto-generated\r\nMIME-Version: 1.0\r\n")
+	for i := 0; i+1 < len(extra); i += 2 {
+		fmt.Fprintf(buf, "%s: %s\r\n", extra[i], extra[i+1])
+	}
+}
+
+func composeText(rp Reporter, to string, reports []*Report, now time.Time) *Message {
+	// List the reports in a stable order.
+	reports = append([]*Report(nil), reports...)
+	sort.SliceStable(reports, func(i, j int) bool { return reports[i].Key() < reports[j].Key() })
+
+	id := messageID(FormatText, to, reports)
+	subj := subject(reports)
+	var buf bytes.Buffer
+	header(&buf, rp, to, subj, id, now,
+		"Content-Type", "text/plain; charset=utf-8",
+		"Content-Transfer-Encoding", "8bit")
+	buf.WriteString("\r\n")
+	buf.WriteString(greeting(reports))
+	for i, r := range reports {
+		if i > 0 {
+			buf.WriteString("\r\n----\r\n\r\n")
+		}
+		buf.WriteString(crlf(r.Text()))
+	}
+	buf.WriteString(signature(rp))
+	return &Message{ID: id, From: rp.Email, To: to, Subject: subj, Raw: buf.Bytes()}
+}
+
+func composeMachine(format Format, rp Reporter, to string, r *Report, now time.Time) *Message {
+	id := messageID(format, to, []*Report{r})
+	subj := subject([]*Report{r})
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.SetBoundary("abuse-" + id)
+	part := func(contentType, disposition, content string) {
+		h := textproto.MIMEHeader{"Content-Type": {contentType}, "Content-Transfer-Encoding": {"8bit"}}
+		if disposition != "" {
+			h.Set("Content-Disposition", disposition)
+		}
+		w, _ := mw.CreatePart(h)
+		w.Write([]byte(content))
+	}
+
+	human := greeting([]*Report{r}) + crlf(r.Text()) + signature(rp)
+	first, _ := r.Span()
+	if first.IsZero() {
+		first = now
+	}
+
+	var buf bytes.Buffer
+	if format == FormatXARF {
+		header(&buf, rp, to, subj, id, now,
+			"X-XARF", "PLAIN",
+			"Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mw.Boundary()}))
+		part("text/plain; charset=utf-8", "", human)
+		part(`text/plain; charset=utf-8; name="report.txt"`, "", xarfReport(rp, r, id, first))
+		part(`text/plain; charset=utf-8; name="logfile.log"`, `attachment; filename="logfile.log"`, crlf(r.evidenceText()))
+	} else {
+		header(&buf, rp, to, subj, id, now,
+			"Content-Type", mime.FormatMediaType("multipart/report", map[string]string{"report-type": "feedback-report", "boundary": mw.Boundary()}))
+		part("text/plain; charset=utf-8", "", human)
+		part("message/feedback-report", "", arfReport(rp, r, first))
+		if len(r.Message) > 0 {
+			part("message/rfc822", "", string(r.Message))
+		} else {
+			part(`text/plain; charset=utf-8; name="logfile.log"`, `attachment; filename="logfile.log"`, crlf(r.evidenceText()))
+		}
+	}
+	mw.Close()
+	buf.WriteString("\r\n")
+	buf.Write(body.Bytes())
+	return &Message{ID: id, From: rp.Email, To: to, Subject: subj, Raw: buf.Bytes()}
+}
+
+// xarfReport renders the YAML report part of an X-ARF 0.2 message.
+func xarfReport(rp Reporter, r *Report, id string, date time.Time) string {
+	var b strings.Builder
+	line := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+		}
+	}
+	line("Reported-From", rp.Email)
+	line("Category", "abuse")
+	line("Report-Type", string(r.Type))
+	line("Service", r.Service)
+	if r.Port > 0 {
+		line("Port", fmt.Sprint(r.Port))
+	}
+	line("Version", "0.2")
+	line("User-Agent", rp.userAgent())
+	line("Date", date.Format(time.RFC1123Z))
+	line("Source-Type", sourceType(r.address()))
+	line("Source", r.address())
+	line("Attachment", "text/plain")
+	line("Report-ID", id+"@"+rp.domain())
+	line("Schema-URL", fmt.Sprintf("http://www.x-arf.org/schema/abuse_%s_0.1.2.json", r.Type))
+	return b.String()
+}
+
+// arfReport renders the machine readable part of an ARF message.
+func arfReport(rp Reporter, r *Report, arrival time.Time) string {
+	feedbackType := "abuse"
+	if r.Type == Malware {
+		feedbackType = "virus"
+	}
+	return fmt.Sprintf("Feedback-Type: %s\r\nUser-Agent: %s\r\nVersion: 1\r\nSource-IP: %s\r\nArrival-Date: %s\r\n",
+		feedbackType, rp.userAgent(), r.address(), arrival.Format(time.RFC1123Z))
+}
+
+func sourceType(addr string) string {
+	ip := net.ParseIP(addr)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return "ipv4"
+	}
+	return "ipv6"
+}
+
+// crlf converts LF line endings to CRLF.
+func crlf(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+}
@@ -0,0 +1,213 @@
+// Package abuse builds abuse reports from IP lookups and our own evidence.
+//
+// A Report combines a looked-up IP with log lines, timestamps and an
+// attack type. Compose renders reports addressed to the same abuse
+// contact as a plain text, X-ARF or ARF message, and an Outbox batches
+// reports per AbuseEmail, drops duplicates and delivers the messages to a
+// Sink such as an outbox directory or an SMTP relay:
+//
+//	out := &abuse.Outbox{
+//		Reporter: abuse.Reporter{Name: "Example SOC", Email: "soc@example.com"},
+//		Format:   abuse.FormatXARF,
+//		Sink:     abuse.DirSink{Dir: "/var/spool/abuse"},
+//	}
+//	out.Add(&abuse.Report{IP: ip, Type: abuse.LoginAttack, Evidence: lines})
+//	sent, err := out.Flush(ctx)
+package abuse
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	"cerberius.com/go-client/generated/models"
+)
+
+// AttackType is the kind of abuse being reported. The values follow the
+// X-ARF report types.
+type AttackType string
+
+const (
+	LoginAttack AttackType = "login-attack" // LoginAttack is brute forcing of logins.
+	PortScan    AttackType = "portscan"     // PortScan is scanning of ports.
+	DDoS        AttackType = "ddos"         // DDoS is a denial of service attack.
+	WebAttack   AttackType = "web-attack"   // WebAttack is exploitation of web applications.
+	Spam        AttackType = "spam"         // Spam is unsolicited email.
+	Malware     AttackType = "malware"      // Malware is the distribution of malware.
+)
+
+var (
+	// ErrNoContact is returned for reports whose lookup has neither an
+	// AbuseEmail nor an OrgEmail.
+	ErrNoContact = errors.New("abuse: no abuse contact")
+	// ErrInvalidContact is returned for reports whose lookup has an abuse
+	// contact that is not a valid email address.
+	ErrInvalidContact = errors.New("abuse: invalid abuse contact")
+	// ErrInvalidReport is returned for reports with a line break in a
+	// field that is rendered into a message header.
+	ErrInvalidReport = errors.New("abuse: invalid report")
+)
+
+// Evidence is a log line of the reported activity.
+type Evidence struct {
+	Time time.Time `json:"time"`
+	Line string    `json:"line"`
+}
+
+// Report is an abuse report about one IP address.
+type Report struct {
+	IP       *models.IPData `json:"ip"`
+	Type     AttackType     `json:"type"`
+	Evidence []Evidence     `json:"evidence"`
+
+	// Service and Port are the targeted service, such as ssh, and port,
+	// if known.
+	Service string `json:"service,omitempty"`
+	Port    int    `json:"port,omitempty"`
+
+	// Comment is a free text note for the recipient.
+	Comment string `json:"comment,omitempty"`
+
+	// Message is the original message of a Spam report. It is attached to
+	// ARF reports.
+	Message []byte `json:"message,omitempty"`
+}
+
+// Recipient returns the abuse contact of the report: the AbuseEmail of the
+// lookup, or its OrgEmail. The contacts come from whois data controlled by
+// the reported network, so only a valid address is returned. It returns
+// ErrNoContact if there is no contact, and an error wrapping
+// ErrInvalidContact if no contact is a valid address.
+func (r *Report) Recipient() (string, error) {
+	var invalid error
+	if r.IP != nil {
+		for _, addr := range []string{r.IP.AbuseEmail, r.IP.OrgEmail} {
+			if addr = strings.TrimSpace(addr); addr == "" {
+				continue
+			}
+			a, err := mail.ParseAddress(addr)
+			if err != nil {
+				if invalid == nil {
+					invalid = fmt.Errorf("%w %q: %v", ErrInvalidContact, addr, err)
+				}
+				continue
+			}
+			return strings.ToLower(a.Address), nil
+		}
+	}
+	if invalid != nil {
+		return "", invalid
+	}
+	return "", ErrNoContact
+}
+
+// Validate returns an error wrapping ErrInvalidReport if the attack type,
+// service, comment or IP address of the report contains a line break.
+func (r *Report) Validate() error {
+	for _, f := range []struct{ name, value string }{
+		{"type", string(r.Type)},
+		{"service", r.Service},
+		{"comment", r.Comment},
+		{"IP address", r.address()},
+	} {
+		if strings.ContainsAny(f.value, "\r\n") {
+			return fmt.Errorf("%w: line break in %s", ErrInvalidReport, f.name)
+		}
+	}
+	return nil
+}
+
+// Key returns the deduplication key of the report: its recipient, IP
+// address and attack type.
+func (r *Report) Key() string {
+	to, _ := r.Recipient()
+	return to + "|" + r.address() + "|" + string(r.Type)
+}
+
+func (r *Report) address() string {
+	if r.IP == nil {
+		return ""
+	}
+	return r.IP.IPAddress
+}
+
+// Span returns the times of the first and last evidence, or zero times if
+// there is none.
+func (r *Report) Span() (first, last time.Time) {
+	for _, e := range r.Evidence {
+		if first.IsZero() || e.Time.Before(first) {
+			first = e.Time
+		}
+		if e.Time.After(last) {
+			last = e.Time
+		}
+	}
+	return first, last
+}
+
+// merge adds the evidence of o that r does not have yet.
+func (r *Report) merge(o *Report) {
+	seen := make(map[Evidence]bool, len(r.Evidence))
+	for _, e := range r.Evidence {
+		seen[e] = true
+	}
+	for _, e := range o.Evidence {
+		if !seen[e] {
+			seen[e] = true
+			r.Evidence = append(r.Evidence, e)
+		}
+	}
+	sort.SliceStable(r.Evidence, func(i, j int) bool { return r.Evidence[i].Time.Before(r.Evidence[j].Time) })
+}
+
+// Text renders the report as plain text, without a greeting.
+func (r *Report) Text() string {
+	var b strings.Builder
+	ip := r.IP
+	if ip == nil {
+		ip = &models.IPData{}
+	}
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%-13s %s\n", name+":", value)
+		}
+	}
+	field("IP address", ip.IPAddress)
+	field("Reverse DNS", ip.ReverseDNS)
+	field("Network", strings.TrimSpace(strings.Join([]string{ip.ASN, ip.OrgName}, " ")))
+	field("Attack type", string(r.Type))
+	service := r.Service
+	if r.Port > 0 {
+		service = strings.TrimSpace(fmt.Sprintf("%s port %d", service, r.Port))
+	}
+	field("Target", service)
+	if first, last := r.Span(); !first.IsZero() {
+		field("First seen", first.UTC().Format(time.RFC3339))
+		field("Last seen", last.UTC().Format(time.RFC3339))
+	}
+	field("Events", fmt.Sprint(len(r.Evidence)))
+	if r.Comment != "" {
+		fmt.Fprintf(&b, "\n%s\n", r.Comment)
+	}
+	if len(r.Evidence) > 0 {
+		b.WriteString("\nEvidence (times in UTC):\n")
+		b.WriteString(r.evidenceText())
+	}
+	return b.String()
+}
+
+func (r *Report) evidenceText() string {
+	var b strings.Builder
+	for _, e := range r.Evidence {
+		line := strings.ReplaceAll(strings.TrimRight(e.Line, "\r\n"), "\n", " ")
+		if e.Time.IsZero() {
+			fmt.Fprintf(&b, "%s\n", line)
+		} else {
+			fmt.Fprintf(&b, "%s %s\n", e.Time.UTC().Format(time.RFC3339), line)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,400 @@
+// Package asn aggregates IP lookups by network.
+//
+// An Aggregator groups lookups by autonomous system, falling back to the
+// organisation name for lookups without an ASN, and keeps rolling
+// statistics per network: the fraud score distribution and the Tor,
+// anonymous and blocklist rates. Networks are classified as hosting,
+// residential or mobile from their ISP, organisation and reverse DNS
+// names, and Signals turns what is known about a network into extra risk
+// signals for new IPs from it:
+//
+//	agg := asn.New(asn.Options{})
+//	agg.Add(resp.Payload.Data...)
+//	if s := agg.Signals(ip); s.Risk >= 50 {
+//		log.Println(ip.IPAddress, s.Reasons)
+//	}
+package asn
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cerberius.com/go-client/generated/models"
+)
+
+// Defaults for the zero fields of Options.
+const (
+	DefaultWindow     = 7 * 24 * time.Hour
+	DefaultMinLookups = 10
+)
+
+// windowBuckets is the number of buckets of a rolling window.
+const windowBuckets = 10
+
+// scoreBins is the number of bins of the fraud score histogram.
+const scoreBins = 10
+
+// Options configures an Aggregator.
+type Options struct {
+	// Window is the period statistics are kept for, DefaultWindow if zero.
+	Window time.Duration
+
+	// MinLookups is the number of lookups of a network from which its
+	// rates are used as risk signals, DefaultMinLookups if zero.
+	MinLookups int
+
+	// Now returns the current time, time.Now if nil.
+	Now func() time.Time
+}
+
+// Stats are the statistics of a network over the window.
+type Stats struct {
+	Lookups     int `json:"lookups"`
+	Tor         int `json:"tor"`
+	Anonymous   int `json:"anonymous"`
+	BlockListed int `json:"block_listed"`
+
+	// Scored is the number of lookups with a fraud score, and FraudScores
+	// their histogram: FraudScores[i] counts the scores from 10*i to
+	// 10*i+9, with 100 in the last bin.
+	Scored      int            `json:"scored"`
+	ScoreSum    int            `json:"score_sum"`
+	FraudScores [scoreBins]int `json:"fraud_scores"`
+
+	kinds [kindCount]int
+}
+
+func (s *Stats) add(o Stats) {
+	s.Lookups += o.Lookups
+	s.Tor += o.Tor
+	s.Anonymous += o.Anonymous
+	s.BlockListed += o.BlockListed
+	s.Scored += o.Scored
+	s.ScoreSum += o.ScoreSum
+	for i := range s.FraudScores {
+		s.FraudScores[i] += o.FraudScores[i]
+	}
+	for i := range s.kinds {
+		s.kinds[i] += o.kinds[i]
+	}
+}
+
+// TorRate returns the share of lookups that were Tor exit points.
+func (s Stats) TorRate() float64 { return rate(s.Tor, s.Lookups) }
+
+// AnonymousRate returns the share of lookups that were anonymous.
+func (s Stats) AnonymousRate() float64 { return rate(s.Anonymous, s.Lookups) }
+
+// BlockListRate returns the share of lookups that were on a blocklist.
+func (s Stats) BlockListRate() float64 { return rate(s.BlockListed, s.Lookups) }
+
+// MeanFraudScore returns the mean fraud score, or 0 if no lookup was
+// scored.
+func (s Stats) MeanFraudScore() float64 { return rate(s.ScoreSum, s.Scored) }
+
+// FraudScoreQuantile returns the upper bound of the histogram bin holding
+// the q quantile of fraud scores, or 0 if no lookup was scored.
+func (s Stats) FraudScoreQuantile(q float64) int {
+	if s.Scored == 0 {
+		return 0
+	}
+	rank := max(1, int(math.Ceil(q*float64(s.Scored))))
+	seen := 0
+	for i, n := range s.FraudScores {
+		seen += n
+		if seen >= rank {
+			return min(10*i+9, 100)
+		}
+	}
+	return 100
+}
+
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// Network is a network and its statistics.
+type Network struct {
+	Key     string `json:"key"` // Key is the ASN, or "org:" and the lower-cased organisation name.
+	ASN     string `json:"asn,omitempty"`
+	OrgName string `json:"org_name,omitempty"`
+	ISP     string `json:"isp,omitempty"`
+	Kind    Kind   `json:"kind"`
+	Stats   Stats  `json:"stats"`
+
+	// LastSeen is the time of the last lookup of the network.
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// bucket holds the statistics of one slice of the rolling window.
+type bucket struct {
+	start time.Time
+	Stats
+}
+
+type network struct {
+	asn, org, isp string
+	orgKind       Kind
+	lastSeen      time.Time
+	buckets       [windowBuckets]bucket
+}
+
+// Aggregator keeps rolling statistics of networks. It is safe for
+// concurrent use.
+type Aggregator struct {
+	opts Options
+
+	mu       sync.Mutex
+	networks map[string]*network
+}
+
+// New creates a new Aggregator.
+func New(opts Options) *Aggregator {
+	if opts.Window <= 0 {
+		opts.Window = DefaultWindow
+	}
+	if opts.MinLookups <= 0 {
+		opts.MinLookups = DefaultMinLookups
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Aggregator{opts: opts, networks: make(map[string]*network)}
+}
+
+// Key returns the key of the network of a lookup: its ASN, normalised to
+// the AS123 form, or "org:" and the lower-cased organisation name. It
+// returns "" if the lookup has neither.
+func Key(ip *models.IPData) string {
+	if ip == nil {
+		return ""
+	}
+	if asn := normalizeASN(ip.ASN); asn != "" {
+		return asn
+	}
+	if org := strings.ToLower(strings.TrimSpace(ip.OrgName)); org != "" {
+		return "org:" + org
+	}
+	return ""
+}
+
+func normalizeASN(asn string) string {
+	asn = strings.ToUpper(strings.TrimSpace(asn))
+	if asn == "" {
+		return ""
+	}
+	// Some sources return "AS123 Org Name".
+	if i := strings.IndexByte(asn, ' '); i >= 0 {
+		asn = asn[:i]
+	}
+	// The API documents "ASN123456"; other sources use "AS123456" or the
+	// bare number.
+	asn = strings.TrimPrefix(asn, "ASN")
+	asn = strings.TrimPrefix(asn, "AS")
+	return "AS" + asn
+}
+
+// Add records lookups. Lookups without an ASN or organisation name are
+// ignored.
+func (a *Aggregator) Add(ips ...*models.IPData) {
+	now := a.opts.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, ip := range ips {
+		key := Key(ip)
+		if key == "" {
+			continue
+		}
+		n, ok := a.networks[key]
+		if !ok {
+			n = &network{orgKind: KindUnknown}
+			a.networks[key] = n
+		}
+		if ip.ASN != "" {
+			n.asn = normalizeASN(ip.ASN)
+		}
+		if ip.OrgName != "" || ip.ISP != "" {
+			n.org, n.isp = ip.OrgName, ip.ISP
+			n.orgKind = classifyNames(ip.ISP, ip.OrgName)
+		}
+		n.lastSeen = now
+
+		s := a.current(n, now)
+		s.Lookups++
+		if ip.IsTorExitPoint {
+			s.Tor++
+		}
+		if ip.IsAnonymous {
+			s.Anonymous++
+		}
+		if ip.OnBlockList {
+			s.BlockListed++
+		}
+		if score, err := strconv.Atoi(strings.TrimSpace(ip.FraudScore)); err == nil && score >= 0 && score <= 100 {
+			s.Scored++
+			s.ScoreSum += score
+			s.FraudScores[min(score/10, scoreBins-1)]++
+		}
+		s.kinds[classifyReverseDNS(ip.ReverseDNS)]++
+	}
+}
+
+// current returns the bucket of n for now, resetting it if it is stale.
+// a.mu must be held.
+func (a *Aggregator) current(n *network, now time.Time) *Stats {
+	size := a.opts.Window / windowBuckets
+	start := now.Truncate(size)
+	bk := &n.buckets[(start.UnixNano()/int64(size))%windowBuckets]
+	if !bk.start.Equal(start) {
+		*bk = bucket{start: start}
+	}
+	return &bk.Stats
+}
+
+// snapshot returns the public view of n. a.mu must be held.
+func (a *Aggregator) snapshot(key string, n *network, now time.Time) Network {
+	var s Stats
+	oldest := now.Truncate(a.opts.Window / windowBuckets).Add(-a.opts.Window)
+	for _, bk := range n.buckets {
+		if bk.start.After(oldest) {
+			s.add(bk.Stats)
+		}
+	}
+	return Network{
+		Key:      key,
+		ASN:      n.asn,
+		OrgName:  n.org,
+		ISP:      n.isp,
+		Kind:     networkKind(n.orgKind, s.kinds),
+		Stats:    s,
+		LastSeen: n.lastSeen,
+	}
+}
+
+// Network returns the network with the given key, as returned by Key.
+func (a *Aggregator) Network(key string) (Network, bool) {
+	now := a.opts.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n, ok := a.networks[key]
+	if !ok {
+		return Network{}, false
+	}
+	return a.snapshot(key, n, now), true
+}
+
+// Networks returns every network with lookups in the window, most looked
+// up first.
+func (a *Aggregator) Networks() []Network {
+	now := a.opts.Now()
+	a.mu.Lock()
+	networks := make([]Network, 0, len(a.networks))
+	for key, n := range a.networks {
+		if nw := a.snapshot(key, n, now); nw.Stats.Lookups > 0 {
+			networks = append(networks, nw)
+		}
+	}
+	a.mu.Unlock()
+
+	sort.Slice(networks, func(i, j int) bool {
+		if networks[i].Stats.Lookups != networks[j].Stats.Lookups {
+			return networks[i].Stats.Lookups > networks[j].Stats.Lookups
+		}
+		return networks[i].Key < networks[j].Key
+	})
+	return networks
+}
+
+// Prune forgets networks without lookups in the window and returns their
+// number.
+func (a *Aggregator) Prune() int {
+	now := a.opts.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	pruned := 0
+	for key, n := range a.networks {
+		if now.Sub(n.lastSeen) >= a.opts.Window {
+			delete(a.networks, key)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// Signals are risk signals for an IP derived from what is known about its
+// network.
+type Signals struct {
+	Network string `json:"network"` // Network is the network key, "" if unknown.
+	Kind    Kind   `json:"kind"`
+
+	// Lookups is the number of lookups of the network in the window. The
+	// rate signals are only used from Options.MinLookups lookups.
+	Lookups int `json:"lookups"`
+
+	// Risk is an extra risk score from 0 to 100, with the Reasons that
+	// contributed to it.
+	Risk    int      `json:"risk"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Signals returns the risk signals of the network of ip. The lookup itself
+// is not recorded; call Add for that.
+func (a *Aggregator) Signals(ip *models.IPData) Signals {
+	if ip == nil {
+		return Signals{Kind: KindUnknown}
+	}
+	key := Key(ip)
+	nw, ok := a.Network(key)
+	if !ok {
+		kind := Classify(ip.ISP, ip.OrgName, ip.ReverseDNS)
+		s := Signals{Network: key, Kind: kind}
+		if kind == KindHosting {
+			s.Risk = hostingRisk
+			s.Reasons = []string{"hosting network"}
+		}
+		return s
+	}
+
+	s := Signals{Network: key, Kind: nw.Kind, Lookups: nw.Stats.Lookups}
+	risk := 0.0
+	if nw.Kind == KindHosting {
+		risk += hostingRisk
+		s.Reasons = append(s.Reasons, "hosting network")
+	}
+	if nw.Stats.Lookups >= a.opts.MinLookups {
+		for _, r := range []struct {
+			name   string
+			rate   float64
+			weight float64
+		}{
+			{"Tor", nw.Stats.TorRate(), 40},
+			{"anonymous", nw.Stats.AnonymousRate(), 30},
+			{"blocklisted", nw.Stats.BlockListRate(), 30},
+		} {
+			if r.rate >= minRate {
+				risk += r.rate * r.weight
+				s.Reasons = append(s.Reasons, fmt.Sprintf("%.0f%% of network lookups %s", 100*r.rate, r.name))
+			}
+		}
+		if mean := nw.Stats.MeanFraudScore(); nw.Stats.Scored > 0 && mean >= 50 {
+			risk += (mean - 50) / 2
+			s.Reasons = append(s.Reasons, fmt.Sprintf("mean network fraud score %.0f", mean))
+		}
+	}
+	s.Risk = min(int(risk+0.5), 100)
+	return s
+}
+
+// Signal weights.
+const (
+	hostingRisk = 20
+	minRate     = 0.05 // minRate is the smallest rate reported as a signal.
+)
@@ -0,0 +1,100 @@
+package asn
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"cerberius.com/go-client/generated/models"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		isp, org, rdns string
+		want           Kind
+	}{
+		{"Hetzner Online GmbH", "", "", KindHosting},
+		{"", "Amazon.com, Inc.", "ec2-1-2-3-4.compute-1.amazonaws.com", KindHosting},
+		{"Deutsche Telekom AG", "", "", KindResidential},
+		{"T-Mobile USA", "", "", KindMobile},
+		{"", "", "p5b0c1234.dip0.t-ipconnect.de", KindUnknown},
+		{"", "", "cpe-1-2-3-4.nyc.res.rr.com", KindResidential},
+		{"", "", "dsl-187-1-2-3.example.net", KindResidential},
+		{"", "", "vps123.provider.net", KindHosting},
+		{"Ghostwriters Ltd", "", "", KindUnknown},
+		{"", "", "example.com", KindUnknown},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.isp, tt.org, tt.rdns); got != tt.want {
+			t.Errorf("Classify(%q, %q, %q) = %s, want %s", tt.isp, tt.org, tt.rdns, got, tt.want)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	for ip, want := range map[*models.IPData]string{
+		{ASN: "13335"}:              "AS13335",
+		{ASN: "ASN13335"}:           "AS13335",
+		{ASN: "AS13335"}:            "AS13335",
+		{ASN: "as13335 Cloudflare"}: "AS13335",
+		{OrgName: " Example Corp "}: "org:example corp",
+		{}:                          "",
+	} {
+		if got := Key(ip); got != want {
+			t.Errorf("Key(%+v) = %q, want %q", ip, got, want)
+		}
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	a := New(Options{Window: time.Hour, MinLookups: 4, Now: func() time.Time { return now }})
+
+	for i := range 10 {
+		a.Add(&models.IPData{
+			IPAddress:      fmt.Sprintf("10.0.0.%d", i),
+			ASN:            "AS64500",
+			ISP:            "Example Hosting",
+			FraudScore:     fmt.Sprint(60 + 4*i),
+			IsTorExitPoint: i < 5,
+			OnBlockList:    i < 2,
+		})
+	}
+	a.Add(&models.IPData{ASN: "64501", ReverseDNS: "dsl-1.isp.net", FraudScore: "5"})
+	a.Add(&models.IPData{IPAddress: "no network"})
+
+	nw, ok := a.Network("AS64500")
+	if !ok || nw.Kind != KindHosting || nw.Stats.Lookups != 10 || nw.Stats.TorRate() != 0.5 || nw.Stats.BlockListRate() != 0.2 {
+		t.Fatalf("Unexpected network %+v", nw)
+	}
+	if mean := nw.Stats.MeanFraudScore(); mean != 78 {
+		t.Errorf("Expected mean fraud score 78, got %v", mean)
+	}
+	if q := nw.Stats.FraudScoreQuantile(0.5); q != 79 {
+		t.Errorf("Expected median bin 70-79, got %d", q)
+	}
+	if got := a.Networks(); len(got) != 2 || got[0].Key != "AS64500" || got[1].Kind != KindResidential {
+		t.Fatalf("Unexpected networks %+v", got)
+	}
+
+	s := a.Signals(&models.IPData{ASN: "AS64500"})
+	// 20 hosting + 0.5*40 Tor + 0.2*30 blocklist + (78-50)/2 fraud score.
+	if s.Kind != KindHosting || s.Risk != 60 || len(s.Reasons) != 4 {
+		t.Errorf("Unexpected signals %+v", s)
+	}
+	if s := a.Signals(&models.IPData{ASN: "AS64501"}); s.Risk != 0 || s.Lookups != 1 {
+		t.Errorf("Expected no risk for a small residential network, got %+v", s)
+	}
+	if s := a.Signals(&models.IPData{ASN: "AS1", ISP: "DigitalOcean, LLC"}); s.Kind != KindHosting || s.Risk != hostingRisk {
+		t.Errorf("Expected an unseen hosting network to be flagged, got %+v", s)
+	}
+
+	// Statistics roll out of the window.
+	now = now.Add(time.Hour)
+	if nw, _ := a.Network("AS64500"); nw.Stats.Lookups != 0 {
+		t.Errorf("Expected the window to be empty, got %+v", nw.Stats)
+	}
+	if n := a.Prune(); n != 2 || len(a.Networks()) != 0 {
+		t.Errorf("Expected 2 networks to be pruned, got %d", n)
+	}
+}
@@ -0,0 +1,147 @@
+package asn
+
+import "strings"
+
+// Kind is the kind of a network.
+type Kind string
+
+const (
+	KindUnknown     Kind = "unknown"     // KindUnknown is a network the heuristics cannot place.
+	KindHosting     Kind = "hosting"     // KindHosting is a hosting, cloud or datacenter network.
+	KindResidential Kind = "residential" // KindResidential is a consumer broadband network.
+	KindMobile      Kind = "mobile"      // KindMobile is a cellular network.
+)
+
+// kindCount is the number of kinds, for vote counting.
+const kindCount = 4
+
+// kinds maps vote indexes to kinds.
+var kinds = [kindCount]Kind{KindUnknown, KindHosting, KindResidential, KindMobile}
+
+func kindIndex(k Kind) int {
+	for i, kk := range kinds {
+		if kk == k {
+			return i
+		}
+	}
+	return 0
+}
+
+// Keywords of the ISP and organisation names, checked in order: a
+// hosting company that also sells broadband is counted as hosting.
+var (
+	HostingKeywords = []string{
+		"hosting", "host", "cloud", "datacenter", "data center", "data centre",
+		"server", "vps", "colo", "dedicated", "amazon", "aws", "google llc",
+		"microsoft", "azure", "digitalocean", "ovh", "hetzner", "linode",
+		"akamai", "vultr", "choopa", "contabo", "leaseweb", "m247", "scaleway",
+		"oracle", "alibaba", "tencent", "cdn",
+	}
+	MobileKeywords = []string{
+		"mobile", "wireless", "cellular", "lte", "5g", "t-mobile", "vodafone",
+	}
+	ResidentialKeywords = []string{
+		"broadband", "cable", "dsl", "fiber", "fibre", "telecom", "telekom",
+		"communications", "internet service", "residential", "comcast",
+		"verizon", "charter", "spectrum", "cox", "orange", "telefonica",
+	}
+)
+
+// Labels of reverse DNS names, matched as whole dot or dash separated
+// labels or label prefixes.
+var (
+	hostingLabels     = []string{"vps", "server", "srv", "cloud", "compute", "ec2", "host", "dedi"}
+	mobileLabels      = []string{"mobile", "lte", "cell", "wireless", "gprs", "umts"}
+	residentialLabels = []string{"dsl", "adsl", "vdsl", "dyn", "dynamic", "pool", "cable", "dhcp", "ppp", "pppoe", "ftth", "fttx", "fiber", "broadband", "cpe", "home"}
+)
+
+// Classify classifies a network from its ISP and organisation names,
+// falling back to the reverse DNS name of one of its IPs.
+func Classify(isp, org, reverseDNS string) Kind {
+	if k := classifyNames(isp, org); k != KindUnknown {
+		return k
+	}
+	return kinds[classifyReverseDNS(reverseDNS)]
+}
+
+func classifyNames(isp, org string) Kind {
+	names := strings.ToLower(isp + " | " + org)
+	switch {
+	case containsWord(names, HostingKeywords):
+		return KindHosting
+	case containsWord(names, MobileKeywords):
+		return KindMobile
+	case containsWord(names, ResidentialKeywords):
+		return KindResidential
+	}
+	return KindUnknown
+}
+
+// classifyReverseDNS returns the vote index of a reverse DNS name.
+func classifyReverseDNS(name string) int {
+	labels := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return r == '.' || r == '-' || r == '_' || r >= '0' && r <= '9'
+	})
+	// The last two labels are the registered domain.
+	if len(labels) > 2 {
+		labels = labels[:len(labels)-2]
+	} else {
+		labels = nil
+	}
+	for _, set := range []struct {
+		kind   Kind
+		labels []string
+	}{
+		{KindHosting, hostingLabels},
+		{KindMobile, mobileLabels},
+		{KindResidential, residentialLabels},
+	} {
+		for _, l := range labels {
+			for _, want := range set.labels {
+				if strings.HasPrefix(l, want) {
+					return kindIndex(set.kind)
+				}
+			}
+		}
+	}
+	return kindIndex(KindUnknown)
+}
+
+// containsWord reports whether s contains one of words at word
+// boundaries.
+func containsWord(s string, words []string) bool {
+	for _, w := range words {
+		for i := 0; ; {
+			j := strings.Index(s[i:], w)
+			if j < 0 {
+				break
+			}
+			start, end := i+j, i+j+len(w)
+			if (start == 0 || !isLetter(s[start-1])) && (end == len(s) || !isLetter(s[end])) {
+				return true
+			}
+			i = start + 1
+		}
+	}
+	return false
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
+
+// networkKind returns the kind of a network: the kind of its names if
+// they are conclusive, otherwise the most common kind among the
+// conclusive reverse DNS names of its lookups.
+func networkKind(names Kind, votes [kindCount]int) Kind {
+	if names != KindUnknown {
+		return names
+	}
+	best, bestVotes := 0, 0
+	for i := 1; i < kindCount; i++ {
+		if votes[i] > bestVotes {
+			best, bestVotes = i, votes[i]
+		}
+	}
+	return kinds[best]
+}
@@ -19,6 +19,11 @@ type HMACAuthTransport struct {
 	APIKey    string             // APIKey is the Cerberus API Key.
 	APISecret string             // APISecret is the Cerberus API Secret.
 	Transport http.RoundTripper // Transport is the underlying transport to delegate requests to.
+
+	// SignBody enables signature version 2, which also covers the request
+	// method, path and body. See CanonicalString. The Cerberius API does not
+	// accept version 2 signatures; use it only against a Verifier.
+	SignBody bool
 }
 
 // NewHMACAuthTransport creates a new HMACAuthTransport.
@@ -49,13 +54,21 @@ func (t *HMACAuthTransport) RoundTrip(req *http.Request) (*http.Response, error)
 	// Get current UNIX timestamp as a string.
 	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
 
-	// Construct the message for HMAC signature: timestamp + apiKey.
+	// Construct the message for HMAC signature: timestamp + apiKey, or the
+	// canonical string when signing the body.
 	message := timestamp + t.APIKey
+	if t.SignBody {
+		bodyHash, err := hashBody(req, reqClone)
+		if err != nil {
+			return nil, err
+		}
+		message = CanonicalString(timestamp, t.APIKey, reqClone.Method, reqClone.URL.EscapedPath(), bodyHash)
+		reqClone.Header.Set(SignatureVersionHeader, SignatureVersion2)
+		reqClone.Header.Set(ContentHashHeader, bodyHash)
+	}
 
 	// Calculate HMAC-SHA256 signature.
-	mac := hmac.New(sha256.New, []byte(t.APISecret))
-	mac.Write([]byte(message))
-	signature := hex.EncodeToString(mac.Sum(nil))
+	signature := sign(t.APISecret, message)
 
 	// Add authentication headers to the cloned request.
 	reqClone.Header.Set("X-API-Key", t.APIKey)
@@ -72,3 +85,10 @@ func (t *HMACAuthTransport) RoundTrip(req *http.Request) (*http.Response, error)
 	// Delegate the request to the nested RoundTripper.
 	return t.Transport.RoundTrip(reqClone)
 }
+
+// sign returns the hex-encoded HMAC-SHA256 of message using secret.
+func sign(secret, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
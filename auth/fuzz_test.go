@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// signAndCheck signs a request built from the arguments and reports a
+// property violation: the caller's request must not change, and a Verifier
+// must accept the signed request.
+func signAndCheck(t *testing.T, apiKey, apiSecret, method, headerValue string, body []byte, signBody bool) {
+	t.Helper()
+
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, "http://example.com/api/email-lookup", reqBody)
+	if err != nil {
+		// Not a valid HTTP method; nothing to sign.
+		return
+	}
+	req.Header.Set("X-Custom", headerValue)
+	before := req.Header.Clone()
+
+	mockNext := &mockRoundTripper{}
+	transport := NewHMACAuthTransport(apiKey, apiSecret, mockNext)
+	transport.SignBody = signBody
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(req.Header, before) {
+		t.Fatalf("RoundTrip mutated the caller's headers: before %v, after %v", before, req.Header)
+	}
+	if req.Method != method || req.URL.Path != "/api/email-lookup" {
+		t.Fatalf("RoundTrip mutated the caller's request line: %s %s", req.Method, req.URL)
+	}
+	if body != nil {
+		got, _ := io.ReadAll(req.Body)
+		if !bytes.Equal(got, body) {
+			t.Fatalf("RoundTrip consumed the caller's body: got %q, want %q", got, body)
+		}
+	}
+
+	if apiKey == "" {
+		// An empty key cannot be told apart from a missing header.
+		return
+	}
+	if body != nil {
+		// The clone shares the caller's body, read above; deliver it again
+		// as the server would receive it.
+		mockNext.request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	v := NewVerifier(map[string]string{apiKey: apiSecret})
+	v.RequireBodySignature = signBody
+	if err := v.Verify(mockNext.request); err != nil {
+		t.Fatalf("Verifier rejected a signed request (key %q, secret %q): %v", apiKey, apiSecret, err)
+	}
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add("testAPIKey", "testAPISecret", "POST", "value", []byte(`{"data":["user@example.com"]}`), false)
+	f.Add("testAPIKey", "testAPISecret", "POST", "value", []byte(`{"data":["user@example.com"]}`), true)
+	f.Add("", "", "GET", "", []byte(nil), false)
+	f.Add("k\x00ey", "sécret", "PUT", "a\r\nb", []byte{0xff, 0xfe}, true)
+
+	f.Fuzz(func(t *testing.T, apiKey, apiSecret, method, headerValue string, body []byte, signBody bool) {
+		signAndCheck(t, apiKey, apiSecret, method, headerValue, body, signBody)
+	})
+}
+
+func TestRoundTripProperties(t *testing.T) {
+	property := func(apiKey, apiSecret, headerValue string, body []byte, signBody bool) bool {
+		signAndCheck(t, apiKey, apiSecret, "POST", headerValue, body, signBody)
+		return !t.Failed()
+	}
+	if err := quick.Check(property, nil); err != nil {
+		t.Error(err)
+	}
+}
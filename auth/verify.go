@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultMaxSkew is the maximum difference between the X-Timestamp header
+// and the current time accepted by a Verifier, matching the Cerberus API.
+const DefaultMaxSkew = 5 * time.Minute
+
+// DefaultMaxBodySize is the largest request body a Verifier reads to check
+// a body signature.
+const DefaultMaxBodySize = 1 << 20
+
+// Errors returned by Verifier.Verify.
+var (
+	ErrMissingHeaders = errors.New("auth: missing authentication headers")
+	ErrUnknownKey     = errors.New("auth: unknown API key")
+	ErrTimestampSkew  = errors.New("auth: timestamp outside the allowed window")
+	ErrBadSignature   = errors.New("auth: signature mismatch")
+
+	ErrSignatureRequired  = errors.New("auth: body signature required")
+	ErrUnsupportedVersion = errors.New("auth: unsupported signature version")
+	ErrBodyMismatch       = errors.New("auth: body does not match content hash")
+	ErrBodyTooLarge       = errors.New("auth: body too large")
+)
+
+// SecretFunc returns the API secret for an API key, or false if the key is
+// unknown.
+type SecretFunc func(apiKey string) (secret string, ok bool)
+
+// Verifier checks the HMAC authentication headers produced by
+// HMACAuthTransport. It is intended for gateways and test servers that
+// accept requests from this client.
+type Verifier struct {
+	Secret  SecretFunc       // Secret looks up the API secret of a request's API key.
+	MaxSkew time.Duration    // MaxSkew is the accepted timestamp difference, DefaultMaxSkew if zero.
+	Now     func() time.Time // Now returns the current time, time.Now if nil.
+
+	// RequireBodySignature rejects requests that are not signed with
+	// signature version 2 with ErrSignatureRequired.
+	RequireBodySignature bool
+
+	// MaxBodySize is the largest body, in bytes, read to check a body
+	// signature, DefaultMaxBodySize if zero. Larger bodies fail with
+	// ErrBodyTooLarge.
+	MaxBodySize int64
+}
+
+// NewVerifier creates a new Verifier that accepts the API key and secret
+// pairs in secrets.
+func NewVerifier(secrets map[string]string) *Verifier {
+	return &Verifier{
+		Secret: func(apiKey string) (string, bool) {
+			secret, ok := secrets[apiKey]
+			return secret, ok
+		},
+	}
+}
+
+// Verify checks the X-API-Key, X-Timestamp and X-Signature headers of req.
+// Requests signed with signature version 2 are checked against their
+// method, path and body; the body, up to MaxBodySize bytes, is read and
+// replaced so that it can still be read by handlers.
+func (v *Verifier) Verify(req *http.Request) error {
+	apiKey := req.Header.Get("X-API-Key")
+	timestamp := req.Header.Get("X-Timestamp")
+	signature := req.Header.Get("X-Signature")
+	if apiKey == "" || timestamp == "" || signature == "" {
+		return ErrMissingHeaders
+	}
+
+	secret, ok := v.Secret(apiKey)
+	if !ok {
+		return ErrUnknownKey
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrTimestampSkew
+	}
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	maxSkew := v.MaxSkew
+	if maxSkew == 0 {
+		maxSkew = DefaultMaxSkew
+	}
+	if skew := now().Sub(time.Unix(ts, 0)); skew > maxSkew || skew < -maxSkew {
+		return ErrTimestampSkew
+	}
+
+	message := timestamp + apiKey
+	switch version := req.Header.Get(SignatureVersionHeader); version {
+	case "", "1":
+		if v.RequireBodySignature {
+			return ErrSignatureRequired
+		}
+	case SignatureVersion2:
+		maxBody := v.MaxBodySize
+		if maxBody == 0 {
+			maxBody = DefaultMaxBodySize
+		}
+		bodyHash, err := readBodyHash(req, maxBody)
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal([]byte(req.Header.Get(ContentHashHeader)), []byte(bodyHash)) {
+			return ErrBodyMismatch
+		}
+		message = CanonicalString(timestamp, apiKey, req.Method, req.URL.EscapedPath(), bodyHash)
+	default:
+		return ErrUnsupportedVersion
+	}
+
+	expected := sign(secret, message)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// Middleware returns an http.Handler that rejects requests failing Verify
+// with the API's 401 error response and passes the others to next.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := v.Verify(req); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"code":100401,"message":"Unauthorized"}}`))
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
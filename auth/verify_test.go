@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signedRequest returns a request signed by HMACAuthTransport.
+func signedRequest(t *testing.T, apiKey, apiSecret string) *http.Request {
+	t.Helper()
+	mockNext := &mockRoundTripper{}
+	req, _ := http.NewRequest("POST", "http://example.com/api/ip-lookup", nil)
+	if _, err := NewHMACAuthTransport(apiKey, apiSecret, mockNext).RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	return mockNext.request
+}
+
+func TestVerifierAcceptsSignedRequest(t *testing.T) {
+	v := NewVerifier(map[string]string{"testKey": "testSecret"})
+	if err := v.Verify(signedRequest(t, "testKey", "testSecret")); err != nil {
+		t.Errorf("Expected signed request to verify, got %v", err)
+	}
+}
+
+func TestVerifierRejections(t *testing.T) {
+	v := NewVerifier(map[string]string{"testKey": "testSecret"})
+
+	t.Run("MissingHeaders", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "http://example.com", nil)
+		if err := v.Verify(req); !errors.Is(err, ErrMissingHeaders) {
+			t.Errorf("Expected ErrMissingHeaders, got %v", err)
+		}
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		if err := v.Verify(signedRequest(t, "otherKey", "testSecret")); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Expected ErrUnknownKey, got %v", err)
+		}
+	})
+
+	t.Run("WrongSecret", func(t *testing.T) {
+		if err := v.Verify(signedRequest(t, "testKey", "otherSecret")); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("Expected ErrBadSignature, got %v", err)
+		}
+	})
+
+	t.Run("StaleTimestamp", func(t *testing.T) {
+		req := signedRequest(t, "testKey", "testSecret")
+		stale := NewVerifier(map[string]string{"testKey": "testSecret"})
+		stale.Now = func() time.Time { return time.Now().Add(10 * time.Minute) }
+		if err := stale.Verify(req); !errors.Is(err, ErrTimestampSkew) {
+			t.Errorf("Expected ErrTimestampSkew, got %v", err)
+		}
+	})
+
+	t.Run("TamperedTimestamp", func(t *testing.T) {
+		req := signedRequest(t, "testKey", "testSecret")
+		ts, _ := strconv.ParseInt(req.Header.Get("X-Timestamp"), 10, 64)
+		req.Header.Set("X-Timestamp", strconv.FormatInt(ts-1, 10))
+		if err := v.Verify(req); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("Expected ErrBadSignature, got %v", err)
+		}
+	})
+}
+
+// bodySignedRequest returns a POST request with body signed by
+// HMACAuthTransport with SignBody set, as received by a server.
+func bodySignedRequest(t *testing.T, body string) *http.Request {
+	t.Helper()
+	mockNext := &mockRoundTripper{}
+	req, _ := http.NewRequest("POST", "http://example.com/api/email-lookup", strings.NewReader(body))
+	transport := NewHMACAuthTransport("testKey", "testSecret", mockNext)
+	transport.SignBody = true
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	return mockNext.request
+}
+
+func TestVerifierBodySignature(t *testing.T) {
+	const body = `{"data":["user@example.com"]}`
+	v := NewVerifier(map[string]string{"testKey": "testSecret"})
+	v.RequireBodySignature = true
+
+	req := bodySignedRequest(t, body)
+	if got := req.Header.Get(SignatureVersionHeader); got != SignatureVersion2 {
+		t.Errorf("Expected %s %q, got %q", SignatureVersionHeader, SignatureVersion2, got)
+	}
+	if got := req.Header.Get(ContentHashHeader); len(got) != 64 {
+		t.Errorf("Unexpected %s %q", ContentHashHeader, got)
+	}
+	if err := v.Verify(req); err != nil {
+		t.Fatalf("Expected body-signed request to verify, got %v", err)
+	}
+	if got, _ := io.ReadAll(req.Body); string(got) != body {
+		t.Errorf("Expected the body to be readable after Verify, got %q", got)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(*http.Request)
+		want   error
+	}{
+		{"Body", func(r *http.Request) {
+			r.Body = io.NopCloser(strings.NewReader(`{"data":["other@example.com"]}`))
+		}, ErrBodyMismatch},
+		{"BodyAndHash", func(r *http.Request) {
+			other := bodySignedRequest(t, `{"data":["other@example.com"]}`)
+			r.Body = other.Body
+			r.Header.Set(ContentHashHeader, other.Header.Get(ContentHashHeader))
+		}, ErrBadSignature},
+		{"Path", func(r *http.Request) { r.URL.Path = "/api/ip-lookup" }, ErrBadSignature},
+		{"Method", func(r *http.Request) { r.Method = "PUT" }, ErrBadSignature},
+		{"Version", func(r *http.Request) { r.Header.Set(SignatureVersionHeader, "3") }, ErrUnsupportedVersion},
+		{"Downgrade", func(r *http.Request) { r.Header.Del(SignatureVersionHeader) }, ErrSignatureRequired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := bodySignedRequest(t, body)
+			tt.tamper(req)
+			if err := v.Verify(req); !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	t.Run("TooLarge", func(t *testing.T) {
+		small := NewVerifier(map[string]string{"testKey": "testSecret"})
+		small.MaxBodySize = int64(len(body)) - 1
+		if err := small.Verify(bodySignedRequest(t, body)); !errors.Is(err, ErrBodyTooLarge) {
+			t.Errorf("Expected ErrBodyTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("LegacyRequired", func(t *testing.T) {
+		if err := v.Verify(signedRequest(t, "testKey", "testSecret")); !errors.Is(err, ErrSignatureRequired) {
+			t.Errorf("Expected ErrSignatureRequired, got %v", err)
+		}
+	})
+}
+
+func TestVerifierMiddleware(t *testing.T) {
+	v := NewVerifier(map[string]string{"testKey": "testSecret"})
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest(t, "testKey", "testSecret"))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Expected signed request to pass, got status %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/ip-lookup", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected unsigned request to be rejected, got status %d", rec.Code)
+	}
+}
@@ -0,0 +1,360 @@
+// Package breaker provides circuit breakers for the Cerberius operations.
+//
+// A Breaker watches the outcome and latency of the calls it lets through.
+// When the error rate or the share of slow calls in its rolling window
+// crosses a threshold, it opens and rejects calls without sending them
+// until OpenTimeout has passed. It then lets a few trial calls through
+// (half-open) and closes again if they all succeed.
+//
+// Client wraps an operations.ClientService with one Breaker per operation:
+//
+//	svc := breaker.New(apiClient.Operations, breaker.Options{
+//		Fallbacks: breaker.Fallbacks{IPLookup: breaker.NeutralIPLookup},
+//	})
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Defaults for the zero fields of Settings.
+const (
+	DefaultWindow           = 30 * time.Second
+	DefaultMinRequests      = 10
+	DefaultErrorRate        = 0.5
+	DefaultOpenTimeout      = 30 * time.Second
+	DefaultHalfOpenRequests = 1
+)
+
+// windowBuckets is the number of buckets of a rolling window.
+const windowBuckets = 10
+
+// ErrOpen matches every *OpenError with errors.Is.
+var ErrOpen = errors.New("breaker: circuit open")
+
+// OpenError is returned for calls rejected by an open breaker.
+type OpenError struct {
+	Operation  string        // Operation is the ID of the rejected operation.
+	State      State         // State is StateOpen, or StateHalfOpen if all trial calls were taken.
+	RetryAfter time.Duration // RetryAfter is the time until the breaker lets trial calls through.
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("breaker: circuit %s for %s, retry after %s", e.State, e.Operation, e.RetryAfter)
+}
+
+// Is reports whether target is ErrOpen.
+func (e *OpenError) Is(target error) bool {
+	return target == ErrOpen
+}
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	// StateClosed lets every call through.
+	StateClosed State = iota
+	// StateHalfOpen lets a limited number of trial calls through.
+	StateHalfOpen
+	// StateOpen rejects every call.
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half-open"
+	case StateOpen:
+		return "open"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Outcome is the outcome of a call let through by a Breaker.
+type Outcome int
+
+const (
+	// OutcomeSuccess is a call that succeeded.
+	OutcomeSuccess Outcome = iota
+	// OutcomeFailure is a call that failed.
+	OutcomeFailure
+	// OutcomeIgnored is a call that tells nothing about the health of the
+	// operation, such as a cancelled one. It is not counted, and a trial
+	// call it took while half open is given back.
+	OutcomeIgnored
+)
+
+// Settings are the thresholds of a Breaker.
+type Settings struct {
+	// Window is the length of the rolling window the rates are computed
+	// over, DefaultWindow if zero. It is raised to one nanosecond per
+	// bucket if shorter.
+	Window time.Duration
+
+	// MinRequests is the number of calls in the window below which the
+	// breaker never opens, DefaultMinRequests if zero.
+	MinRequests int
+
+	// ErrorRate is the share of failed calls in the window, between 0 and
+	// 1, at which the breaker opens. DefaultErrorRate if zero.
+	ErrorRate float64
+
+	// SlowCall is the duration above which a call counts as slow. Slow
+	// calls are not tracked if zero.
+	SlowCall time.Duration
+
+	// SlowCallRate is the share of slow calls in the window, between 0 and
+	// 1, at which the breaker opens. Slow calls alone never open the
+	// breaker if zero.
+	SlowCallRate float64
+
+	// OpenTimeout is the time the breaker stays open before letting trial
+	// calls through, DefaultOpenTimeout if zero.
+	OpenTimeout time.Duration
+
+	// HalfOpenRequests is the number of trial calls let through while half
+	// open, DefaultHalfOpenRequests if zero. The breaker closes once they
+	// all succeed and reopens on the first failure.
+	HalfOpenRequests int
+}
+
+// withDefaults returns s with its zero fields set to the defaults.
+func (s Settings) withDefaults() Settings {
+	if s.Window <= 0 {
+		s.Window = DefaultWindow
+	}
+	if s.Window < windowBuckets {
+		s.Window = windowBuckets
+	}
+	if s.MinRequests <= 0 {
+		s.MinRequests = DefaultMinRequests
+	}
+	if s.ErrorRate <= 0 {
+		s.ErrorRate = DefaultErrorRate
+	}
+	if s.OpenTimeout <= 0 {
+		s.OpenTimeout = DefaultOpenTimeout
+	}
+	if s.HalfOpenRequests <= 0 {
+		s.HalfOpenRequests = DefaultHalfOpenRequests
+	}
+	return s
+}
+
+// Counts are the calls recorded in a Breaker's rolling window.
+type Counts struct {
+	Requests int // Requests is the number of completed calls.
+	Failures int // Failures is the number of failed calls.
+	Slow     int // Slow is the number of calls slower than Settings.SlowCall.
+}
+
+func (c *Counts) add(o Counts) {
+	c.Requests += o.Requests
+	c.Failures += o.Failures
+	c.Slow += o.Slow
+}
+
+// bucket holds the counts of one slice of the rolling window.
+type bucket struct {
+	start time.Time
+	Counts
+}
+
+// Breaker is a circuit breaker for a single operation. It is safe for
+// concurrent use.
+type Breaker struct {
+	name     string
+	settings Settings
+	onChange func(name string, from, to State)
+	now      func() time.Time
+
+	mu         sync.Mutex
+	state      State
+	openedAt   time.Time
+	buckets    [windowBuckets]bucket
+	trials     int    // trials is the number of trial calls let through while half open.
+	trialsDone int    // trialsDone is the number of trial calls that succeeded.
+	generation uint64 // generation is incremented on every state change.
+}
+
+// NewBreaker creates a new closed Breaker. onChange, if set, is called
+// with the breaker's name on every state change; now returns the current
+// time and is time.Now if nil.
+func NewBreaker(name string, settings Settings, onChange func(name string, from, to State), now func() time.Time) *Breaker {
+	if now == nil {
+		now = time.Now
+	}
+	return &Breaker{name: name, settings: settings.withDefaults(), onChange: onChange, now: now}
+}
+
+// Name returns the name of the breaker.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	state, changed := b.refresh(b.now())
+	b.mu.Unlock()
+	b.notify(changed)
+	return state
+}
+
+// Counts returns the calls recorded in the current window.
+func (b *Breaker) Counts() Counts {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.total(b.now())
+}
+
+// Allow reports whether a call may proceed. If it may, done must be called
+// with the call's outcome; otherwise Allow returns an *OpenError.
+func (b *Breaker) Allow() (done func(Outcome), err error) {
+	b.mu.Lock()
+	now := b.now()
+	state, changed := b.refresh(now)
+	switch {
+	case state == StateOpen:
+		err = &OpenError{Operation: b.name, State: state, RetryAfter: b.openedAt.Add(b.settings.OpenTimeout).Sub(now)}
+	case state == StateHalfOpen && b.trials >= b.settings.HalfOpenRequests:
+		err = &OpenError{Operation: b.name, State: state}
+	case state == StateHalfOpen:
+		b.trials++
+	}
+	generation := b.generation
+	b.mu.Unlock()
+	b.notify(changed)
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func(outcome Outcome) {
+		once.Do(func() { b.record(generation, now, outcome) })
+	}, nil
+}
+
+// record records the outcome of a call started at start in generation.
+// Outcomes of calls started before the last state change are ignored.
+func (b *Breaker) record(generation uint64, start time.Time, outcome Outcome) {
+	b.mu.Lock()
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+	now := b.now()
+	failed := outcome == OutcomeFailure
+	slow := b.settings.SlowCall > 0 && now.Sub(start) > b.settings.SlowCall
+	var changed []transition
+
+	switch {
+	case outcome == OutcomeIgnored:
+		if b.state == StateHalfOpen {
+			b.trials--
+		}
+	case b.state == StateHalfOpen:
+		if failed || slow {
+			changed = append(changed, b.setState(StateOpen, now))
+		} else if b.trialsDone++; b.trialsDone >= b.settings.HalfOpenRequests {
+			changed = append(changed, b.setState(StateClosed, now))
+		}
+	case b.state == StateClosed:
+		c := b.current(now)
+		c.Requests++
+		if failed {
+			c.Failures++
+		}
+		if slow {
+			c.Slow++
+		}
+		if b.tripped(b.total(now)) {
+			changed = append(changed, b.setState(StateOpen, now))
+		}
+	}
+	b.mu.Unlock()
+	b.notify(changed)
+}
+
+// tripped reports whether counts cross the thresholds.
+func (b *Breaker) tripped(c Counts) bool {
+	if c.Requests < b.settings.MinRequests {
+		return false
+	}
+	if float64(c.Failures) >= b.settings.ErrorRate*float64(c.Requests) {
+		return true
+	}
+	return b.settings.SlowCallRate > 0 && float64(c.Slow) >= b.settings.SlowCallRate*float64(c.Requests)
+}
+
+// transition is a state change to report to onChange.
+type transition struct {
+	from, to State
+}
+
+// refresh moves an open breaker to half open once its timeout has passed
+// and returns the current state. b.mu must be held.
+func (b *Breaker) refresh(now time.Time) (State, []transition) {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.settings.OpenTimeout {
+		return StateHalfOpen, []transition{b.setState(StateHalfOpen, now)}
+	}
+	return b.state, nil
+}
+
+// setState changes the state and resets the counts. b.mu must be held.
+func (b *Breaker) setState(to State, now time.Time) transition {
+	t := transition{from: b.state, to: to}
+	b.state = to
+	b.generation++
+	b.trials, b.trialsDone = 0, 0
+	b.buckets = [windowBuckets]bucket{}
+	if to == StateOpen {
+		b.openedAt = now
+	}
+	return t
+}
+
+// notify calls onChange for the transitions. b.mu must not be held.
+func (b *Breaker) notify(changed []transition) {
+	if b.onChange == nil {
+		return
+	}
+	for _, t := range changed {
+		b.onChange(b.name, t.from, t.to)
+	}
+}
+
+// bucketSize returns the length of a window bucket.
+func (b *Breaker) bucketSize() time.Duration {
+	return b.settings.Window / windowBuckets
+}
+
+// current returns the bucket for now, resetting it if it is stale.
+// b.mu must be held.
+func (b *Breaker) current(now time.Time) *Counts {
+	size := b.bucketSize()
+	start := now.Truncate(size)
+	bk := &b.buckets[(start.UnixNano()/int64(size))%windowBuckets]
+	if !bk.start.Equal(start) {
+		*bk = bucket{start: start}
+	}
+	return &bk.Counts
+}
+
+// total returns the sum of the buckets within the window ending at now.
+// b.mu must be held.
+func (b *Breaker) total(now time.Time) Counts {
+	var c Counts
+	oldest := now.Truncate(b.bucketSize()).Add(-b.settings.Window)
+	for _, bk := range b.buckets {
+		if bk.start.After(oldest) {
+			c.add(bk.Counts)
+		}
+	}
+	return c
+}
@@ -0,0 +1,310 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"cerberius.com/go-client/fake"
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+	"cerberius.com/go-client/tenant"
+	"cerberius.com/go-client/validation"
+
+	"github.com/go-openapi/runtime"
+)
+
+// clock is a manually advanced time source.
+type clock struct{ now time.Time }
+
+func (c *clock) Now() time.Time          { return c.now }
+func (c *clock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newClock() *clock { return &clock{now: time.Unix(1700000000, 0)} }
+
+func ipParams(ips ...string) *operations.IPLookupRequestDataParams {
+	return operations.NewIPLookupRequestDataParams().WithBody(&models.IPLookupRequest{Data: ips})
+}
+
+func TestBreakerLifecycle(t *testing.T) {
+	clk := newClock()
+	var changes []string
+	svc := fake.New()
+	failing := true
+	svc.IPLookupRequestDataFunc = func(params *operations.IPLookupRequestDataParams, _ ...operations.ClientOption) (*operations.IPLookupRequestDataOK, error) {
+		if failing {
+			return nil, operations.NewIPLookupRequestDataDefault(503)
+		}
+		return &operations.IPLookupRequestDataOK{Payload: &models.IPLookupResponse{}}, nil
+	}
+
+	c := New(svc, Options{
+		Settings: Settings{MinRequests: 4, ErrorRate: 0.5, OpenTimeout: time.Minute},
+		Now:      clk.Now,
+		OnStateChange: func(op string, from, to State) {
+			changes = append(changes, fmt.Sprintf("%s:%s->%s", op, from, to))
+		},
+	})
+
+	for i := 0; i < 4; i++ {
+		if _, err := c.IPLookupRequestData(ipParams("8.8.8.8")); errors.Is(err, ErrOpen) {
+			t.Fatalf("Call %d rejected before the threshold", i)
+		}
+	}
+	if got := c.Breaker(OperationIPLookup).State(); got != StateOpen {
+		t.Fatalf("Expected open breaker, got %s", got)
+	}
+
+	// Open: calls fail fast without reaching the service.
+	_, err := c.IPLookupRequestData(ipParams("8.8.8.8"))
+	var openErr *OpenError
+	if !errors.As(err, &openErr) || openErr.Operation != OperationIPLookup || openErr.RetryAfter != time.Minute {
+		t.Fatalf("Expected *OpenError, got %v", err)
+	}
+	svc.AssertIPLookupRequestDataCalled(t, 4)
+
+	// Other operations have their own breaker.
+	if got := c.Breaker(OperationEmailValidation).State(); got != StateClosed {
+		t.Errorf("Expected email breaker to stay closed, got %s", got)
+	}
+
+	// Half open: a failed trial reopens.
+	clk.Advance(time.Minute)
+	c.IPLookupRequestData(ipParams("8.8.8.8"))
+	if got := c.Breaker(OperationIPLookup).State(); got != StateOpen {
+		t.Fatalf("Expected failed trial to reopen, got %s", got)
+	}
+
+	// Half open: a successful trial closes.
+	clk.Advance(time.Minute)
+	failing = false
+	if _, err := c.IPLookupRequestData(ipParams("8.8.8.8")); err != nil {
+		t.Fatalf("Trial call failed: %v", err)
+	}
+	if got := c.Breaker(OperationIPLookup).State(); got != StateClosed {
+		t.Fatalf("Expected successful trial to close, got %s", got)
+	}
+
+	want := []string{
+		"ipLookupRequestData:closed->open",
+		"ipLookupRequestData:open->half-open",
+		"ipLookupRequestData:half-open->open",
+		"ipLookupRequestData:open->half-open",
+		"ipLookupRequestData:half-open->closed",
+	}
+	if fmt.Sprint(changes) != fmt.Sprint(want) {
+		t.Errorf("Expected changes %v, got %v", want, changes)
+	}
+}
+
+func TestBreakerHalfOpenLimitsTrials(t *testing.T) {
+	clk := newClock()
+	b := NewBreaker("op", Settings{MinRequests: 1, OpenTimeout: time.Second, HalfOpenRequests: 2}, nil, clk.Now)
+
+	done, _ := b.Allow()
+	done(OutcomeFailure)
+	clk.Advance(time.Second)
+
+	first, err1 := b.Allow()
+	second, err2 := b.Allow()
+	if err1 != nil || err2 != nil {
+		t.Fatalf("Expected 2 trial calls, got %v, %v", err1, err2)
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Fatalf("Expected a third trial to be rejected, got %v", err)
+	}
+	first(OutcomeSuccess)
+	if b.State() != StateHalfOpen {
+		t.Fatal("Expected the breaker to wait for all trials")
+	}
+	second(OutcomeSuccess)
+	if b.State() != StateClosed {
+		t.Fatal("Expected the breaker to close")
+	}
+}
+
+func TestBreakerIgnoresCancelledTrials(t *testing.T) {
+	clk := newClock()
+	b := NewBreaker("op", Settings{MinRequests: 1, OpenTimeout: time.Second}, nil, clk.Now)
+
+	done, _ := b.Allow()
+	done(OutcomeFailure)
+	clk.Advance(time.Second)
+
+	trial, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	trial(OutcomeIgnored)
+	if b.State() != StateHalfOpen {
+		t.Fatal("Expected a cancelled trial not to close the breaker")
+	}
+	// The trial is given back.
+	if _, err := b.Allow(); err != nil {
+		t.Errorf("Expected another trial call, got %v", err)
+	}
+}
+
+func TestBreakerIgnoresStaleTrials(t *testing.T) {
+	clk := newClock()
+	b := NewBreaker("op", Settings{MinRequests: 1, OpenTimeout: time.Second, HalfOpenRequests: 2}, nil, clk.Now)
+
+	done, _ := b.Allow()
+	done(OutcomeFailure)
+	clk.Advance(time.Second)
+	stale, _ := b.Allow()
+	failing, _ := b.Allow()
+	failing(OutcomeFailure)
+	clk.Advance(time.Second)
+
+	// The breaker is half open again; the trial of the earlier period must
+	// neither give back nor decide a trial of this one.
+	first, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale(OutcomeIgnored)
+	second, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Allow(); !errors.Is(err, ErrOpen) {
+		t.Errorf("Expected a third trial to be rejected, got %v", err)
+	}
+	first(OutcomeSuccess)
+	second(OutcomeSuccess)
+	if b.State() != StateClosed {
+		t.Error("Expected the trials of this period to close the breaker")
+	}
+}
+
+func TestBreakerTinyWindow(t *testing.T) {
+	clk := newClock()
+	b := NewBreaker("op", Settings{Window: time.Nanosecond, MinRequests: 1}, nil, clk.Now)
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done(OutcomeFailure)
+	if b.State() != StateOpen {
+		t.Error("Expected the breaker to open")
+	}
+}
+
+func TestIsFailure(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{context.Canceled, false},
+		{context.DeadlineExceeded, true},
+		{&url.Error{Op: "Post", URL: "https://api.example", Err: syscall.ECONNREFUSED}, true},
+		{fmt.Errorf("reading response: %w", io.ErrUnexpectedEOF), true},
+		{runtime.NewAPIError("unknown error", nil, 503), true},
+		{runtime.NewAPIError("unknown error", nil, 429), true},
+		{runtime.NewAPIError("unknown error", nil, 400), false},
+		{&validation.Error{Operation: OperationIPLookup}, false},
+		{fmt.Errorf("%w: tenant %q", tenant.ErrBudgetExceeded, "acme"), false},
+		{errors.New("json: cannot unmarshal"), false},
+	} {
+		if got := IsFailure(tt.err); got != tt.want {
+			t.Errorf("IsFailure(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestBreakerOnChangeMayInspectBreaker(t *testing.T) {
+	clk := newClock()
+	var b *Breaker
+	var seen []State
+	b = NewBreaker("op", Settings{MinRequests: 1, OpenTimeout: time.Second}, func(_ string, _, to State) {
+		// Calls back into the breaker must not deadlock.
+		seen = append(seen, b.State())
+		b.Counts()
+	}, clk.Now)
+
+	done, _ := b.Allow()
+	done(OutcomeFailure)
+	clk.Advance(time.Second)
+	if got := b.State(); got != StateHalfOpen {
+		t.Fatalf("Expected half-open breaker, got %s", got)
+	}
+	if len(seen) != 2 || seen[0] != StateOpen || seen[1] != StateHalfOpen {
+		t.Errorf("Unexpected states seen by onChange %v", seen)
+	}
+}
+
+func TestBreakerSlowCalls(t *testing.T) {
+	clk := newClock()
+	b := NewBreaker("op", Settings{MinRequests: 2, SlowCall: 100 * time.Millisecond, SlowCallRate: 1}, nil, clk.Now)
+
+	for i := 0; i < 2; i++ {
+		done, err := b.Allow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		clk.Advance(200 * time.Millisecond)
+		done(OutcomeSuccess)
+	}
+	if b.State() != StateOpen {
+		t.Fatalf("Expected slow calls to open the breaker, counts %+v", b.Counts())
+	}
+}
+
+func TestBreakerWindowExpires(t *testing.T) {
+	clk := newClock()
+	b := NewBreaker("op", Settings{Window: 10 * time.Second, MinRequests: 3}, nil, clk.Now)
+
+	for i := 0; i < 2; i++ {
+		done, _ := b.Allow()
+		done(OutcomeFailure)
+	}
+	clk.Advance(11 * time.Second)
+	if c := b.Counts(); c.Requests != 0 {
+		t.Fatalf("Expected an empty window, got %+v", c)
+	}
+	done, _ := b.Allow()
+	done(OutcomeFailure)
+	if b.State() != StateClosed {
+		t.Fatal("Expected failures outside the window to be forgotten")
+	}
+}
+
+func TestClientFallbackAndClassification(t *testing.T) {
+	clk := newClock()
+	svc := fake.New().
+		FailIPLookupRequestData(422, 100422, "Invalid input").
+		ErrorIPLookupRequestData(context.Canceled).
+		ErrorIPLookupRequestData(&url.Error{Op: "Post", URL: "https://api.example", Err: syscall.ECONNRESET})
+	c := New(svc, Options{
+		Settings:  Settings{MinRequests: 1, ErrorRate: 0.3},
+		Fallbacks: Fallbacks{IPLookup: NeutralIPLookup},
+		Now:       clk.Now,
+	})
+
+	// Client errors and cancellations do not open the breaker.
+	c.IPLookupRequestData(ipParams("8.8.8.8"))
+	c.IPLookupRequestData(ipParams("8.8.8.8"))
+	if got := c.Breaker(OperationIPLookup).State(); got != StateClosed {
+		t.Fatalf("Expected closed breaker, got %s", got)
+	}
+	c.IPLookupRequestData(ipParams("8.8.8.8"))
+	if got := c.Breaker(OperationIPLookup).State(); got != StateOpen {
+		t.Fatalf("Expected open breaker, got %s", got)
+	}
+
+	resp, err := c.IPLookupRequestData(ipParams("1.1.1.1", "9.9.9.9"))
+	if err != nil {
+		t.Fatalf("Expected the fallback to answer, got %v", err)
+	}
+	if len(resp.Payload.Data) != 2 || resp.Payload.Data[1].IPAddress != "9.9.9.9" {
+		t.Errorf("Unexpected fallback payload %+v", resp.Payload.Data)
+	}
+	svc.AssertIPLookupRequestDataCalled(t, 3)
+}
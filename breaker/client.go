@@ -0,0 +1,207 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+
+	"github.com/go-openapi/runtime"
+)
+
+// Operation IDs of the breakers of a Client.
+const (
+	OperationEmailValidation = "emailValidationRequestData"
+	OperationIPLookup        = "ipLookupRequestData"
+	OperationPromptCheck     = "promptCheckRequestData"
+)
+
+// Fallbacks are called instead of an operation rejected by an open
+// breaker, with the *OpenError. A nil fallback returns the error.
+type Fallbacks struct {
+	EmailValidation func(params *operations.EmailValidationRequestDataParams, err error) (*operations.EmailValidationRequestDataOK, error)
+	IPLookup        func(params *operations.IPLookupRequestDataParams, err error) (*operations.IPLookupRequestDataOK, error)
+	PromptCheck     func(params *operations.PromptCheckRequestDataParams, err error) (*operations.PromptCheckRequestDataOK, error)
+}
+
+// NeutralIPLookup is an IPLookup fallback that returns an IPData without
+// any findings for every requested address.
+func NeutralIPLookup(params *operations.IPLookupRequestDataParams, _ error) (*operations.IPLookupRequestDataOK, error) {
+	payload := &models.IPLookupResponse{}
+	if params.Body != nil {
+		for _, ip := range params.Body.Data {
+			payload.Data = append(payload.Data, &models.IPData{IPAddress: ip})
+		}
+	}
+	return &operations.IPLookupRequestDataOK{Payload: payload}, nil
+}
+
+// Options configures a Client.
+type Options struct {
+	// Settings are the thresholds of every breaker.
+	Settings Settings
+
+	// Operations overrides Settings for individual operations, keyed by
+	// operation ID.
+	Operations map[string]Settings
+
+	// Fallbacks are called for rejected operations.
+	Fallbacks Fallbacks
+
+	// OnStateChange, if set, is called with the operation ID on every
+	// state change of a breaker.
+	OnStateChange func(operation string, from, to State)
+
+	// IsFailure reports whether an operation error counts against the
+	// breaker, IsFailure if nil.
+	IsFailure func(err error) bool
+
+	// Now returns the current time, time.Now if nil.
+	Now func() time.Time
+}
+
+// IsFailure is the default failure classifier. Network errors, timeouts
+// and 408, 429 and 5xx responses count as failures. Every other error,
+// such as a cancelled call, a client error or one raised before the
+// request was sent, is caused by the call rather than by the service and
+// does not.
+func IsFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	if code, ok := statusCode(err); ok {
+		return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// statusCode returns the HTTP status code of an API error.
+func statusCode(err error) (int, bool) {
+	var coded interface{ Code() int }
+	if errors.As(err, &coded) {
+		return coded.Code(), true
+	}
+	var apiErr *runtime.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code, true
+	}
+	return 0, false
+}
+
+// outcome classifies the error of an operation. Cancelled calls are
+// ignored, so that they neither count in the window nor pass as a trial.
+func (c *Client) outcome(err error) Outcome {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return OutcomeIgnored
+	case c.isFailure(err):
+		return OutcomeFailure
+	}
+	return OutcomeSuccess
+}
+
+// Client is an operations.ClientService that sends every operation through
+// the breaker of its operation.
+type Client struct {
+	next      operations.ClientService
+	fallbacks Fallbacks
+	isFailure func(error) bool
+
+	emailValidation *Breaker
+	ipLookup        *Breaker
+	promptCheck     *Breaker
+}
+
+var _ operations.ClientService = (*Client)(nil)
+
+// New creates a new Client that wraps next.
+func New(next operations.ClientService, opts Options) *Client {
+	if opts.IsFailure == nil {
+		opts.IsFailure = IsFailure
+	}
+	newBreaker := func(id string) *Breaker {
+		settings, ok := opts.Operations[id]
+		if !ok {
+			settings = opts.Settings
+		}
+		return NewBreaker(id, settings, opts.OnStateChange, opts.Now)
+	}
+	return &Client{
+		next:            next,
+		fallbacks:       opts.Fallbacks,
+		isFailure:       opts.IsFailure,
+		emailValidation: newBreaker(OperationEmailValidation),
+		ipLookup:        newBreaker(OperationIPLookup),
+		promptCheck:     newBreaker(OperationPromptCheck),
+	}
+}
+
+// Breaker returns the breaker of an operation ID, or nil if the ID is
+// unknown.
+func (c *Client) Breaker(operation string) *Breaker {
+	switch operation {
+	case OperationEmailValidation:
+		return c.emailValidation
+	case OperationIPLookup:
+		return c.ipLookup
+	case OperationPromptCheck:
+		return c.promptCheck
+	}
+	return nil
+}
+
+// EmailValidationRequestData implements operations.ClientService.
+func (c *Client) EmailValidationRequestData(params *operations.EmailValidationRequestDataParams, opts ...operations.ClientOption) (*operations.EmailValidationRequestDataOK, error) {
+	done, err := c.emailValidation.Allow()
+	if err != nil {
+		if c.fallbacks.EmailValidation != nil {
+			return c.fallbacks.EmailValidation(params, err)
+		}
+		return nil, err
+	}
+	resp, err := c.next.EmailValidationRequestData(params, opts...)
+	done(c.outcome(err))
+	return resp, err
+}
+
+// IPLookupRequestData implements operations.ClientService.
+func (c *Client) IPLookupRequestData(params *operations.IPLookupRequestDataParams, opts ...operations.ClientOption) (*operations.IPLookupRequestDataOK, error) {
+	done, err := c.ipLookup.Allow()
+	if err != nil {
+		if c.fallbacks.IPLookup != nil {
+			return c.fallbacks.IPLookup(params, err)
+		}
+		return nil, err
+	}
+	resp, err := c.next.IPLookupRequestData(params, opts...)
+	done(c.outcome(err))
+	return resp, err
+}
+
+// PromptCheckRequestData implements operations.ClientService.
+func (c *Client) PromptCheckRequestData(params *operations.PromptCheckRequestDataParams, opts ...operations.ClientOption) (*operations.PromptCheckRequestDataOK, error) {
+	done, err := c.promptCheck.Allow()
+	if err != nil {
+		if c.fallbacks.PromptCheck != nil {
+			return c.fallbacks.PromptCheck(params, err)
+		}
+		return nil, err
+	}
+	resp, err := c.next.PromptCheckRequestData(params, opts...)
+	done(c.outcome(err))
+	return resp, err
+}
+
+// SetTransport implements operations.ClientService.
+func (c *Client) SetTransport(transport runtime.ClientTransport) {
+	c.next.SetTransport(transport)
+}
@@ -0,0 +1,397 @@
+package goclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"cerberius.com/go-client/auth"
+	"cerberius.com/go-client/generated/client"
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+	"cerberius.com/go-client/internal/ratelimit"
+	"cerberius.com/go-client/validation"
+
+	"github.com/go-openapi/runtime"
+	httptransport "github.com/go-openapi/runtime/client"
+	"github.com/go-openapi/strfmt"
+)
+
+// Defaults for the zero fields of Config.
+const (
+	DefaultDeadline     = 10 * time.Second
+	DefaultMaxRetries   = 2
+	DefaultRetryBackoff = 200 * time.Millisecond
+	DefaultBatchSize    = validation.DefaultMaxBatchSize
+)
+
+// maxRetryBackoff caps the exponential retry backoff.
+const maxRetryBackoff = 5 * time.Second
+
+// ErrMissingCredentials is returned by New when neither credentials nor a
+// Service are configured.
+var ErrMissingCredentials = errors.New("goclient: API key and secret are required")
+
+// Deadlines are the default deadlines of the operations, applied to calls
+// whose context has no deadline of its own. A deadline covers a single API
+// request including its retries; DefaultDeadline is used for zero fields.
+type Deadlines struct {
+	EmailValidation time.Duration
+	IPLookup        time.Duration
+	PromptCheck     time.Duration
+}
+
+// Config configures a Client.
+type Config struct {
+	APIKey    string // APIKey is the Cerberus API key.
+	APISecret string // APISecret is the Cerberus API secret.
+	SignBody  bool   // SignBody enables body signing, see auth.HMACAuthTransport.
+
+	// Host, BasePath and Schemes locate the API, client.DefaultHost,
+	// client.DefaultBasePath and client.DefaultSchemes if empty.
+	Host     string
+	BasePath string
+	Schemes  []string
+
+	// Transport is the underlying round tripper, http.DefaultTransport if
+	// nil.
+	Transport http.RoundTripper
+
+	// Service, if set, is used to send operations instead of a client
+	// built from the fields above, for example a decorated or fake
+	// operations.ClientService.
+	Service operations.ClientService
+
+	// Deadlines are the per-operation default deadlines.
+	Deadlines Deadlines
+
+	// MaxRetries is the number of retries of a failed request,
+	// DefaultMaxRetries if zero. Retries are disabled if negative. Only
+	// Retryable errors are retried.
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first retry, doubled for every
+	// further retry. DefaultRetryBackoff if zero.
+	RetryBackoff time.Duration
+
+	// RateLimit is the number of requests per second, unlimited if zero,
+	// with bursts of up to RateBurst requests.
+	RateLimit float64
+	RateBurst int
+
+	// BatchSize is the number of items per request, DefaultBatchSize if
+	// zero.
+	BatchSize int
+
+	// Concurrency is the number of batches sent in parallel, 1 if zero.
+	Concurrency int
+}
+
+// Client is a context-first client for the Cerberius API. Every method
+// takes a context as its first argument: the context's deadline bounds the
+// whole call, including batching, retries and rate limit waits, and is
+// passed down as the HTTP timeout; cancelling it stops the call. Calls
+// without a deadline get the operation's default deadline from
+// Config.Deadlines.
+//
+// A Client is safe for concurrent use.
+type Client struct {
+	svc       operations.ClientService
+	deadlines Deadlines
+	retries   int
+	backoff   time.Duration
+	limiter   *ratelimit.Limiter
+	batchSize int
+	workers   int
+}
+
+// New creates a new Client.
+func New(cfg Config) (*Client, error) {
+	svc := cfg.Service
+	if svc == nil {
+		if cfg.APIKey == "" || cfg.APISecret == "" {
+			return nil, ErrMissingCredentials
+		}
+		if cfg.Host == "" {
+			cfg.Host = client.DefaultHost
+		}
+		if cfg.BasePath == "" {
+			cfg.BasePath = client.DefaultBasePath
+		}
+		if len(cfg.Schemes) == 0 {
+			cfg.Schemes = client.DefaultSchemes
+		}
+		signer := auth.NewHMACAuthTransport(cfg.APIKey, cfg.APISecret, cfg.Transport)
+		signer.SignBody = cfg.SignBody
+		transport := httptransport.NewWithClient(cfg.Host, cfg.BasePath, cfg.Schemes, &http.Client{Transport: signer})
+		svc = operations.New(transport, strfmt.Default)
+	}
+
+	d := &cfg.Deadlines
+	for _, v := range []*time.Duration{&d.EmailValidation, &d.IPLookup, &d.PromptCheck} {
+		if *v <= 0 {
+			*v = DefaultDeadline
+		}
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DefaultMaxRetries
+	} else if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = DefaultRetryBackoff
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+
+	return &Client{
+		svc:       svc,
+		deadlines: cfg.Deadlines,
+		retries:   cfg.MaxRetries,
+		backoff:   cfg.RetryBackoff,
+		limiter:   ratelimit.New(cfg.RateLimit, cfg.RateBurst),
+		batchSize: cfg.BatchSize,
+		workers:   cfg.Concurrency,
+	}, nil
+}
+
+// Operations returns the operations.ClientService used by the client.
+func (c *Client) Operations() operations.ClientService {
+	return c.svc
+}
+
+// LookupIPs looks up ips in batches and returns the results in input
+// order. The first failing batch cancels the others and its error is
+// returned.
+func (c *Client) LookupIPs(ctx context.Context, ips []string) ([]*models.IPData, error) {
+	return runBatches(ctx, c, ips, c.lookupIPBatch)
+}
+
+// ValidateEmails validates emails in batches and returns the results in
+// input order. The first failing batch cancels the others and its error is
+// returned.
+func (c *Client) ValidateEmails(ctx context.Context, emails []string) ([]*models.EmailData, error) {
+	return runBatches(ctx, c, emails, c.validateEmailBatch)
+}
+
+// CheckPrompt checks a prompt for injection attempts.
+func (c *Client) CheckPrompt(ctx context.Context, prompt string) (*models.PromptGuardData, error) {
+	return call(ctx, c, c.deadlines.PromptCheck, func(ctx context.Context, timeout time.Duration) (*models.PromptGuardData, error) {
+		params := operations.NewPromptCheckRequestDataParamsWithContext(ctx).
+			WithTimeout(timeout).
+			WithBody(&models.PromptGuardRequest{Data: &models.Prompt{Prompt: prompt}})
+		resp, err := c.svc.PromptCheckRequestData(params)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Payload == nil {
+			return nil, nil
+		}
+		return resp.Payload.Data, nil
+	})
+}
+
+// lookupIPBatch sends a single IP lookup.
+func (c *Client) lookupIPBatch(ctx context.Context, ips []string) ([]*models.IPData, error) {
+	return call(ctx, c, c.deadlines.IPLookup, func(ctx context.Context, timeout time.Duration) ([]*models.IPData, error) {
+		params := operations.NewIPLookupRequestDataParamsWithContext(ctx).
+			WithTimeout(timeout).
+			WithBody(&models.IPLookupRequest{Data: ips})
+		resp, err := c.svc.IPLookupRequestData(params)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Payload == nil {
+			return nil, nil
+		}
+		return resp.Payload.Data, nil
+	})
+}
+
+// validateEmailBatch sends a single email validation.
+func (c *Client) validateEmailBatch(ctx context.Context, emails []string) ([]*models.EmailData, error) {
+	return call(ctx, c, c.deadlines.EmailValidation, func(ctx context.Context, timeout time.Duration) ([]*models.EmailData, error) {
+		params := operations.NewEmailValidationRequestDataParamsWithContext(ctx).
+			WithTimeout(timeout).
+			WithBody(&models.EmailLookupRequest{Data: emails})
+		resp, err := c.svc.EmailValidationRequestData(params)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Payload == nil {
+			return nil, nil
+		}
+		return resp.Payload.Data, nil
+	})
+}
+
+// call runs a single API request with deadline, rate limiting and retries.
+// The request is passed the context and the HTTP timeout derived from its
+// deadline.
+func call[T any](ctx context.Context, c *Client, deadline time.Duration, request func(ctx context.Context, timeout time.Duration) (T, error)) (T, error) {
+	var zero T
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
+
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		if _, err := c.limiter.Wait(ctx); err != nil {
+			return zero, err
+		}
+		timeout, err := remaining(ctx)
+		if err != nil {
+			return zero, err
+		}
+
+		result, err := request(ctx, timeout)
+		if err == nil || attempt >= c.retries || !Retryable(err) {
+			return result, err
+		}
+		if ctx.Err() != nil {
+			return zero, err
+		}
+
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		if deadline, _ := ctx.Deadline(); time.Until(deadline) < wait {
+			// The retry could not complete in time.
+			return zero, err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return zero, err
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// remaining returns the time left until the deadline of ctx, which is used
+// as the HTTP timeout of a request.
+func remaining(ctx context.Context) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	deadline, _ := ctx.Deadline()
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		return 0, context.DeadlineExceeded
+	}
+	return timeout, nil
+}
+
+// Retryable reports whether a failed request may succeed when retried:
+// network errors, truncated responses and 408, 429 and 5xx responses are
+// retryable. Context errors, other API errors and errors raised before a
+// request was sent, such as validation errors or an open breaker, are
+// not.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var coded interface{ Code() int }
+	var apiErr *runtime.APIError
+	switch {
+	case errors.As(err, &coded):
+		return retryableStatus(coded.Code())
+	case errors.As(err, &apiErr):
+		return retryableStatus(apiErr.Code)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryableStatus reports whether a response with the HTTP status code
+// may succeed when retried.
+func retryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+// runBatches splits items into batches, runs them with the client's
+// concurrency and returns the concatenated results in input order.
+func runBatches[T any](ctx context.Context, c *Client, items []string, run func(ctx context.Context, batch []string) ([]T, error)) ([]T, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	n := (len(items) + c.batchSize - 1) / c.batchSize
+	results := make([][]T, n)
+	errs := make([]error, n)
+	work := make(chan int)
+	done := make(chan struct{})
+	workers := min(c.workers, n)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for i := range work {
+				start := i * c.batchSize
+				end := min(start+c.batchSize, len(items))
+				if results[i], errs[i] = run(ctx, items[start:end]); errs[i] != nil {
+					cancel()
+				}
+			}
+		}()
+	}
+
+	sent := 0
+feed:
+	for ; sent < n; sent++ {
+		select {
+		case work <- sent:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	for w := 0; w < workers; w++ {
+		<-done
+	}
+
+	// Report the first batch that failed on its own rather than one that
+	// was cancelled because of it.
+	var firstErr error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("goclient: batch %d of %d: %w", i+1, n, err)
+		if firstErr == nil {
+			firstErr = err
+		}
+		if !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if sent < n {
+		// The parent context ended before all batches were sent.
+		return nil, ctx.Err()
+	}
+
+	var all []T
+	for _, r := range results {
+		all = append(all, r...)
+	}
+	return all, nil
+}
@@ -0,0 +1,187 @@
+package goclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	goclient "cerberius.com/go-client"
+	"cerberius.com/go-client/breaker"
+	"cerberius.com/go-client/fake"
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+	"cerberius.com/go-client/validation"
+)
+
+// echoIPs answers IP lookups with one IPData per address.
+func echoIPs(params *operations.IPLookupRequestDataParams, _ ...operations.ClientOption) (*operations.IPLookupRequestDataOK, error) {
+	payload := &models.IPLookupResponse{}
+	for _, ip := range params.Body.Data {
+		payload.Data = append(payload.Data, &models.IPData{IPAddress: ip})
+	}
+	return &operations.IPLookupRequestDataOK{Payload: payload}, nil
+}
+
+func ips(n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+	}
+	return out
+}
+
+func TestClientBatchesInOrder(t *testing.T) {
+	svc := fake.New()
+	svc.IPLookupRequestDataFunc = echoIPs
+	c, err := goclient.New(goclient.Config{Service: svc, BatchSize: 3, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := ips(10)
+	got, err := c.LookupIPs(context.Background(), input)
+	if err != nil {
+		t.Fatalf("LookupIPs failed: %v", err)
+	}
+	if len(got) != len(input) {
+		t.Fatalf("Expected %d results, got %d", len(input), len(got))
+	}
+	for i, d := range got {
+		if d.IPAddress != input[i] {
+			t.Fatalf("Result %d is %s, want %s", i, d.IPAddress, input[i])
+		}
+	}
+	svc.AssertIPLookupRequestDataCalled(t, 4)
+}
+
+func TestClientDerivesTimeoutFromDeadline(t *testing.T) {
+	svc := fake.New()
+	var deadline time.Time
+	svc.IPLookupRequestDataFunc = func(params *operations.IPLookupRequestDataParams, opts ...operations.ClientOption) (*operations.IPLookupRequestDataOK, error) {
+		deadline, _ = params.Context.Deadline()
+		return echoIPs(params, opts...)
+	}
+	c, _ := goclient.New(goclient.Config{Service: svc, Deadlines: goclient.Deadlines{IPLookup: 200 * time.Millisecond}})
+
+	// The default deadline applies when the context has none.
+	start := time.Now()
+	c.LookupIPs(context.Background(), ips(1))
+	if d := deadline.Sub(start); d < 150*time.Millisecond || d > 250*time.Millisecond {
+		t.Errorf("Expected a 200ms default deadline, got %v", d)
+	}
+
+	// A shorter context deadline wins over the default.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	want, _ := ctx.Deadline()
+	c.LookupIPs(ctx, ips(1))
+	if !deadline.Equal(want) {
+		t.Errorf("Expected the context deadline %v, got %v", want, deadline)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	svc := fake.New().
+		FailIPLookupRequestData(503, 100503, "Service unavailable").
+		ErrorIPLookupRequestData(&url.Error{Op: "Post", URL: "https://api.example", Err: syscall.ECONNRESET}).
+		ReturnIPLookupRequestData(&models.IPLookupResponse{Data: []*models.IPData{{IPAddress: "8.8.8.8"}}})
+	c, _ := goclient.New(goclient.Config{Service: svc, RetryBackoff: time.Millisecond})
+
+	got, err := c.LookupIPs(context.Background(), []string{"8.8.8.8"})
+	if err != nil || len(got) != 1 {
+		t.Fatalf("Expected success after retries, got %v, %v", got, err)
+	}
+	svc.AssertIPLookupRequestDataCalled(t, 3)
+
+	// Client errors are not retried.
+	svc = fake.New().FailIPLookupRequestData(422, 100422, "Invalid input")
+	c, _ = goclient.New(goclient.Config{Service: svc, RetryBackoff: time.Millisecond})
+	if _, err := c.LookupIPs(context.Background(), []string{"bad"}); err == nil {
+		t.Fatal("Expected the 422 to be returned")
+	}
+	svc.AssertIPLookupRequestDataCalled(t, 1)
+
+	// Neither are errors raised before the request is sent.
+	for _, cause := range []error{
+		&validation.Error{Operation: "ipLookupRequestData"},
+		&breaker.OpenError{Operation: breaker.OperationIPLookup, State: breaker.StateOpen},
+		errors.New("json: cannot unmarshal"),
+	} {
+		svc = fake.New().ErrorIPLookupRequestData(cause)
+		c, _ = goclient.New(goclient.Config{Service: svc, RetryBackoff: time.Millisecond})
+		if _, err := c.LookupIPs(context.Background(), []string{"8.8.8.8"}); !errors.Is(err, cause) {
+			t.Fatalf("Expected %v, got %v", cause, err)
+		}
+		svc.AssertIPLookupRequestDataCalled(t, 1)
+	}
+}
+
+func TestClientRetryRespectsDeadline(t *testing.T) {
+	svc := fake.New()
+	svc.IPLookupRequestDataFunc = func(*operations.IPLookupRequestDataParams, ...operations.ClientOption) (*operations.IPLookupRequestDataOK, error) {
+		return nil, operations.NewIPLookupRequestDataDefault(503)
+	}
+	c, _ := goclient.New(goclient.Config{Service: svc, MaxRetries: 5, RetryBackoff: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.LookupIPs(ctx, []string{"8.8.8.8"}); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Expected to give up without sleeping past the deadline, took %v", elapsed)
+	}
+	svc.AssertIPLookupRequestDataCalled(t, 1)
+}
+
+func TestClientCancelStopsBatches(t *testing.T) {
+	var calls int32
+	ctx, cancel := context.WithCancel(context.Background())
+	svc := fake.New()
+	svc.IPLookupRequestDataFunc = func(params *operations.IPLookupRequestDataParams, opts ...operations.ClientOption) (*operations.IPLookupRequestDataOK, error) {
+		if atomic.AddInt32(&calls, 1) == 2 {
+			cancel()
+		}
+		return echoIPs(params, opts...)
+	}
+	c, _ := goclient.New(goclient.Config{Service: svc, BatchSize: 1})
+
+	_, err := c.LookupIPs(ctx, ips(10))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n > 3 {
+		t.Errorf("Expected batching to stop after cancellation, got %d calls", n)
+	}
+}
+
+func TestClientFirstBatchErrorWins(t *testing.T) {
+	svc := fake.New()
+	svc.EmailValidationRequestDataFunc = func(params *operations.EmailValidationRequestDataParams, _ ...operations.ClientOption) (*operations.EmailValidationRequestDataOK, error) {
+		if strings.HasPrefix(params.Body.Data[0], "bad") {
+			return nil, operations.NewEmailValidationRequestDataDefault(422)
+		}
+		<-params.Context.Done()
+		return nil, params.Context.Err()
+	}
+	c, _ := goclient.New(goclient.Config{Service: svc, BatchSize: 1, Concurrency: 2})
+
+	_, err := c.ValidateEmails(context.Background(), []string{"slow@example.com", "bad@example.com"})
+	var def *operations.EmailValidationRequestDataDefault
+	if !errors.As(err, &def) || !strings.Contains(err.Error(), "batch 2 of 2") {
+		t.Fatalf("Expected the 422 of batch 2, got %v", err)
+	}
+}
+
+func TestNewRequiresCredentials(t *testing.T) {
+	if _, err := goclient.New(goclient.Config{}); !errors.Is(err, goclient.ErrMissingCredentials) {
+		t.Errorf("Expected ErrMissingCredentials, got %v", err)
+	}
+}
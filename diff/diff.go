@@ -0,0 +1,282 @@
+// Package diff detects changes between two runs of email validations or IP
+// lookups.
+//
+// Emails and IPs compare two snapshots record by record, keyed by email or
+// IP address, and field by field. Boolean fields that flip and scores that
+// move by at least Options.ScoreThreshold are reported as structured
+// Events, each marked as a change for the worse or not, so that periodic
+// re-validation jobs can act on what changed only:
+//
+//	events, commit, err := diff.NewStore(dir).DiffEmails("customers", current, diff.Options{})
+//	for _, ev := range events {
+//		if ev.Worse {
+//			log.Println(ev)
+//		}
+//	}
+//	err = commit()
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cerberius.com/go-client/generated/models"
+)
+
+// DefaultScoreThreshold is the smallest score delta reported when
+// Options.ScoreThreshold is not set.
+const DefaultScoreThreshold = 10
+
+// Kind is the kind of an Event.
+type Kind string
+
+const (
+	// KindAdded is a record that is new in the current snapshot.
+	KindAdded Kind = "added"
+	// KindRemoved is a record that is missing from the current snapshot.
+	KindRemoved Kind = "removed"
+	// KindFlag is a boolean field that flipped.
+	KindFlag Kind = "flag"
+	// KindScore is a score that moved by at least the threshold.
+	KindScore Kind = "score"
+	// KindValue is any other field that changed.
+	KindValue Kind = "value"
+)
+
+// Event is a single change between two snapshots.
+type Event struct {
+	Key   string `json:"key"`             // Key is the email or IP address of the record.
+	Kind  Kind   `json:"kind"`            // Kind is the kind of change.
+	Field string `json:"field,omitempty"` // Field is the JSON name of the changed field.
+	Old   string `json:"old,omitempty"`   // Old is the previous value.
+	New   string `json:"new,omitempty"`   // New is the current value.
+	Delta int64  `json:"delta,omitempty"` // Delta is the score change, for KindScore.
+
+	// Worse reports whether the change is for the worse, for example an
+	// address whose SMTP check started failing, a domain that lost SPF or
+	// an IP that landed on a blocklist.
+	Worse bool `json:"worse"`
+}
+
+func (e Event) String() string {
+	switch e.Kind {
+	case KindAdded, KindRemoved:
+		return fmt.Sprintf("%s: %s", e.Key, e.Kind)
+	case KindScore:
+		return fmt.Sprintf("%s: %s %s -> %s (%+d)", e.Key, e.Field, e.Old, e.New, e.Delta)
+	}
+	return fmt.Sprintf("%s: %s %q -> %q", e.Key, e.Field, e.Old, e.New)
+}
+
+// Options configures a comparison.
+type Options struct {
+	// ScoreThreshold is the smallest absolute score delta reported,
+	// DefaultScoreThreshold if zero.
+	ScoreThreshold int64
+
+	// Ignore lists the JSON names of fields that are not compared.
+	Ignore []string
+}
+
+// polarity is the direction in which a field gets better.
+type polarity int
+
+const (
+	neutral    polarity = iota
+	higherGood          // true or a higher score is better.
+	lowerGood           // false or a lower score is better.
+)
+
+// emailPolarity and ipPolarity give the direction of the fields whose
+// changes can be for the worse.
+var (
+	emailPolarity = map[string]polarity{
+		"validity_score": higherGood,
+		"smtp_valid":     higherGood,
+		"has_spf":        higherGood,
+		"has_dmarc":      higherGood,
+		"is_disposable":  lowerGood,
+		"smtp_catch_all": lowerGood,
+	}
+	ipPolarity = map[string]polarity{
+		"fraud_score":        lowerGood,
+		"on_block_list":      lowerGood,
+		"is_tor_exit_point":  lowerGood,
+		"is_anonimous":       lowerGood,
+		"recent_spam_domain": lowerGood,
+	}
+)
+
+// scoreFields are the fields compared as scores.
+var scoreFields = map[string]bool{
+	"validity_score": true,
+	"fraud_score":    true,
+}
+
+// Emails compares two snapshots of email validation results, keyed by
+// case-insensitive email address, and returns the changes sorted by key.
+func Emails(old, current []*models.EmailData, opts Options) []Event {
+	key := func(d *models.EmailData) string { return strings.ToLower(strings.TrimSpace(d.EmailAddress)) }
+	opts.Ignore = append(opts.Ignore[:len(opts.Ignore):len(opts.Ignore)], "email_address")
+	return compareAll(old, current, key, emailPolarity, opts)
+}
+
+// IPs compares two snapshots of IP lookup results, keyed by IP address,
+// and returns the changes sorted by key.
+func IPs(old, current []*models.IPData, opts Options) []Event {
+	key := func(d *models.IPData) string { return strings.TrimSpace(d.IPAddress) }
+	opts.Ignore = append(opts.Ignore[:len(opts.Ignore):len(opts.Ignore)], "ip_address")
+	return compareAll(old, current, key, ipPolarity, opts)
+}
+
+// Email compares two validation results of the same email address. A nil
+// old or current result is reported as added or removed.
+func Email(old, current *models.EmailData, opts Options) []Event {
+	switch {
+	case old == nil && current == nil:
+		return nil
+	case old == nil:
+		return []Event{{Key: current.EmailAddress, Kind: KindAdded}}
+	case current == nil:
+		return []Event{{Key: old.EmailAddress, Kind: KindRemoved}}
+	}
+	opts.Ignore = append(opts.Ignore[:len(opts.Ignore):len(opts.Ignore)], "email_address")
+	return compare(current.EmailAddress, old, current, emailPolarity, opts)
+}
+
+// IP compares two lookup results of the same IP address. A nil old or
+// current result is reported as added or removed.
+func IP(old, current *models.IPData, opts Options) []Event {
+	switch {
+	case old == nil && current == nil:
+		return nil
+	case old == nil:
+		return []Event{{Key: current.IPAddress, Kind: KindAdded}}
+	case current == nil:
+		return []Event{{Key: old.IPAddress, Kind: KindRemoved}}
+	}
+	opts.Ignore = append(opts.Ignore[:len(opts.Ignore):len(opts.Ignore)], "ip_address")
+	return compare(current.IPAddress, old, current, ipPolarity, opts)
+}
+
+// compareAll matches the records of two snapshots by key and compares them.
+func compareAll[T any](old, current []*T, key func(*T) string, polarities map[string]polarity, opts Options) []Event {
+	previous := make(map[string]*T, len(old))
+	for _, d := range old {
+		if d != nil {
+			previous[key(d)] = d
+		}
+	}
+
+	var events []Event
+	seen := make(map[string]bool, len(current))
+	for _, d := range current {
+		if d == nil {
+			continue
+		}
+		k := key(d)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		p, ok := previous[k]
+		if !ok {
+			events = append(events, Event{Key: k, Kind: KindAdded})
+			continue
+		}
+		events = append(events, compare(k, p, d, polarities, opts)...)
+	}
+	for k := range previous {
+		if !seen[k] {
+			events = append(events, Event{Key: k, Kind: KindRemoved})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Key != events[j].Key {
+			return events[i].Key < events[j].Key
+		}
+		return events[i].Field < events[j].Field
+	})
+	return events
+}
+
+// compare compares two records field by field, in field order.
+func compare[T any](key string, old, current *T, polarities map[string]polarity, opts Options) []Event {
+	threshold := opts.ScoreThreshold
+	if threshold <= 0 {
+		threshold = DefaultScoreThreshold
+	}
+
+	ov := reflect.ValueOf(old).Elem()
+	cv := reflect.ValueOf(current).Elem()
+	t := ov.Type()
+
+	var events []Event
+fields:
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		for _, ignored := range opts.Ignore {
+			if name == ignored {
+				continue fields
+			}
+		}
+		a, b := ov.Field(i), cv.Field(i)
+		if reflect.DeepEqual(a.Interface(), b.Interface()) {
+			continue
+		}
+
+		ev := Event{Key: key, Field: name, Old: format(a), New: format(b)}
+		switch {
+		case scoreFields[name]:
+			oldScore, err1 := score(a)
+			newScore, err2 := score(b)
+			if err1 != nil || err2 != nil {
+				ev.Kind = KindValue
+				break
+			}
+			ev.Kind = KindScore
+			ev.Delta = newScore - oldScore
+			if ev.Delta < threshold && ev.Delta > -threshold {
+				continue
+			}
+			ev.Worse = polarities[name] == higherGood && ev.Delta < 0 || polarities[name] == lowerGood && ev.Delta > 0
+		case a.Kind() == reflect.Bool:
+			ev.Kind = KindFlag
+			ev.Worse = polarities[name] == higherGood && !b.Bool() || polarities[name] == lowerGood && b.Bool()
+		default:
+			ev.Kind = KindValue
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+// format formats a field value for an Event.
+func format(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.String:
+		return v.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// score returns the value of a score field, which is an integer or a
+// numeric string.
+func score(v reflect.Value) (int64, error) {
+	if v.Kind() == reflect.Int64 {
+		return v.Int(), nil
+	}
+	s := strings.TrimSpace(v.String())
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
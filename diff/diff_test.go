@@ -0,0 +1,166 @@
+package diff
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"cerberius.com/go-client/generated/models"
+)
+
+func TestEmails(t *testing.T) {
+	old := []*models.EmailData{
+		{EmailAddress: "alice@example.com", ValidityScore: 90, SMTPValid: true, HasSPF: true, HasDMARC: true, Domain: "example.com"},
+		{EmailAddress: "bob@example.com", ValidityScore: 80},
+		{EmailAddress: "carol@example.com", ValidityScore: 70},
+	}
+	current := []*models.EmailData{
+		{EmailAddress: "Alice@Example.com", ValidityScore: 30, SMTPValid: false, HasSPF: false, HasDMARC: true, Domain: "example.com"},
+		{EmailAddress: "bob@example.com", ValidityScore: 85},
+		{EmailAddress: "dave@example.com", ValidityScore: 60},
+	}
+
+	events := Emails(old, current, Options{})
+	want := []Event{
+		{Key: "alice@example.com", Kind: KindFlag, Field: "has_spf", Old: "true", New: "false", Worse: true},
+		{Key: "alice@example.com", Kind: KindFlag, Field: "smtp_valid", Old: "true", New: "false", Worse: true},
+		{Key: "alice@example.com", Kind: KindScore, Field: "validity_score", Old: "90", New: "30", Delta: -60, Worse: true},
+		{Key: "carol@example.com", Kind: KindRemoved},
+		{Key: "dave@example.com", Kind: KindAdded},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %d: %v", len(want), len(events), events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("Event %d: expected %+v, got %+v", i, want[i], events[i])
+		}
+	}
+
+	// Ignored fields and small deltas are not reported.
+	events = Emails(old[:1], current[:1], Options{ScoreThreshold: 100, Ignore: []string{"has_spf"}})
+	if len(events) != 1 || events[0].Field != "smtp_valid" {
+		t.Errorf("Unexpected events %v", events)
+	}
+}
+
+func TestIPs(t *testing.T) {
+	old := []*models.IPData{{IPAddress: "8.8.8.8", FraudScore: "10", OnBlockList: true, Country: "US"}}
+	current := []*models.IPData{{IPAddress: "8.8.8.8", FraudScore: "45", OnBlockList: false, Country: "United States"}}
+
+	events := IPs(old, current, Options{})
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %v", events)
+	}
+	for _, ev := range events {
+		switch ev.Field {
+		case "fraud_score":
+			if ev.Kind != KindScore || ev.Delta != 35 || !ev.Worse {
+				t.Errorf("Unexpected fraud score event %+v", ev)
+			}
+		case "on_block_list":
+			if ev.Kind != KindFlag || ev.Worse {
+				t.Errorf("Leaving a blocklist should not be worse: %+v", ev)
+			}
+		case "country":
+			if ev.Kind != KindValue || ev.Worse {
+				t.Errorf("Unexpected country event %+v", ev)
+			}
+		default:
+			t.Errorf("Unexpected event %+v", ev)
+		}
+	}
+
+	// A non-numeric score is reported as a value change.
+	events = IP(&models.IPData{IPAddress: "1.1.1.1", FraudScore: "10"}, &models.IPData{IPAddress: "1.1.1.1", FraudScore: "n/a"}, Options{})
+	if len(events) != 1 || events[0].Kind != KindValue {
+		t.Errorf("Unexpected events %v", events)
+	}
+}
+
+func TestNilRecords(t *testing.T) {
+	d := &models.IPData{IPAddress: "8.8.8.8"}
+	if events := IP(nil, d, Options{}); len(events) != 1 || events[0].Kind != KindAdded || events[0].Key != "8.8.8.8" {
+		t.Errorf("Unexpected events for a new record %v", events)
+	}
+	if events := IP(d, nil, Options{}); len(events) != 1 || events[0].Kind != KindRemoved {
+		t.Errorf("Unexpected events for a removed record %v", events)
+	}
+	if events := Email(nil, nil, Options{}); events != nil {
+		t.Errorf("Unexpected events for two nil records %v", events)
+	}
+}
+
+func TestSingleRecordsIgnoreKeySpelling(t *testing.T) {
+	old := &models.EmailData{EmailAddress: "Foo@x.com", ValidityScore: 90}
+	current := &models.EmailData{EmailAddress: "foo@x.com", ValidityScore: 90}
+	if events := Email(old, current, Options{}); len(events) != 0 {
+		t.Errorf("Unexpected events for a respelled address %v", events)
+	}
+	if events := IP(&models.IPData{IPAddress: "::ffff:8.8.8.8"}, &models.IPData{IPAddress: "8.8.8.8"}, Options{}); len(events) != 0 {
+		t.Errorf("Unexpected events for a respelled address %v", events)
+	}
+}
+
+func TestCompareNonComparableFields(t *testing.T) {
+	type record struct {
+		Tags []string `json:"tags"`
+	}
+	if events := compare("k", &record{Tags: []string{"a"}}, &record{Tags: []string{"a"}}, nil, Options{}); len(events) != 0 {
+		t.Errorf("Unexpected events for equal slices %v", events)
+	}
+	events := compare("k", &record{Tags: []string{"a"}}, &record{Tags: []string{"b"}}, nil, Options{})
+	if len(events) != 1 || events[0].Kind != KindValue || events[0].Old != "[a]" || events[0].New != "[b]" {
+		t.Errorf("Unexpected events for changed slices %v", events)
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := NewStore(t.TempDir())
+	if _, err := s.Load("monthly"); !errors.Is(err, ErrNoSnapshot) || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected ErrNoSnapshot, got %v", err)
+	}
+
+	first := []*models.IPData{{IPAddress: "8.8.8.8", FraudScore: "10"}}
+	events, commit, err := s.DiffIPs("monthly", first, Options{})
+	if err != nil || len(events) != 1 || events[0].Kind != KindAdded {
+		t.Fatalf("Expected the first run to add every address, got %v, %v", events, err)
+	}
+	// Until committed, the run reports the same events again.
+	if events, _, err = s.DiffIPs("monthly", first, Options{}); err != nil || len(events) != 1 {
+		t.Fatalf("Expected an uncommitted run to be repeated, got %v, %v", events, err)
+	}
+	if err := commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	second := []*models.IPData{{IPAddress: "8.8.8.8", FraudScore: "10", OnBlockList: true}}
+	events, commit, err = s.DiffIPs("monthly", second, Options{})
+	if err != nil || len(events) != 1 || events[0].Field != "on_block_list" || !events[0].Worse {
+		t.Fatalf("Expected the blocklist event, got %v, %v", events, err)
+	}
+	if err := commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := s.Load("monthly" + IPsSuffix)
+	if err != nil || len(snap.IPs) != 1 || !snap.IPs[0].OnBlockList || snap.Taken.IsZero() {
+		t.Errorf("Expected the second run to be saved, got %+v, %v", snap, err)
+	}
+
+	// Email snapshots of the same run name are kept apart.
+	events, commit, err = s.DiffEmails("monthly", []*models.EmailData{{EmailAddress: "user@example.com"}}, Options{})
+	if err != nil || len(events) != 1 || events[0].Kind != KindAdded {
+		t.Fatalf("Expected the first email run to add every address, got %v, %v", events, err)
+	}
+	if err := commit(); err != nil {
+		t.Fatal(err)
+	}
+	if snap, err := s.Load("monthly" + IPsSuffix); err != nil || len(snap.IPs) != 1 {
+		t.Errorf("Expected the IP snapshot to survive the email run, got %+v, %v", snap, err)
+	}
+
+	if err := s.Save("../escape", &Snapshot{}); err == nil {
+		t.Error("Expected an invalid name to be rejected")
+	}
+}
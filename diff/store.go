@@ -0,0 +1,146 @@
+package diff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cerberius.com/go-client/generated/models"
+)
+
+// ErrNoSnapshot is returned by Store.Load when no snapshot has been saved
+// under a name. It matches fs.ErrNotExist.
+var ErrNoSnapshot = fmt.Errorf("diff: no snapshot: %w", fs.ErrNotExist)
+
+// Snapshot is the result of a validation run.
+type Snapshot struct {
+	Taken  time.Time           `json:"taken"`
+	Emails []*models.EmailData `json:"emails,omitempty"`
+	IPs    []*models.IPData    `json:"ips,omitempty"`
+}
+
+// Store persists the latest snapshot of every named run as a JSON file in
+// a directory.
+type Store struct {
+	Dir string // Dir is the directory the snapshots are stored in.
+}
+
+// NewStore creates a new Store in dir.
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// path returns the file of the snapshot name.
+func (s *Store) path(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("diff: invalid snapshot name %q", name)
+	}
+	return filepath.Join(s.Dir, name+".json"), nil
+}
+
+// Load returns the snapshot saved under name, or ErrNoSnapshot.
+func (s *Store) Load(name string) (*Snapshot, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNoSnapshot
+	} else if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return nil, fmt.Errorf("diff: snapshot %q: %w", name, err)
+	}
+	return &snap, nil
+}
+
+// Save replaces the snapshot saved under name. The file is replaced
+// atomically, so a crash never leaves a partial snapshot behind.
+func (s *Store) Save(name string, snap *Snapshot) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.Dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Snapshot name suffixes of DiffEmails and DiffIPs, which keep the email
+// and IP snapshots of a run name apart.
+const (
+	EmailsSuffix = ".emails"
+	IPsSuffix    = ".ips"
+)
+
+// DiffEmails compares current with the email snapshot of the run name and
+// returns the changes. On the first run every address is reported as added.
+// The snapshot is saved under name+EmailsSuffix by commit, which the caller
+// calls once the events are processed, so that a run that crashes before
+// reports the same events again.
+func (s *Store) DiffEmails(name string, current []*models.EmailData, opts Options) (events []Event, commit func() error, err error) {
+	name += EmailsSuffix
+	prev, err := s.loadOrEmpty(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	commit = func() error {
+		return s.Save(name, &Snapshot{Taken: time.Now().UTC(), Emails: current})
+	}
+	return Emails(prev.Emails, current, opts), commit, nil
+}
+
+// DiffIPs compares current with the IP snapshot of the run name and returns
+// the changes like DiffEmails. The snapshot is saved under name+IPsSuffix by
+// commit.
+func (s *Store) DiffIPs(name string, current []*models.IPData, opts Options) (events []Event, commit func() error, err error) {
+	name += IPsSuffix
+	prev, err := s.loadOrEmpty(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	commit = func() error {
+		return s.Save(name, &Snapshot{Taken: time.Now().UTC(), IPs: current})
+	}
+	return IPs(prev.IPs, current, opts), commit, nil
+}
+
+// loadOrEmpty returns the snapshot saved under name, or an empty snapshot
+// if there is none.
+func (s *Store) loadOrEmpty(name string) (*Snapshot, error) {
+	snap, err := s.Load(name)
+	if errors.Is(err, ErrNoSnapshot) {
+		return &Snapshot{}, nil
+	}
+	return snap, err
+}
@@ -77,6 +77,29 @@
 // For more detailed examples, including comprehensive error handling, see the
 // `examples/main.go` file in this repository.
 //
+// # Context-first client
+//
+// Client wraps the generated operations with methods that take a context as
+// their first argument. The context's deadline becomes the HTTP timeout of
+// every request, and cancelling it stops batching, retries and rate limit
+// waits. Calls without a deadline get a per-operation default:
+//
+//	c, err := goclient.New(goclient.Config{
+//		APIKey:    apiKey,
+//		APISecret: apiSecret,
+//		Deadlines: goclient.Deadlines{IPLookup: 200 * time.Millisecond},
+//	})
+//	...
+//	ips, err := c.LookupIPs(ctx, []string{"8.8.8.8"})
+//
+// Inputs that don't fit in memory can be streamed with LookupIPsSeq and
+// ValidateEmailsSeq, which read an iter.Seq (see FromChan for channels) in
+// batches and yield the results as they arrive:
+//
+//	for ip, err := range c.LookupIPsSeq(ctx, cursor, goclient.SeqOptions{Concurrency: 4}) {
+//		...
+//	}
+//
 // # Authentication
 //
 // Authentication is handled by `cerberius.com/go-client/auth.HMACAuthTransport`. This transport
@@ -86,4 +109,9 @@
 //   - X-Signature: The HMAC-SHA256 signature of (timestamp + api_key) using your API secret.
 //
 // You must provide your API key and secret when creating the `HMACAuthTransport`.
+//
+// Setting `SignBody` on the transport selects signature version 2, which also
+// signs the request method, path and a SHA-256 of the body, and adds the
+// X-Signature-Version and X-Content-SHA256 headers. `auth.Verifier` checks
+// both versions and can be told to require version 2.
 package goclient // import "cerberius.com/go-client"
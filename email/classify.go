@@ -0,0 +1,190 @@
+package email
+
+import (
+	"strings"
+
+	"cerberius.com/go-client/generated/models"
+)
+
+// Category is the kind of mailbox behind an address.
+type Category string
+
+const (
+	CategoryPersonal     Category = "personal"     // CategoryPersonal is a mailbox of a single person.
+	CategoryRole         Category = "role"         // CategoryRole is a function such as admin@ or billing@.
+	CategoryDistribution Category = "distribution" // CategoryDistribution is a list such as team@ or all@.
+	CategoryNoReply      Category = "no-reply"     // CategoryNoReply is an address that is not read.
+)
+
+// Action is what a signup flow should do with an address.
+type Action string
+
+const (
+	ActionAllow  Action = "allow"  // ActionAllow accepts the address.
+	ActionReview Action = "review" // ActionReview accepts the address but flags it for review.
+	ActionReject Action = "reject" // ActionReject refuses the address.
+)
+
+// Default local-part prefixes of the categories.
+var (
+	DefaultRolePrefixes = []string{
+		"abuse", "accounting", "accounts", "admin", "administrator", "billing",
+		"careers", "contact", "finance", "help", "helpdesk", "hostmaster", "hr",
+		"info", "jobs", "legal", "marketing", "office", "postmaster", "privacy",
+		"root", "sales", "security", "support", "webmaster",
+	}
+	DefaultDistributionPrefixes = []string{
+		"all", "engineering", "everyone", "group", "list", "ops",
+		"staff", "team",
+	}
+	DefaultNoReplyPrefixes = []string{
+		"bounce", "bounces", "donotreply", "mailer-daemon", "noreply",
+		"notification", "notifications",
+	}
+)
+
+// DefaultPolicy is the action of every category when a Classifier has no
+// policy for it.
+var DefaultPolicy = map[Category]Action{
+	CategoryPersonal:     ActionAllow,
+	CategoryRole:         ActionReview,
+	CategoryDistribution: ActionReview,
+	CategoryNoReply:      ActionReject,
+}
+
+// Classification is the outcome of classifying an address.
+type Classification struct {
+	Address  string   `json:"address"`           // Address is the classified address.
+	Category Category `json:"category"`          // Category is the kind of mailbox.
+	Matched  string   `json:"matched,omitempty"` // Matched is the local prefix that matched, if any.
+	Action   Action   `json:"action"`            // Action is the policy for Category.
+
+	// Shared reports whether the API flagged the address as shared. It is
+	// always false for offline classifications.
+	Shared bool `json:"shared"`
+}
+
+// Classifier classifies addresses into categories with local prefix lists
+// and the API's IsSharedAddress flag.
+type Classifier struct {
+	// Role, Distribution and NoReply are the local-part prefixes of the
+	// categories, the defaults if nil. A prefix matches a local part that
+	// is equal to it or starts with it followed by a separator or digit,
+	// ignoring case and any +tag. Prefixes of up to three characters, such
+	// as hr and ops, only match the whole local part. NoReply prefixes
+	// also match any run of the parts of the local part between separators
+	// and digits, ignoring the separators, to catch shop-noreply@ and
+	// do.not.reply@ but not bob.ounce@.
+	Role         []string
+	Distribution []string
+	NoReply      []string
+
+	// Policy maps categories to actions; DefaultPolicy is used for
+	// categories without an entry.
+	Policy map[Category]Action
+}
+
+// NewClassifier creates a new Classifier with the default prefixes and
+// policy.
+func NewClassifier() *Classifier {
+	return &Classifier{}
+}
+
+// Classify classifies an address offline, from its local part only.
+func (c *Classifier) Classify(address string) Classification {
+	user, _ := splitAddress(address)
+	user = strings.ToLower(user)
+	if i := strings.IndexByte(user, '+'); i >= 0 {
+		user = user[:i]
+	}
+
+	cl := Classification{Address: address, Category: CategoryPersonal}
+	switch {
+	case containsRun(user, orDefault(c.NoReply, DefaultNoReplyPrefixes), &cl.Matched):
+		cl.Category = CategoryNoReply
+	case matchPrefix(user, orDefault(c.Distribution, DefaultDistributionPrefixes), &cl.Matched):
+		cl.Category = CategoryDistribution
+	case matchPrefix(user, orDefault(c.Role, DefaultRolePrefixes), &cl.Matched):
+		cl.Category = CategoryRole
+	}
+	cl.Action = c.action(cl.Category)
+	return cl
+}
+
+// ClassifyResult classifies a validation result. An address the API flags
+// as shared but that matches no local prefix is classified as a role
+// address. A nil result is classified like an empty address.
+func (c *Classifier) ClassifyResult(ed *models.EmailData) Classification {
+	if ed == nil {
+		return c.Classify("")
+	}
+	cl := c.Classify(ed.EmailAddress)
+	cl.Shared = ed.IsSharedAddress
+	if cl.Shared && cl.Category == CategoryPersonal {
+		cl.Category = CategoryRole
+		cl.Action = c.action(cl.Category)
+	}
+	return cl
+}
+
+func (c *Classifier) action(cat Category) Action {
+	if a, ok := c.Policy[cat]; ok {
+		return a
+	}
+	return DefaultPolicy[cat]
+}
+
+func orDefault(list, def []string) []string {
+	if list == nil {
+		return def
+	}
+	return list
+}
+
+// shortPrefix is the length up to which a prefix must match the whole
+// local part.
+const shortPrefix = 3
+
+// matchPrefix reports whether user is one of prefixes, or starts with one
+// longer than shortPrefix followed by a separator or digit, and sets
+// matched.
+func matchPrefix(user string, prefixes []string, matched *string) bool {
+	for _, p := range prefixes {
+		p = strings.ToLower(p)
+		rest, ok := strings.CutPrefix(user, p)
+		if !ok {
+			continue
+		}
+		if rest == "" || len(p) > shortPrefix && strings.ContainsRune(".-_0123456789", rune(rest[0])) {
+			*matched = p
+			return true
+		}
+	}
+	return false
+}
+
+// containsRun reports whether one of words, with its separators removed,
+// equals a run of consecutive parts of user between separators and
+// digits, and sets matched.
+func containsRun(user string, words []string, matched *string) bool {
+	parts := strings.FieldsFunc(user, func(r rune) bool {
+		return strings.ContainsRune(".-_0123456789", r)
+	})
+	for _, w := range words {
+		w = strings.ToLower(w)
+		squashed := strings.NewReplacer(".", "", "-", "", "_", "").Replace(w)
+		for i := range parts {
+			run := ""
+			for _, part := range parts[i:] {
+				if run += part; len(run) >= len(squashed) {
+					break
+				}
+			}
+			if run == squashed {
+				*matched = w
+				return true
+			}
+		}
+	}
+	return false
+}
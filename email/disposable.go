@@ -0,0 +1,398 @@
+package email
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+
+	"github.com/go-openapi/runtime"
+)
+
+var (
+	//go:embed data/disposable.txt
+	embeddedDisposable string
+	//go:embed data/free.txt
+	embeddedFree string
+)
+
+// List kinds of DomainList files.
+const (
+	KindDisposable    = "disposable"     // KindDisposable marks a disposable domain.
+	KindFree          = "free"           // KindFree marks a free mailbox provider.
+	KindNotDisposable = "not-disposable" // KindNotDisposable overrides an embedded disposable domain.
+	KindNotFree       = "not-free"       // KindNotFree overrides an embedded free domain.
+)
+
+// Flags are the answers of a DomainList for an address or domain.
+type Flags struct {
+	Disposable bool `json:"is_disposable"`
+	Free       bool `json:"is_free"`
+}
+
+// Defaults for the zero fields of DomainList.
+const (
+	// DefaultMinConfirmations is the number of addresses API results must
+	// flag before a DomainList learns their domain.
+	DefaultMinConfirmations = 2
+
+	// DefaultMaxPending is the number of flagged domains a DomainList
+	// keeps while they wait for confirmations.
+	DefaultMaxPending = 10000
+)
+
+// Changes are the differences a DomainList found between itself and API
+// results.
+type Changes struct {
+	// Disposable and Free are the domains that were learned.
+	Disposable []string `json:"disposable,omitempty"`
+	Free       []string `json:"free,omitempty"`
+
+	// Conflicts are the listed domains the API did not flag. They are
+	// kept, as API results for a single address can be wrong.
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// Empty reports whether nothing was learned.
+func (c Changes) Empty() bool {
+	return len(c.Disposable) == 0 && len(c.Free) == 0
+}
+
+// DomainList answers IsDisposable and IsFree locally from a list of
+// domains: the embedded lists, updated from a file, plus what was learned
+// from API results. It is safe for concurrent use.
+type DomainList struct {
+	// Path is the file the list is updated from and learned domains are
+	// persisted to. Nothing is persisted if empty.
+	Path string
+
+	// MinConfirmations is the number of distinct addresses API results must
+	// flag before their domain is learned, DefaultMinConfirmations if
+	// zero.
+	MinConfirmations int
+
+	// MaxPending is the number of flagged domains kept while they wait for
+	// confirmations, DefaultMaxPending if zero. Once it is reached, an
+	// arbitrary waiting domain is forgotten for every new one.
+	MaxPending int
+
+	saveMu     sync.Mutex // saveMu serializes Save.
+	mu         sync.RWMutex
+	disposable map[string]bool
+	free       map[string]bool
+	local      map[listEntry]string   // local maps the entries of the file and learned domains to their kind.
+	pending    map[listEntry][]string // pending maps domains not yet learned to the addresses flagging them.
+}
+
+// listEntry is a domain of the disposable or free list.
+type listEntry struct {
+	free   bool
+	domain string
+}
+
+// NewDomainList creates a new DomainList with the embedded lists.
+func NewDomainList() *DomainList {
+	l := &DomainList{
+		disposable: make(map[string]bool),
+		free:       make(map[string]bool),
+		local:      make(map[listEntry]string),
+		pending:    make(map[listEntry][]string),
+	}
+	for _, d := range parseList(embeddedDisposable) {
+		l.disposable[d] = true
+	}
+	for _, d := range parseList(embeddedFree) {
+		l.free[d] = true
+	}
+	return l
+}
+
+// LoadDomainList creates a new DomainList with the embedded lists updated
+// from the file at path, which is also where learned domains are
+// persisted. A missing file is not an error.
+func LoadDomainList(path string) (*DomainList, error) {
+	l := NewDomainList()
+	l.Path = path
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := l.Update(f); err != nil {
+		return nil, fmt.Errorf("email: domain list %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// parseList parses an embedded list of domains.
+func parseList(s string) []string {
+	var domains []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			domains = append(domains, strings.ToLower(line))
+		}
+	}
+	return domains
+}
+
+// Update applies the entries of r, one "kind domain" pair per line, where
+// kind is one of the Kind constants. Blank lines and lines starting with
+// # are ignored.
+func (l *DomainList) Update(r io.Reader) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("line %d: want \"kind domain\", got %q", n, line)
+		}
+		kind, domain := fields[0], strings.ToLower(strings.TrimSuffix(fields[1], "."))
+		switch kind {
+		case KindDisposable:
+			l.disposable[domain] = true
+		case KindFree:
+			l.free[domain] = true
+		case KindNotDisposable:
+			delete(l.disposable, domain)
+		case KindNotFree:
+			delete(l.free, domain)
+		default:
+			return fmt.Errorf("line %d: unknown kind %q", n, kind)
+		}
+		l.local[listEntry{kind == KindFree || kind == KindNotFree, domain}] = kind
+	}
+	return sc.Err()
+}
+
+// Check returns the flags of an address or domain. Subdomains of listed
+// domains are flagged too.
+func (l *DomainList) Check(addressOrDomain string) Flags {
+	domain := addressOrDomain
+	if strings.Contains(domain, "@") {
+		domain = DomainOf(domain)
+	}
+	domain = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.check(domain)
+}
+
+// check returns the flags of a domain. l.mu must be held.
+func (l *DomainList) check(domain string) Flags {
+	var f Flags
+	for d := domain; d != ""; {
+		f.Disposable = f.Disposable || l.disposable[d]
+		f.Free = f.Free || l.free[d]
+		i := strings.IndexByte(d, '.')
+		if i < 0 {
+			break
+		}
+		d = d[i+1:]
+	}
+	return f
+}
+
+// IsDisposable reports whether an address or domain is disposable.
+func (l *DomainList) IsDisposable(addressOrDomain string) bool {
+	return l.Check(addressOrDomain).Disposable
+}
+
+// IsFree reports whether an address or domain belongs to a free mailbox
+// provider.
+func (l *DomainList) IsFree(addressOrDomain string) bool {
+	return l.Check(addressOrDomain).Free
+}
+
+// Reconcile compares API results with the list and learns the disposable
+// and free domains it does not know yet once results for MinConfirmations
+// distinct addresses flagged them. Domains the file marks not-disposable
+// or not-free are never learned, nor are free domains learned as
+// disposable. If Path is set and something was learned, it persists them.
+func (l *DomainList) Reconcile(results []*models.EmailData) (Changes, error) {
+	var ch Changes
+	conflicts := make(map[string]bool)
+	l.mu.Lock()
+	for _, ed := range results {
+		if ed == nil {
+			continue
+		}
+		domain := resultDomain(ed)
+		if domain == "" {
+			continue
+		}
+		address := strings.ToLower(strings.TrimSpace(ed.EmailAddress))
+		f := l.check(domain)
+		if ed.IsDisposable && !f.Disposable && !f.Free && l.confirm(listEntry{false, domain}, address) {
+			l.disposable[domain] = true
+			l.local[listEntry{false, domain}] = KindDisposable
+			ch.Disposable = append(ch.Disposable, domain)
+		}
+		if ed.IsFree && !f.Free && l.confirm(listEntry{true, domain}, address) {
+			l.free[domain] = true
+			l.local[listEntry{true, domain}] = KindFree
+			ch.Free = append(ch.Free, domain)
+		}
+		if f.Disposable && !ed.IsDisposable && !conflicts[domain] {
+			conflicts[domain] = true
+			ch.Conflicts = append(ch.Conflicts, domain)
+		}
+	}
+	l.mu.Unlock()
+	if ch.Empty() || l.Path == "" {
+		return ch, nil
+	}
+	return ch, l.Save()
+}
+
+// confirm records that address flagged the domain of entry and reports
+// whether the domain is to be learned. l.mu must be held.
+func (l *DomainList) confirm(entry listEntry, address string) bool {
+	// Overrides of the file apply to subdomains too, as in Check.
+	for d := entry.domain; d != ""; {
+		if kind := l.local[listEntry{entry.free, d}]; kind == KindNotDisposable || kind == KindNotFree {
+			return false
+		}
+		i := strings.IndexByte(d, '.')
+		if i < 0 {
+			break
+		}
+		d = d[i+1:]
+	}
+	need := l.MinConfirmations
+	if need <= 0 {
+		need = DefaultMinConfirmations
+	}
+	addresses, seen := l.pending[entry], false
+	for _, a := range addresses {
+		seen = seen || a == address
+	}
+	if !seen {
+		addresses = append(addresses, address)
+	}
+	if len(addresses) < need {
+		if _, ok := l.pending[entry]; !ok {
+			limit := l.MaxPending
+			if limit <= 0 {
+				limit = DefaultMaxPending
+			}
+			for e := range l.pending {
+				if len(l.pending) < limit {
+					break
+				}
+				delete(l.pending, e)
+			}
+		}
+		l.pending[entry] = addresses
+		return false
+	}
+	delete(l.pending, entry)
+	return true
+}
+
+// WriteTo writes the entries of the file and the learned domains to w in
+// the format read by Update.
+func (l *DomainList) WriteTo(w io.Writer) (int64, error) {
+	l.mu.RLock()
+	entries := make([]string, 0, len(l.local))
+	for entry, kind := range l.local {
+		entries = append(entries, kind+" "+entry.domain)
+	}
+	l.mu.RUnlock()
+	sort.Strings(entries)
+
+	var b strings.Builder
+	b.WriteString("# Domain list updates: one \"kind domain\" pair per line.\n")
+	for _, entry := range entries {
+		b.WriteString(entry + "\n")
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Save persists the entries of the file and the learned domains to Path.
+// The file is replaced atomically, and concurrent saves one after the
+// other, so that the last file written holds every learned domain.
+func (l *DomainList) Save() error {
+	if l.Path == "" {
+		return errors.New("email: domain list has no path")
+	}
+	l.saveMu.Lock()
+	defer l.saveMu.Unlock()
+	tmp, err := os.CreateTemp(filepath.Dir(l.Path), filepath.Base(l.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := l.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), l.Path)
+}
+
+// DomainListClient is an operations.ClientService that reconciles every
+// email validation response with a DomainList.
+type DomainListClient struct {
+	List *DomainList
+	Next operations.ClientService
+
+	// OnChange is called with the changes of every response that taught
+	// the list something or conflicted with it, and the error of
+	// persisting them. It may be nil.
+	OnChange func(Changes, error)
+}
+
+var _ operations.ClientService = (*DomainListClient)(nil)
+
+// EmailValidationRequestData implements operations.ClientService.
+func (c *DomainListClient) EmailValidationRequestData(params *operations.EmailValidationRequestDataParams, opts ...operations.ClientOption) (*operations.EmailValidationRequestDataOK, error) {
+	resp, err := c.Next.EmailValidationRequestData(params, opts...)
+	if err != nil || resp.Payload == nil {
+		return resp, err
+	}
+	ch, err := c.List.Reconcile(resp.Payload.Data)
+	if c.OnChange != nil && (!ch.Empty() || len(ch.Conflicts) > 0 || err != nil) {
+		c.OnChange(ch, err)
+	}
+	return resp, nil
+}
+
+// IPLookupRequestData implements operations.ClientService.
+func (c *DomainListClient) IPLookupRequestData(params *operations.IPLookupRequestDataParams, opts ...operations.ClientOption) (*operations.IPLookupRequestDataOK, error) {
+	return c.Next.IPLookupRequestData(params, opts...)
+}
+
+// PromptCheckRequestData implements operations.ClientService.
+func (c *DomainListClient) PromptCheckRequestData(params *operations.PromptCheckRequestDataParams, opts ...operations.ClientOption) (*operations.PromptCheckRequestDataOK, error) {
+	return c.Next.PromptCheckRequestData(params, opts...)
+}
+
+// SetTransport implements operations.ClientService.
+func (c *DomainListClient) SetTransport(transport runtime.ClientTransport) {
+	c.Next.SetTransport(transport)
+}
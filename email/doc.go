@@ -0,0 +1,30 @@
+// Package email provides workflows around the Cerberius email validation
+// operation.
+//
+// A Suggester turns the DidYouMean field of a validation result into a
+// "confirm your email" Recommendation for signup forms, falling back to a
+// local engine for common provider typos when the API has no suggestion.
+//
+// Several EmailData fields describe the domain rather than the mailbox.
+// Profiles groups results by domain into DomainProfiles, which a
+// DomainCache keeps separately from per-address results, and a
+// DomainChecker validates only one representative address per domain when
+// only the domain posture is needed.
+//
+// A Classifier sorts addresses into personal, role, distribution list and
+// no-reply categories with configurable local-part lists, combined with
+// the API's IsSharedAddress flag when a result is available, and maps each
+// category to a policy Action.
+//
+// A Normalizer maps the spellings of a mailbox, such as
+// John.Doe+promo@gmail.com and johndoe@googlemail.com, to one canonical
+// address with case folding, provider dot and tag rules, alias domains and
+// IDNA domain mapping, for cache keys, deduplicated validation and spotting
+// multi-accounting.
+//
+// A DomainList answers IsDisposable and IsFree without an API call from
+// embedded domain lists, updatable from a file. Reconcile, or a
+// DomainListClient wrapping the operations client, learns the domains API
+// results for several addresses flag that the list does not know yet and
+// persists them.
+package email
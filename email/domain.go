@@ -0,0 +1,300 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+	"cerberius.com/go-client/validation"
+)
+
+// DefaultDomainTTL is the time a DomainCache keeps a profile when its TTL
+// is not set.
+const DefaultDomainTTL = 24 * time.Hour
+
+// DomainProfile is the posture of an email domain, made of the EmailData
+// fields that are properties of the domain rather than of the mailbox.
+type DomainProfile struct {
+	Domain       string `json:"domain"`
+	DomainIP     string `json:"domain_ip,omitempty"`
+	MX           string `json:"mx_hosts,omitempty"`
+	MXReverseDNS string `json:"mx_reverse_dns,omitempty"`
+	HasSPF       bool   `json:"has_spf"`
+	HasDMARC     bool   `json:"has_dmarc"`
+	IsFree       bool   `json:"is_free"`
+	IsDisposable bool   `json:"is_disposable"`
+	CatchAll     bool   `json:"smtp_catch_all"`
+
+	// Addresses is the number of validation results the profile was
+	// inferred from.
+	Addresses int `json:"addresses"`
+
+	// Conflicts lists the JSON names of the fields on which the results
+	// disagreed. The profile holds the majority value.
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// DomainOf returns the lower-cased domain of an address, or "" if it has
+// none.
+func DomainOf(address string) string {
+	_, domain := splitAddress(address)
+	return strings.ToLower(strings.TrimSuffix(domain, "."))
+}
+
+// resultDomain returns the domain of a validation result.
+func resultDomain(ed *models.EmailData) string {
+	if ed.Domain != "" {
+		return strings.ToLower(ed.Domain)
+	}
+	return DomainOf(ed.EmailAddress)
+}
+
+// GroupByDomain groups validation results by domain. Results without a
+// domain are left out.
+func GroupByDomain(results []*models.EmailData) map[string][]*models.EmailData {
+	groups := make(map[string][]*models.EmailData)
+	for _, ed := range results {
+		if ed == nil {
+			continue
+		}
+		if domain := resultDomain(ed); domain != "" {
+			groups[domain] = append(groups[domain], ed)
+		}
+	}
+	return groups
+}
+
+// InferProfile infers the profile of domain from its validation results,
+// taking the majority value of every field. Empty string values are not
+// counted, so a field is only empty if no result has it.
+func InferProfile(domain string, results []*models.EmailData) *DomainProfile {
+	p := &DomainProfile{Domain: domain, Addresses: len(results)}
+	if len(results) == 0 {
+		return p
+	}
+
+	strs := []struct {
+		name string
+		dst  *string
+		get  func(*models.EmailData) string
+	}{
+		{"domain_ip", &p.DomainIP, func(ed *models.EmailData) string { return ed.DomainIP }},
+		{"mx_hosts", &p.MX, func(ed *models.EmailData) string { return ed.MX }},
+		{"mx_reverse_dns", &p.MXReverseDNS, func(ed *models.EmailData) string { return ed.MXReverseDNS }},
+	}
+	for _, f := range strs {
+		counts := make(map[string]int)
+		for _, ed := range results {
+			if v := f.get(ed); v != "" {
+				counts[v]++
+			}
+		}
+		best := ""
+		for v, n := range counts {
+			if n > counts[best] || n == counts[best] && v < best {
+				best = v
+			}
+		}
+		*f.dst = best
+		if len(counts) > 1 {
+			p.Conflicts = append(p.Conflicts, f.name)
+		}
+	}
+
+	bools := []struct {
+		name string
+		dst  *bool
+		get  func(*models.EmailData) bool
+	}{
+		{"has_spf", &p.HasSPF, func(ed *models.EmailData) bool { return ed.HasSPF }},
+		{"has_dmarc", &p.HasDMARC, func(ed *models.EmailData) bool { return ed.HasDMARC }},
+		{"is_free", &p.IsFree, func(ed *models.EmailData) bool { return ed.IsFree }},
+		{"is_disposable", &p.IsDisposable, func(ed *models.EmailData) bool { return ed.IsDisposable }},
+		{"smtp_catch_all", &p.CatchAll, func(ed *models.EmailData) bool { return ed.SMTPCatchAll }},
+	}
+	for _, f := range bools {
+		yes := 0
+		for _, ed := range results {
+			if f.get(ed) {
+				yes++
+			}
+		}
+		*f.dst = 2*yes > len(results)
+		if yes > 0 && yes < len(results) {
+			p.Conflicts = append(p.Conflicts, f.name)
+		}
+	}
+	sort.Strings(p.Conflicts)
+	return p
+}
+
+// Profiles infers the profile of every domain in results.
+func Profiles(results []*models.EmailData) map[string]*DomainProfile {
+	groups := GroupByDomain(results)
+	profiles := make(map[string]*DomainProfile, len(groups))
+	for domain, group := range groups {
+		profiles[domain] = InferProfile(domain, group)
+	}
+	return profiles
+}
+
+// DomainCache caches domain profiles separately from per-address results.
+// It is safe for concurrent use.
+type DomainCache struct {
+	TTL time.Duration    // TTL is the time a profile is kept, DefaultDomainTTL if zero.
+	Now func() time.Time // Now returns the current time, time.Now if nil.
+
+	mu        sync.Mutex
+	entries   map[string]domainEntry
+	nextSweep time.Time // nextSweep is the time Put next removes expired entries.
+}
+
+type domainEntry struct {
+	profile *DomainProfile
+	expires time.Time
+}
+
+// NewDomainCache creates a new DomainCache that keeps profiles for ttl.
+func NewDomainCache(ttl time.Duration) *DomainCache {
+	return &DomainCache{TTL: ttl}
+}
+
+func (c *DomainCache) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// Get returns the cached profile of domain.
+func (c *DomainCache) Get(domain string) (*DomainProfile, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := strings.ToLower(domain)
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.profile, true
+}
+
+// Put caches a profile. Expired profiles are removed from the cache at
+// most once per TTL.
+func (c *DomainCache) Put(p *DomainProfile) {
+	ttl := c.TTL
+	if ttl <= 0 {
+		ttl = DefaultDomainTTL
+	}
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]domainEntry)
+	}
+	if !now.Before(c.nextSweep) {
+		for key, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, key)
+			}
+		}
+		c.nextSweep = now.Add(ttl)
+	}
+	c.entries[strings.ToLower(p.Domain)] = domainEntry{profile: p, expires: now.Add(ttl)}
+}
+
+// Len returns the number of cached profiles, including expired ones that
+// have not been removed yet.
+func (c *DomainCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Add infers and caches the profiles of the domains in results.
+func (c *DomainCache) Add(results []*models.EmailData) {
+	for _, p := range Profiles(results) {
+		c.Put(p)
+	}
+}
+
+// DomainChecker answers domain posture questions with one validation per
+// domain: in domain-only mode a single representative address per
+// uncached domain is validated, which cuts the cost of bulk lists with
+// many addresses per domain.
+type DomainChecker struct {
+	Service operations.ClientService // Service is the operations client used for validations.
+	Cache   *DomainCache             // Cache holds the known profiles; a new cache is used if nil.
+
+	cacheOnce sync.Once
+}
+
+// NewDomainChecker creates a new DomainChecker with a DefaultDomainTTL
+// cache.
+func NewDomainChecker(svc operations.ClientService) *DomainChecker {
+	return &DomainChecker{Service: svc, Cache: NewDomainCache(DefaultDomainTTL)}
+}
+
+// Profiles returns the profile of every domain of addresses, validating
+// the first address of each uncached domain. Addresses without a domain
+// are ignored.
+func (d *DomainChecker) Profiles(ctx context.Context, addresses []string) (map[string]*DomainProfile, error) {
+	d.cacheOnce.Do(func() {
+		if d.Cache == nil {
+			d.Cache = NewDomainCache(DefaultDomainTTL)
+		}
+	})
+
+	profiles := make(map[string]*DomainProfile)
+	var representatives []string
+	for _, address := range addresses {
+		domain := DomainOf(address)
+		if domain == "" {
+			continue
+		}
+		if _, seen := profiles[domain]; seen {
+			continue
+		}
+		p, ok := d.Cache.Get(domain)
+		profiles[domain] = p
+		if !ok {
+			representatives = append(representatives, strings.TrimSpace(address))
+		}
+	}
+
+	size := validation.DefaultMaxBatchSize
+	for start := 0; start < len(representatives); start += size {
+		batch := representatives[start:min(start+size, len(representatives))]
+		params := operations.NewEmailValidationRequestDataParamsWithContext(ctx).
+			WithBody(&models.EmailLookupRequest{Data: batch})
+		resp, err := d.Service.EmailValidationRequestData(params)
+		if err != nil {
+			return nil, fmt.Errorf("email: validating domain representatives: %w", err)
+		}
+		if resp.Payload == nil {
+			continue
+		}
+		for domain, p := range Profiles(resp.Payload.Data) {
+			d.Cache.Put(p)
+			if _, wanted := profiles[domain]; wanted {
+				profiles[domain] = p
+			}
+		}
+	}
+
+	for domain, p := range profiles {
+		if p == nil {
+			// The API returned no result for the representative.
+			delete(profiles, domain)
+		}
+	}
+	return profiles, nil
+}
@@ -0,0 +1,492 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cerberius.com/go-client/fake"
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+)
+
+func TestSuggestDomain(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   string
+		ok     bool
+	}{
+		{"gmial.com", "gmail.com", true},
+		{"gmail.con", "gmail.com", true},
+		{"hotmial.com", "hotmail.com", true},
+		{"outlok.com", "outlook.com", true},
+		{"yahooo.com", "yahoo.com", true},
+		{"example.cmo", "example.com", true},
+		{"GMAIL.COM", "", false},
+		{"ymail.com", "", false},
+		{"mail.com", "", false},
+		{"aon.com", "", false},
+		{"gnail.com", "gmail.com", true},
+		{"example.com", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := SuggestDomain(tt.domain, KnownProviders)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("SuggestDomain(%q) = %q, %v; want %q, %v", tt.domain, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"gmail.com", "gmail.com", 0},
+		{"gmial.com", "gmail.com", 1},
+		{"gmai.com", "gmail.com", 1},
+		{"gnail.cm", "gmail.com", 2},
+		{"", "abc", 3},
+	} {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	ctx := context.Background()
+	s := &Suggester{}
+
+	// API suggestion for a known provider.
+	rec, err := s.Suggest(ctx, &models.EmailData{EmailAddress: "user@gmial.com", ValidityScore: 20, DidYouMean: "user@gmail.com"})
+	if err != nil || rec == nil || !rec.Confirm || rec.Source != SourceAPI || rec.Message != "Did you mean user@gmail.com?" {
+		t.Fatalf("Unexpected recommendation %+v, %v", rec, err)
+	}
+
+	// Local fallback when the API has none; domain-only DidYouMean.
+	rec, _ = s.Suggest(ctx, &models.EmailData{EmailAddress: "user@hotmial.com", ValidityScore: 10})
+	if rec == nil || !rec.Confirm || rec.Source != SourceLocal || rec.Suggestion != "user@hotmail.com" {
+		t.Fatalf("Expected a local suggestion, got %+v", rec)
+	}
+	rec, _ = s.Suggest(ctx, &models.EmailData{EmailAddress: "user@yahooo.com", DidYouMean: "yahoo.com"})
+	if rec == nil || rec.Suggestion != "user@yahoo.com" {
+		t.Fatalf("Expected a domain-only suggestion to be completed, got %+v", rec)
+	}
+
+	// A valid address is not second-guessed.
+	rec, _ = s.Suggest(ctx, &models.EmailData{EmailAddress: "user@outlok.com", ValidityScore: 95, SMTPValid: true})
+	if rec == nil || rec.Confirm {
+		t.Fatalf("Expected no confirmation for a valid address, got %+v", rec)
+	}
+
+	// No suggestion at all.
+	if rec, _ := s.Suggest(ctx, &models.EmailData{EmailAddress: "user@example.com", ValidityScore: 10}); rec != nil {
+		t.Fatalf("Expected no recommendation, got %+v", rec)
+	}
+
+	// Domains with MX records are not corrected locally.
+	if rec, _ := s.Suggest(ctx, &models.EmailData{EmailAddress: "user@gnail.com", ValidityScore: 10, MX: "mx.gnail.com"}); rec != nil {
+		t.Fatalf("Expected no local correction of a domain with MX records, got %+v", rec)
+	}
+
+	// Unknown domains are not suggested without re-validation.
+	rec, _ = s.Suggest(ctx, &models.EmailData{EmailAddress: "user@examle.com", DidYouMean: "user@example.com"})
+	if rec == nil || rec.Confirm {
+		t.Fatalf("Expected an unknown domain not to be confirmed, got %+v", rec)
+	}
+}
+
+func TestSuggestRevalidates(t *testing.T) {
+	svc := fake.New().
+		ReturnEmailValidationRequestData(&models.EmailLookupResponse{Data: []*models.EmailData{{EmailAddress: "user@example.com", ValidityScore: 90}}}).
+		ReturnEmailValidationRequestData(&models.EmailLookupResponse{Data: []*models.EmailData{{EmailAddress: "user@mailinator.com", ValidityScore: 90, IsDisposable: true}}})
+	s := NewSuggester(svc)
+	ctx := context.Background()
+
+	rec, err := s.Suggest(ctx, &models.EmailData{EmailAddress: "user@examle.com", DidYouMean: "user@example.com"})
+	if err != nil || !rec.Confirm || rec.SuggestionResult == nil || rec.SuggestionResult.ValidityScore != 90 {
+		t.Fatalf("Expected a re-validated suggestion, got %+v, %v", rec, err)
+	}
+	svc.AssertEmailValidationRequestDataCalledWith(t, &models.EmailLookupRequest{Data: []string{"user@example.com"}})
+
+	rec, err = s.Suggest(ctx, &models.EmailData{EmailAddress: "user@mailinatr.com", DidYouMean: "user@mailinator.com"})
+	if err != nil || rec.Confirm || rec.Reason != "suggestion is a disposable address" {
+		t.Fatalf("Expected a disposable suggestion to be refused, got %+v, %v", rec, err)
+	}
+
+	// Known providers need no re-validation.
+	s.Suggest(ctx, &models.EmailData{EmailAddress: "user@gmial.com", DidYouMean: "user@gmail.com"})
+	svc.AssertEmailValidationRequestDataCalled(t, 2)
+}
+
+func TestInferProfile(t *testing.T) {
+	results := []*models.EmailData{
+		{EmailAddress: "a@acme.com", HasSPF: true, HasDMARC: true, MX: "mx.acme.com"},
+		{EmailAddress: "b@ACME.com", HasSPF: true, HasDMARC: false, MX: "mx.acme.com"},
+		{EmailAddress: "c@acme.com", HasSPF: true, HasDMARC: false, MX: "mx2.acme.com"},
+		{EmailAddress: "x@gmail.com", IsFree: true},
+		{EmailAddress: "no-domain"},
+	}
+	profiles := Profiles(results)
+	if len(profiles) != 2 {
+		t.Fatalf("Expected 2 domains, got %v", profiles)
+	}
+	acme := profiles["acme.com"]
+	if acme.Addresses != 3 || !acme.HasSPF || acme.HasDMARC || acme.MX != "mx.acme.com" {
+		t.Errorf("Unexpected acme profile %+v", acme)
+	}
+	if got := strings.Join(acme.Conflicts, ","); got != "has_dmarc,mx_hosts" {
+		t.Errorf("Expected conflicts has_dmarc,mx_hosts, got %s", got)
+	}
+	if !profiles["gmail.com"].IsFree {
+		t.Errorf("Unexpected gmail profile %+v", profiles["gmail.com"])
+	}
+
+	// Missing values do not outvote the ones that are known.
+	p := InferProfile("globex.com", []*models.EmailData{
+		{EmailAddress: "a@globex.com"},
+		{EmailAddress: "b@globex.com"},
+		{EmailAddress: "c@globex.com", MX: "mx.globex.com"},
+	})
+	if p.MX != "mx.globex.com" || len(p.Conflicts) != 0 {
+		t.Errorf("Expected the known MX without conflicts, got %+v", p)
+	}
+}
+
+func TestDomainCheckerDomainOnly(t *testing.T) {
+	svc := fake.New().ReturnEmailValidationRequestData(&models.EmailLookupResponse{Data: []*models.EmailData{
+		{EmailAddress: "alice@acme.com", Domain: "acme.com", HasSPF: true},
+		{EmailAddress: "x@globex.com", Domain: "globex.com", HasDMARC: true},
+	}})
+	d := NewDomainChecker(svc)
+	ctx := context.Background()
+
+	profiles, err := d.Profiles(ctx, []string{"alice@acme.com", "bob@acme.com", "x@globex.com", "y@Globex.com", "bad"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 || !profiles["acme.com"].HasSPF || !profiles["globex.com"].HasDMARC {
+		t.Fatalf("Unexpected profiles %v", profiles)
+	}
+	svc.AssertEmailValidationRequestDataCalledWith(t, &models.EmailLookupRequest{Data: []string{"alice@acme.com", "x@globex.com"}})
+
+	// Cached domains are not validated again.
+	if profiles, err := d.Profiles(ctx, []string{"carol@acme.com"}); err != nil || !profiles["acme.com"].HasSPF {
+		t.Fatalf("Expected the cached profile, got %v, %v", profiles, err)
+	}
+	svc.AssertEmailValidationRequestDataCalled(t, 1)
+}
+
+func TestDomainCacheExpires(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := &DomainCache{TTL: time.Hour, Now: func() time.Time { return now }}
+	c.Put(&DomainProfile{Domain: "Acme.com"})
+	if _, ok := c.Get("acme.com"); !ok {
+		t.Fatal("Expected a cached profile")
+	}
+	now = now.Add(time.Hour)
+	if _, ok := c.Get("acme.com"); ok {
+		t.Fatal("Expected the profile to expire")
+	}
+
+	// Expired profiles that are never read again are evicted by Put.
+	c.Put(&DomainProfile{Domain: "globex.com"})
+	now = now.Add(time.Hour)
+	c.Put(&DomainProfile{Domain: "initech.com"})
+	if n := c.Len(); n != 1 {
+		t.Errorf("Expected expired profiles to be evicted, %d left", n)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	c := NewClassifier()
+	tests := []struct {
+		address string
+		want    Category
+		action  Action
+	}{
+		{"jane.doe@acme.com", CategoryPersonal, ActionAllow},
+		{"Sales@acme.com", CategoryRole, ActionReview},
+		{"billing-eu@acme.com", CategoryRole, ActionReview},
+		{"admin2+x@acme.com", CategoryRole, ActionReview},
+		{"salesman@acme.com", CategoryPersonal, ActionAllow},
+		{"team.platform@acme.com", CategoryDistribution, ActionReview},
+		{"no-reply@acme.com", CategoryNoReply, ActionReject},
+		{"shop_noreply@acme.com", CategoryNoReply, ActionReject},
+		{"do.not.reply@acme.com", CategoryNoReply, ActionReject},
+		{"bounces42@acme.com", CategoryNoReply, ActionReject},
+		{"bob.ounce@acme.com", CategoryPersonal, ActionAllow},
+		{"jbounce@acme.com", CategoryPersonal, ActionAllow},
+		{"no.rep@acme.com", CategoryPersonal, ActionAllow},
+		{"dev.patel@acme.com", CategoryPersonal, ActionAllow},
+		{"dev1984@acme.com", CategoryPersonal, ActionAllow},
+		{"ops@acme.com", CategoryDistribution, ActionReview},
+		{"hr.smith@acme.com", CategoryPersonal, ActionAllow},
+	}
+	for _, tt := range tests {
+		got := c.Classify(tt.address)
+		if got.Category != tt.want || got.Action != tt.action {
+			t.Errorf("Classify(%q) = %s/%s, want %s/%s", tt.address, got.Category, got.Action, tt.want, tt.action)
+		}
+	}
+
+	// Custom lists and policy.
+	c = &Classifier{Role: []string{"ceo"}, Policy: map[Category]Action{CategoryRole: ActionReject}}
+	if got := c.Classify("ceo@acme.com"); got.Category != CategoryRole || got.Action != ActionReject || got.Matched != "ceo" {
+		t.Errorf("Unexpected custom classification %+v", got)
+	}
+	if got := c.Classify("sales@acme.com"); got.Category != CategoryPersonal {
+		t.Errorf("Expected custom lists to replace the defaults, got %+v", got)
+	}
+
+	// The API flag upgrades unmatched addresses.
+	got := NewClassifier().ClassifyResult(&models.EmailData{EmailAddress: "frontdesk@acme.com", IsSharedAddress: true})
+	if got.Category != CategoryRole || !got.Shared || got.Action != ActionReview {
+		t.Errorf("Expected a shared address to be a role, got %+v", got)
+	}
+	if got := NewClassifier().ClassifyResult(nil); got.Category != CategoryPersonal {
+		t.Errorf("Unexpected classification of a nil result %+v", got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	n := NewNormalizer()
+	tests := []struct {
+		address, want string
+	}{
+		{"John.Doe+promo@gmail.com", "johndoe@gmail.com"},
+		{"johndoe@googlemail.com", "johndoe@gmail.com"},
+		{" J.O.H.N.D.O.E@GMAIL.COM. ", "johndoe@gmail.com"},
+		{"jane.doe+news@outlook.com", "jane.doe@outlook.com"},
+		{"jane-shopping@yahoo.com", "jane@yahoo.com"},
+		{"Jane.Doe+x@example.com", "jane.doe+x@example.com"},
+		{"user@Bücher.example", "user@xn--bcher-kva.example"},
+		{"user@例え。テスト", "user@xn--r8jz45g.xn--zckzah"},
+		{"user@bu\u0308cher.example", "user@xn--bcher-kva.example"},
+	}
+	for _, tt := range tests {
+		id, err := n.Normalize(tt.address)
+		if err != nil || id.Canonical != tt.want || id.Original != tt.address {
+			t.Errorf("Normalize(%q) = %+v, %v; want %q", tt.address, id, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"no-at-sign", "@example.com", "+tag@gmail.com", "user@a..b"} {
+		if _, err := n.Normalize(bad); !errors.Is(err, ErrInvalidAddress) {
+			t.Errorf("Normalize(%q) = %v, want ErrInvalidAddress", bad, err)
+		}
+	}
+
+	n.TagAll = true
+	if got := n.Key("Jane+x@Example.com"); got != "jane@example.com" {
+		t.Errorf("Expected TagAll to strip tags, got %q", got)
+	}
+	if got := n.Key(" Not An Address "); got != "not an address" {
+		t.Errorf("Unexpected fallback key %q", got)
+	}
+}
+
+func TestToASCII(t *testing.T) {
+	for domain, want := range map[string]string{
+		"münchen.de":       "xn--mnchen-3ya.de",
+		"bu\u0308cher.de":  "xn--bcher-kva.de",
+		"BÜCHER.de.":       "xn--bcher-kva.de",
+		"ＥＸＡＭＰＬＥ.com":      "example.com",
+		"faß.de":           "xn--fa-hia.de",
+		"xn--bcher-kva.de": "xn--bcher-kva.de",
+	} {
+		if got, err := ToASCII(domain); err != nil || got != want {
+			t.Errorf("ToASCII(%q) = %q, %v; want %q", domain, got, err, want)
+		}
+	}
+	for _, bad := range []string{"a..b", "", "exa mple.com"} {
+		if got, err := ToASCII(bad); err == nil {
+			t.Errorf("ToASCII(%q) = %q, want an error", bad, got)
+		}
+	}
+}
+
+func TestNormalizerValidate(t *testing.T) {
+	svc := fake.New().ReturnEmailValidationRequestData(&models.EmailLookupResponse{Data: []*models.EmailData{
+		{EmailAddress: "johndoe@gmail.com", ValidityScore: 90},
+		{EmailAddress: "bad", ValidityScore: 0},
+	}})
+	addresses := []string{"John.Doe+promo@gmail.com", "johndoe@googlemail.com", "bad"}
+	results, err := NewNormalizer().Validate(context.Background(), svc, addresses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.AssertEmailValidationRequestDataCalledWith(t, &models.EmailLookupRequest{Data: []string{"johndoe@gmail.com", "bad"}})
+	if len(results) != 3 || results[0].Result != results[1].Result || results[0].Result.ValidityScore != 90 ||
+		results[1].Original != "johndoe@googlemail.com" || results[2].Result == nil {
+		t.Fatalf("Unexpected results %+v", results)
+	}
+
+	ids := []Identity{results[0].Identity, results[1].Identity, results[2].Identity, results[1].Identity}
+	multi := MultiAccounts(ids)
+	if len(multi) != 1 || strings.Join(multi["johndoe@gmail.com"], ",") != "John.Doe+promo@gmail.com,johndoe@googlemail.com" {
+		t.Errorf("Unexpected multi-accounts %v", multi)
+	}
+}
+
+func TestDomainList(t *testing.T) {
+	l := NewDomainList()
+	if f := l.Check("user@Mailinator.com"); !f.Disposable || f.Free {
+		t.Errorf("Unexpected flags %+v", f)
+	}
+	if !l.IsDisposable("inbox.guerrillamail.com") || !l.IsFree("gmail.com") || l.IsFree("acme.com") || l.IsDisposable("notmailinator.com") {
+		t.Error("Unexpected embedded list answers")
+	}
+
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	os.WriteFile(path, []byte("# local\nnot-disposable mailinator.com\ndisposable throwaway.example\n"), 0o644)
+	l, err := LoadDomainList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.IsDisposable("mailinator.com") || !l.IsDisposable("x@throwaway.example") {
+		t.Error("Expected the file to update the embedded list")
+	}
+
+	ch, err := l.Reconcile([]*models.EmailData{
+		{EmailAddress: "a@new-trash.example", IsDisposable: true},
+		{EmailAddress: "b@new-trash.example", IsDisposable: true},
+		{EmailAddress: "c@freemail.example", Domain: "freemail.example", IsFree: true},
+		{EmailAddress: "c2@freemail.example", IsFree: true},
+		{EmailAddress: "d@throwaway.example"},
+		{EmailAddress: "e@gmail.com", IsFree: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ch.Disposable, ch.Free, ch.Conflicts) != "[new-trash.example] [freemail.example] [throwaway.example]" {
+		t.Errorf("Unexpected changes %+v", ch)
+	}
+
+	// Learned domains are persisted with the file entries.
+	l, err = LoadDomainList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.IsDisposable("new-trash.example") || !l.IsFree("freemail.example") || l.IsDisposable("mailinator.com") {
+		t.Error("Expected the learned domains and file entries to be persisted")
+	}
+
+	os.WriteFile(path, []byte("bogus example.com\n"), 0o644)
+	if _, err := LoadDomainList(path); err == nil {
+		t.Error("Expected an error for an unknown kind")
+	}
+}
+
+func TestReconcileGuards(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	os.WriteFile(path, []byte("not-disposable 33mail.com\nnot-free corp.example\n"), 0o644)
+	l, err := LoadDomainList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, err := l.Reconcile([]*models.EmailData{
+		// Overridden domains and their subdomains are not learned.
+		{EmailAddress: "a@33mail.com", IsDisposable: true},
+		{EmailAddress: "b@33mail.com", IsDisposable: true},
+		{EmailAddress: "c@x.33mail.com", IsDisposable: true},
+		{EmailAddress: "d@x.33mail.com", IsDisposable: true},
+		{EmailAddress: "a@corp.example", IsFree: true},
+		{EmailAddress: "b@corp.example", IsFree: true},
+		// Free domains are not learned as disposable.
+		{EmailAddress: "a@gmail.com", IsDisposable: true, IsFree: true},
+		{EmailAddress: "b@gmail.com", IsDisposable: true, IsFree: true},
+		// One address is not enough, however often it is seen.
+		{EmailAddress: "a@once.example", IsDisposable: true},
+		{EmailAddress: "A@once.example", IsDisposable: true},
+		{EmailAddress: "a@twice.example", IsDisposable: true},
+		{EmailAddress: "b@twice.example", IsDisposable: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ch.Disposable, ch.Free) != "[twice.example] []" {
+		t.Errorf("Unexpected changes %+v", ch)
+	}
+
+	l, err = LoadDomainList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.IsDisposable("33mail.com") || l.IsFree("corp.example") || l.IsDisposable("gmail.com") || !l.IsDisposable("twice.example") {
+		t.Error("Expected the overrides to survive saving")
+	}
+	b, _ := os.ReadFile(path)
+	if !strings.Contains(string(b), "not-disposable 33mail.com\n") {
+		t.Errorf("Expected the override to be persisted, got %q", b)
+	}
+
+	l.MinConfirmations = 1
+	if ch, _ := l.Reconcile([]*models.EmailData{{EmailAddress: "a@once.example", IsDisposable: true}}); len(ch.Disposable) != 1 {
+		t.Errorf("Expected one confirmation to be enough, got %+v", ch)
+	}
+}
+
+func TestReconcileBoundsPending(t *testing.T) {
+	l := NewDomainList()
+	l.MaxPending = 3
+	for i := 0; i < 10; i++ {
+		l.Reconcile([]*models.EmailData{{EmailAddress: fmt.Sprintf("a@trash%d.example", i), IsDisposable: true}})
+	}
+	if n := len(l.pending); n != 3 {
+		t.Errorf("Expected 3 pending domains, got %d", n)
+	}
+}
+
+func TestConcurrentReconcileKeepsLearnedDomains(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	l, err := LoadDomainList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.MinConfirmations = 1
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := l.Reconcile([]*models.EmailData{{EmailAddress: fmt.Sprintf("a@trash%d.example", i), IsDisposable: true}}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	l, err = LoadDomainList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if domain := fmt.Sprintf("trash%d.example", i); !l.IsDisposable(domain) {
+			t.Errorf("Expected %s to be persisted", domain)
+		}
+	}
+}
+
+func TestDomainListClient(t *testing.T) {
+	svc := fake.New().ReturnEmailValidationRequestData(&models.EmailLookupResponse{Data: []*models.EmailData{
+		{EmailAddress: "a@new-trash.example", IsDisposable: true},
+		{EmailAddress: "b@new-trash.example", IsDisposable: true},
+	}})
+	var changes []Changes
+	c := &DomainListClient{List: NewDomainList(), Next: svc, OnChange: func(ch Changes, err error) { changes = append(changes, ch) }}
+	params := operations.NewEmailValidationRequestDataParams().WithBody(&models.EmailLookupRequest{Data: []string{"a@new-trash.example"}})
+	if _, err := c.EmailValidationRequestData(params); err != nil {
+		t.Fatal(err)
+	}
+	if !c.List.IsDisposable("new-trash.example") || len(changes) != 1 {
+		t.Errorf("Expected the response to be learned, got %+v", changes)
+	}
+}
@@ -0,0 +1,224 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+	"cerberius.com/go-client/validation"
+
+	"golang.org/x/net/idna"
+)
+
+// ErrInvalidAddress is returned for addresses that cannot be normalized.
+var ErrInvalidAddress = errors.New("email: invalid address")
+
+// Rule is how a mailbox provider interprets the local part of addresses.
+type Rule struct {
+	// Alias is the canonical domain of an alias domain, such as gmail.com
+	// for googlemail.com. The rule of the canonical domain applies to
+	// aliased addresses.
+	Alias string
+
+	// IgnoreDots removes dots from the local part.
+	IgnoreDots bool
+
+	// TagSeparators are the characters that start a subaddress tag, which
+	// is removed from the local part.
+	TagSeparators string
+}
+
+// DefaultRules are the rules of the common mailbox providers.
+var DefaultRules = map[string]Rule{
+	"gmail.com":      {IgnoreDots: true, TagSeparators: "+"},
+	"googlemail.com": {Alias: "gmail.com"},
+	"outlook.com":    {TagSeparators: "+"},
+	"hotmail.com":    {TagSeparators: "+"},
+	"live.com":       {TagSeparators: "+"},
+	"msn.com":        {TagSeparators: "+"},
+	"icloud.com":     {TagSeparators: "+"},
+	"me.com":         {TagSeparators: "+"},
+	"mac.com":        {TagSeparators: "+"},
+	"fastmail.com":   {TagSeparators: "+"},
+	"proton.me":      {TagSeparators: "+"},
+	"protonmail.com": {TagSeparators: "+"},
+	"yahoo.com":      {TagSeparators: "-"},
+}
+
+// Identity is an address as entered and the canonical address of the
+// mailbox it reaches.
+type Identity struct {
+	Original  string `json:"original"`
+	Canonical string `json:"canonical"`
+}
+
+// Normalizer maps addresses to canonical addresses, so that different
+// spellings of one mailbox share cache keys and are validated once.
+type Normalizer struct {
+	// Rules are the provider rules by domain, DefaultRules if nil.
+	Rules map[string]Rule
+
+	// TagAll removes +tags from the addresses of domains without a rule.
+	TagAll bool
+}
+
+// NewNormalizer creates a new Normalizer with the default rules.
+func NewNormalizer() *Normalizer {
+	return &Normalizer{}
+}
+
+func (n *Normalizer) rule(domain string) (string, Rule) {
+	rules := n.Rules
+	if rules == nil {
+		rules = DefaultRules
+	}
+	r, ok := rules[domain]
+	if ok && r.Alias != "" {
+		domain = r.Alias
+		r, ok = rules[domain]
+	}
+	if !ok && n.TagAll {
+		r.TagSeparators = "+"
+	}
+	return domain, r
+}
+
+// Normalize returns the identity of an address. The address is case
+// folded, the domain is converted to its ASCII (punycode) form and alias
+// domains are replaced, and the provider's dot and tag rules are applied
+// to the local part. It returns an error wrapping ErrInvalidAddress if the
+// address has no local part or domain.
+func (n *Normalizer) Normalize(address string) (Identity, error) {
+	id := Identity{Original: address}
+	user, domain := splitAddress(address)
+	if user == "" || domain == "" {
+		return id, fmt.Errorf("%w: %q", ErrInvalidAddress, address)
+	}
+	domain, err := ToASCII(domain)
+	if err != nil {
+		return id, fmt.Errorf("%w: %q: %v", ErrInvalidAddress, address, err)
+	}
+
+	domain, rule := n.rule(domain)
+	user = strings.ToLower(user)
+	if i := strings.IndexAny(user, rule.TagSeparators); rule.TagSeparators != "" && i >= 0 {
+		user = user[:i]
+	}
+	if rule.IgnoreDots {
+		user = strings.ReplaceAll(user, ".", "")
+	}
+	if user == "" {
+		return id, fmt.Errorf("%w: %q has an empty mailbox", ErrInvalidAddress, address)
+	}
+	id.Canonical = user + "@" + domain
+	return id, nil
+}
+
+// Key returns the canonical address of address for use as a cache or
+// deduplication key, or the trimmed, lower-cased address if it cannot be
+// normalized.
+func (n *Normalizer) Key(address string) string {
+	if id, err := n.Normalize(address); err == nil {
+		return id.Canonical
+	}
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// Normalized is the validation result of an address with its identity.
+type Normalized struct {
+	Identity
+	Result *models.EmailData `json:"result"`
+}
+
+// Validate validates addresses by their canonical address, validating
+// every mailbox once however many spellings of it there are, and returns
+// a result per address in order. Addresses that cannot be normalized are
+// validated as entered. Result is nil if the API returned no result for
+// an address.
+func (n *Normalizer) Validate(ctx context.Context, svc operations.ClientService, addresses []string) ([]*Normalized, error) {
+	out := make([]*Normalized, len(addresses))
+	var unique []string
+	seen := make(map[string]bool)
+	for i, address := range addresses {
+		id, err := n.Normalize(address)
+		if err != nil {
+			id.Canonical = n.Key(address)
+		}
+		out[i] = &Normalized{Identity: id}
+		if !seen[id.Canonical] {
+			seen[id.Canonical] = true
+			unique = append(unique, id.Canonical)
+		}
+	}
+
+	results := make(map[string]*models.EmailData, len(unique))
+	size := validation.DefaultMaxBatchSize
+	for start := 0; start < len(unique); start += size {
+		batch := unique[start:min(start+size, len(unique))]
+		params := operations.NewEmailValidationRequestDataParamsWithContext(ctx).
+			WithBody(&models.EmailLookupRequest{Data: batch})
+		resp, err := svc.EmailValidationRequestData(params)
+		if err != nil {
+			return nil, fmt.Errorf("email: validating canonical addresses: %w", err)
+		}
+		if resp.Payload == nil {
+			continue
+		}
+		for _, ed := range resp.Payload.Data {
+			if ed != nil {
+				results[strings.ToLower(ed.EmailAddress)] = ed
+			}
+		}
+	}
+	for _, nr := range out {
+		nr.Result = results[nr.Canonical]
+	}
+	return out, nil
+}
+
+// MultiAccounts returns the canonical addresses that were entered with
+// more than one spelling, with their distinct spellings in order.
+func MultiAccounts(ids []Identity) map[string][]string {
+	spellings := make(map[string][]string)
+	for _, id := range ids {
+		if id.Canonical == "" {
+			continue
+		}
+		original := strings.TrimSpace(id.Original)
+		dup := false
+		for _, s := range spellings[id.Canonical] {
+			dup = dup || s == original
+		}
+		if !dup {
+			spellings[id.Canonical] = append(spellings[id.Canonical], original)
+		}
+	}
+	for canonical, s := range spellings {
+		if len(s) < 2 {
+			delete(spellings, canonical)
+		}
+	}
+	return spellings
+}
+
+// ToASCII converts a domain to its lower-cased ASCII form with the IDNA
+// lookup profile: labels are mapped and normalized (NFC) as UTS #46
+// requires and internationalized labels are encoded with punycode, so
+// that equivalent spellings of a domain share one form.
+func ToASCII(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", err
+	}
+	ascii = strings.TrimSuffix(ascii, ".")
+	for _, label := range strings.Split(ascii, ".") {
+		if label == "" {
+			return "", errors.New("empty domain label")
+		}
+	}
+	return ascii, nil
+}
@@ -0,0 +1,299 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+)
+
+// Defaults for the zero fields of Suggester.
+const (
+	DefaultMaxValidityScore  = 80
+	DefaultMinSuggestedScore = 50
+)
+
+// Source is where a suggestion comes from.
+type Source string
+
+const (
+	SourceAPI   Source = "api"   // SourceAPI is the DidYouMean field of the validation result.
+	SourceLocal Source = "local" // SourceLocal is the local typo engine.
+)
+
+// KnownProviders are the mailbox providers the local typo engine corrects
+// to, most popular first. Suggestions for these domains are considered
+// reputable without re-validation, and the domains themselves are never
+// corrected, so the list also holds providers whose names are close to a
+// more popular one.
+var KnownProviders = []string{
+	"gmail.com", "yahoo.com", "hotmail.com", "outlook.com", "icloud.com",
+	"aol.com", "live.com", "msn.com", "me.com", "googlemail.com",
+	"protonmail.com", "proton.me", "gmx.com", "gmx.de", "web.de",
+	"yandex.ru", "mail.ru", "comcast.net", "yahoo.co.uk", "hotmail.co.uk",
+	"ymail.com", "mail.com", "email.com", "gmx.net", "rocketmail.com",
+	"hotmail.fr", "hotmail.de", "live.co.uk", "mac.com", "inbox.com",
+}
+
+// tldTypos maps common misspellings of top-level domains to the intended
+// domain.
+var tldTypos = map[string]string{
+	"con": "com", "cmo": "com", "ocm": "com", "comm": "com", "cpm": "com", "vom": "com", "xom": "com", "om": "com",
+	"nte": "net", "ner": "net", "nett": "net",
+	"ogr": "org", "orgg": "org",
+}
+
+// Recommendation is the outcome of Suggester.Suggest, ready to be rendered
+// by a signup form.
+type Recommendation struct {
+	Email      string `json:"email"`      // Email is the address as entered.
+	Suggestion string `json:"suggestion"` // Suggestion is the suggested address.
+	Source     Source `json:"source"`     // Source is where the suggestion comes from.
+
+	// Confirm reports whether the user should be asked to confirm the
+	// address, with Message as the prompt.
+	Confirm bool   `json:"confirm"`
+	Message string `json:"message,omitempty"`
+
+	// Reason explains the decision, for logging.
+	Reason string `json:"reason"`
+
+	// Result is the validation result of Email, and SuggestionResult the
+	// validation result of Suggestion if it was re-validated.
+	Result           *models.EmailData `json:"result,omitempty"`
+	SuggestionResult *models.EmailData `json:"suggestion_result,omitempty"`
+}
+
+// Suggester decides whether a typo suggestion is worth showing.
+type Suggester struct {
+	// Service is the operations client used to re-validate suggestions.
+	Service operations.ClientService
+
+	// Revalidate enables re-validation of suggestions that are not for a
+	// known provider. It requires Service.
+	Revalidate bool
+
+	// MaxValidityScore is the ValidityScore from which an address is
+	// considered fine as entered and no suggestion is shown,
+	// DefaultMaxValidityScore if zero.
+	MaxValidityScore int64
+
+	// MinSuggestedScore is the ValidityScore a re-validated suggestion must
+	// reach to be shown, DefaultMinSuggestedScore if zero.
+	MinSuggestedScore int64
+
+	// Providers are the reputable domains of the local typo engine,
+	// KnownProviders if nil.
+	Providers []string
+}
+
+// NewSuggester creates a new Suggester that re-validates suggestions with
+// svc.
+func NewSuggester(svc operations.ClientService) *Suggester {
+	return &Suggester{Service: svc, Revalidate: svc != nil}
+}
+
+// Suggest returns a recommendation for the validation result ed, or nil
+// if neither the API nor the local engine has a suggestion. An error is
+// only returned if re-validating the suggestion fails.
+func (s *Suggester) Suggest(ctx context.Context, ed *models.EmailData) (*Recommendation, error) {
+	if ed == nil {
+		return nil, nil
+	}
+	user, domain := splitAddress(ed.EmailAddress)
+	if ed.User != "" {
+		user = ed.User
+	}
+	if ed.Domain != "" {
+		domain = ed.Domain
+	}
+
+	rec := &Recommendation{Email: ed.EmailAddress, Result: ed, Source: SourceAPI}
+	switch {
+	case ed.DidYouMean != "" && strings.Contains(ed.DidYouMean, "@"):
+		rec.Suggestion = ed.DidYouMean
+	case ed.DidYouMean != "":
+		rec.Suggestion = user + "@" + ed.DidYouMean
+	case ed.MX != "":
+		// The domain receives mail, so it is not corrected locally.
+		return nil, nil
+	default:
+		fixed, ok := SuggestDomain(domain, s.providers())
+		if !ok {
+			return nil, nil
+		}
+		rec.Suggestion = user + "@" + fixed
+		rec.Source = SourceLocal
+	}
+	if strings.EqualFold(rec.Suggestion, rec.Email) {
+		return nil, nil
+	}
+
+	maxScore := s.MaxValidityScore
+	if maxScore == 0 {
+		maxScore = DefaultMaxValidityScore
+	}
+	if ed.ValidityScore >= maxScore && ed.SMTPValid {
+		rec.Reason = fmt.Sprintf("address is valid as entered (score %d)", ed.ValidityScore)
+		return rec, nil
+	}
+
+	_, suggestedDomain := splitAddress(rec.Suggestion)
+	if !s.isProvider(suggestedDomain) {
+		if !s.Revalidate || s.Service == nil {
+			rec.Reason = fmt.Sprintf("suggested domain %s is not a known provider", suggestedDomain)
+			return rec, nil
+		}
+		result, err := s.validate(ctx, rec.Suggestion)
+		if err != nil {
+			return nil, err
+		}
+		rec.SuggestionResult = result
+		minScore := s.MinSuggestedScore
+		if minScore == 0 {
+			minScore = DefaultMinSuggestedScore
+		}
+		switch {
+		case result == nil || result.ValidityScore < minScore:
+			rec.Reason = "suggestion does not validate"
+			return rec, nil
+		case result.IsDisposable:
+			rec.Reason = "suggestion is a disposable address"
+			return rec, nil
+		}
+	}
+
+	rec.Confirm = true
+	rec.Message = fmt.Sprintf("Did you mean %s?", rec.Suggestion)
+	if rec.Source == SourceLocal {
+		rec.Reason = "local typo correction"
+	} else {
+		rec.Reason = "API suggestion"
+	}
+	return rec, nil
+}
+
+// validate validates a single address.
+func (s *Suggester) validate(ctx context.Context, address string) (*models.EmailData, error) {
+	params := operations.NewEmailValidationRequestDataParamsWithContext(ctx).
+		WithBody(&models.EmailLookupRequest{Data: []string{address}})
+	resp, err := s.Service.EmailValidationRequestData(params)
+	if err != nil {
+		return nil, fmt.Errorf("email: re-validating suggestion: %w", err)
+	}
+	if resp.Payload == nil || len(resp.Payload.Data) == 0 {
+		return nil, nil
+	}
+	return resp.Payload.Data[0], nil
+}
+
+func (s *Suggester) providers() []string {
+	if s.Providers != nil {
+		return s.Providers
+	}
+	return KnownProviders
+}
+
+func (s *Suggester) isProvider(domain string) bool {
+	for _, p := range s.providers() {
+		if strings.EqualFold(p, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// SuggestDomain returns the intended domain for a misspelled one, or false
+// if domain looks right. It matches providers within a small edit distance
+// and fixes common misspellings of top-level domains. Domains that are
+// providers themselves are never corrected, and the shorter the domain
+// name, the fewer edits are allowed, since short names are often real
+// domains one edit away from a provider.
+func SuggestDomain(domain string, providers []string) (string, bool) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if domain == "" {
+		return "", false
+	}
+	for _, p := range providers {
+		if domain == p {
+			return "", false
+		}
+	}
+
+	candidate := domain
+	if i := strings.LastIndexByte(domain, '.'); i >= 0 {
+		if tld, ok := tldTypos[domain[i+1:]]; ok {
+			candidate = domain[:i+1] + tld
+		}
+	}
+
+	// Names of fewer than 4 characters are not corrected, names of fewer
+	// than 6 only by a substitution or transposition, and long domains by
+	// up to two edits.
+	name, _, _ := strings.Cut(candidate, ".")
+	maxDist, sameLength := 1, false
+	switch {
+	case len(name) < 4:
+		maxDist = 0
+	case len(name) < 6:
+		sameLength = true
+	case len(candidate) >= 12:
+		maxDist = 2
+	}
+	best, bestDist := "", maxDist+1
+	for _, p := range providers {
+		if sameLength && len(p) != len(candidate) {
+			continue
+		}
+		if d := editDistance(candidate, p); d < bestDist {
+			best, bestDist = p, d
+		}
+	}
+	switch {
+	case best != "":
+		return best, true
+	case candidate != domain:
+		return candidate, true
+	}
+	return "", false
+}
+
+// editDistance returns the optimal string alignment distance between a
+// and b: the number of insertions, deletions, substitutions and adjacent
+// transpositions needed to turn one into the other.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+// splitAddress splits an address at its last @.
+func splitAddress(address string) (user, domain string) {
+	address = strings.TrimSpace(address)
+	i := strings.LastIndexByte(address, '@')
+	if i < 0 {
+		return address, ""
+	}
+	return address[:i], address[i+1:]
+}
@@ -0,0 +1,92 @@
+package enrich
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrCheckpointMismatch is returned by RunFiles when the checkpoint was
+// written by a run with a different input file or settings.
+var ErrCheckpointMismatch = errors.New("enrich: checkpoint belongs to a different run")
+
+// checkpoint records how far a pipeline run has progressed, and the input
+// and settings of the run so that a resume with others is refused.
+type checkpoint struct {
+	Rows   int64 `json:"rows"`   // Rows is the number of input data rows written to the output.
+	Offset int64 `json:"offset"` // Offset is the size of the output file after those rows.
+
+	Input  inputIdentity `json:"input"`
+	Format Format        `json:"format"`
+	Column string        `json:"column"`
+	Fields []string      `json:"fields"`
+}
+
+// inputIdentity identifies an input file.
+type inputIdentity struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// sameRun returns an error wrapping ErrCheckpointMismatch if cp was not
+// written by a run like other.
+func (cp *checkpoint) sameRun(other *checkpoint) error {
+	switch {
+	case cp.Input.Path != other.Input.Path || cp.Input.Size != other.Input.Size || !cp.Input.ModTime.Equal(other.Input.ModTime):
+		return fmt.Errorf("%w: input was %s (%d bytes, modified %s)", ErrCheckpointMismatch, cp.Input.Path, cp.Input.Size, cp.Input.ModTime)
+	case cp.Format != other.Format || cp.Column != other.Column:
+		return fmt.Errorf("%w: format or column differ", ErrCheckpointMismatch)
+	case strings.Join(cp.Fields, ",") != strings.Join(other.Fields, ","):
+		return fmt.Errorf("%w: fields were %s", ErrCheckpointMismatch, strings.Join(cp.Fields, ","))
+	}
+	return nil
+}
+
+// loadCheckpoint reads the checkpoint at path. It returns nil and no error
+// if the file does not exist.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return nil, fmt.Errorf("enrich: invalid checkpoint %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// saveCheckpoint atomically replaces the checkpoint at path with cp.
+func saveCheckpoint(path string, cp *checkpoint) error {
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,556 @@
+// Package enrich appends Cerberius email validation results to CSV and
+// NDJSON files.
+//
+// A Pipeline streams its input, validates the chosen email column in batches
+// through the email validation operation and writes every input row back with
+// the selected models.EmailData fields appended. Only one batch is held in
+// memory at a time, so arbitrarily large files can be processed. When a
+// checkpoint file is configured, RunFiles records its progress after every
+// batch and a crashed run resumes where it stopped without validating the
+// already processed rows again.
+package enrich
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+)
+
+// Format is the file format of the pipeline input and output.
+type Format int
+
+const (
+	// FormatCSV is comma-separated values with a header row.
+	FormatCSV Format = iota
+	// FormatNDJSON is newline-delimited JSON with one object per line.
+	FormatNDJSON
+)
+
+// DefaultBatchSize is the number of rows validated per request when
+// Pipeline.BatchSize is not set.
+const DefaultBatchSize = 100
+
+// DefaultFields are the models.EmailData fields appended when
+// Pipeline.Fields is empty. Field names are the JSON names of the model.
+var DefaultFields = []string{"validity_score", "is_disposable", "is_free", "did_you_mean", "comment"}
+
+// ErrColumnNotFound is returned when the configured email column is missing
+// from the CSV header.
+var ErrColumnNotFound = errors.New("enrich: email column not found")
+
+// Pipeline validates the email column of a CSV or NDJSON stream.
+type Pipeline struct {
+	Service operations.ClientService // Service is the operations client used for validation.
+	Format  Format                   // Format is the input and output format.
+	Column  string                   // Column is the CSV header or NDJSON key that holds the email address.
+	Fields  []string                 // Fields are the models.EmailData JSON names to append, DefaultFields if empty.
+
+	// BatchSize is the number of rows validated per request,
+	// DefaultBatchSize if zero.
+	BatchSize int
+
+	// CheckpointPath is the file used by RunFiles to record progress.
+	// Checkpointing is disabled if it is empty.
+	CheckpointPath string
+}
+
+// Stats summarizes a pipeline run.
+type Stats struct {
+	Rows      int64 // Rows is the number of data rows written in this run.
+	Validated int64 // Validated is the number of email addresses sent for validation.
+	Skipped   int64 // Skipped is the number of rows without an email address.
+	Batches   int64 // Batches is the number of validation requests made.
+	Resumed   int64 // Resumed is the number of rows skipped because a checkpoint recorded them.
+}
+
+// Run reads rows from r and writes the enriched rows to w. It does not use
+// the checkpoint file; see RunFiles for resumable runs.
+func (p *Pipeline) Run(ctx context.Context, r io.Reader, w io.Writer) (*Stats, error) {
+	return p.run(ctx, r, w, nil, nil)
+}
+
+// RunFiles enriches the file at inPath into the file at outPath. If
+// CheckpointPath is set and a checkpoint from an earlier run exists, the
+// output is truncated to the last recorded batch and processing resumes
+// after the rows that batch covered. A checkpoint of a run with a different
+// input file, format, column or fields is refused with an error wrapping
+// ErrCheckpointMismatch. The checkpoint is removed once the run completes.
+func (p *Pipeline) RunFiles(ctx context.Context, inPath, outPath string) (*Stats, error) {
+	in, err := os.Open(inPath)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	run, err := p.identity(in, inPath)
+	if err != nil {
+		return nil, err
+	}
+	var cp *checkpoint
+	if p.CheckpointPath != "" {
+		if cp, err = loadCheckpoint(p.CheckpointPath); err != nil {
+			return nil, err
+		}
+		if cp != nil {
+			if err := cp.sameRun(run); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	out, err := os.OpenFile(outPath, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+
+	// Discard anything written after the last checkpoint, or everything
+	// when starting from scratch.
+	var offset int64
+	if cp != nil {
+		offset = cp.Offset
+		fi, err := out.Stat()
+		if err != nil {
+			return nil, err
+		}
+		if fi.Size() < offset {
+			return nil, fmt.Errorf("%w: output %s has %d bytes, the checkpoint expects at least %d", ErrCheckpointMismatch, outPath, fi.Size(), offset)
+		}
+	}
+	if err := out.Truncate(offset); err != nil {
+		return nil, err
+	}
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var save func(rows, offset int64) error
+	if p.CheckpointPath != "" {
+		save = func(rows, offset int64) error {
+			if err := out.Sync(); err != nil {
+				return err
+			}
+			progress := *run
+			progress.Rows, progress.Offset = rows, offset
+			return saveCheckpoint(p.CheckpointPath, &progress)
+		}
+	}
+
+	stats, err := p.run(ctx, in, out, cp, save)
+	if err != nil {
+		return stats, err
+	}
+	if p.CheckpointPath != "" {
+		if err := os.Remove(p.CheckpointPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// fields returns the fields to append.
+func (p *Pipeline) fields() []string {
+	if len(p.Fields) == 0 {
+		return DefaultFields
+	}
+	return p.Fields
+}
+
+// identity returns a checkpoint without progress that identifies a run of
+// the pipeline over the input file in.
+func (p *Pipeline) identity(in *os.File, inPath string) (*checkpoint, error) {
+	fi, err := in.Stat()
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(inPath)
+	if err != nil {
+		return nil, err
+	}
+	return &checkpoint{
+		Input:  inputIdentity{Path: abs, Size: fi.Size(), ModTime: fi.ModTime().UTC()},
+		Format: p.Format,
+		Column: p.Column,
+		Fields: p.fields(),
+	}, nil
+}
+
+// run drives the pipeline. resume, if not nil, holds the progress of an
+// earlier run whose output has already been written to w. save, if not nil,
+// is called after every batch with the total number of data rows and bytes
+// written so far.
+func (p *Pipeline) run(ctx context.Context, r io.Reader, w io.Writer, resume *checkpoint, save func(rows, offset int64) error) (*Stats, error) {
+	fields := p.fields()
+	getters, err := fieldGetters(fields)
+	if err != nil {
+		return nil, err
+	}
+	batchSize := p.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	var offset, rowsDone int64
+	if resume != nil {
+		offset, rowsDone = resume.Offset, resume.Rows
+	}
+	cw := &countingWriter{w: w, n: offset}
+	bw := bufio.NewWriter(cw)
+
+	var codec rowCodec
+	switch p.Format {
+	case FormatCSV:
+		codec, err = newCSVCodec(r, bw, p.Column, fields, resume == nil)
+	case FormatNDJSON:
+		codec = newNDJSONCodec(r, bw, p.Column, fields)
+	default:
+		err = fmt.Errorf("enrich: unknown format %d", p.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{}
+	for ; stats.Resumed < rowsDone; stats.Resumed++ {
+		if _, _, err := codec.read(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return stats, err
+		}
+	}
+
+	batch := make([]row, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			// Nothing to validate, but buffered output such as the
+			// CSV header must still be written.
+			if err := codec.flush(); err != nil {
+				return err
+			}
+			return bw.Flush()
+		}
+		if err := p.validate(ctx, batch, stats); err != nil {
+			return err
+		}
+		for _, rw := range batch {
+			values := make([]any, len(getters))
+			for i, get := range getters {
+				values[i] = get(rw.data)
+			}
+			if err := codec.write(rw.raw, values); err != nil {
+				return err
+			}
+		}
+		stats.Rows += int64(len(batch))
+		batch = batch[:0]
+
+		if err := codec.flush(); err != nil {
+			return err
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if save != nil {
+			return save(rowsDone+stats.Rows, cw.n)
+		}
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		raw, email, err := codec.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+		batch = append(batch, row{raw: raw, email: strings.TrimSpace(email)})
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// validate sends the email addresses of batch for validation and attaches
+// the results to the rows.
+func (p *Pipeline) validate(ctx context.Context, batch []row, stats *Stats) error {
+	var emails []string
+	seen := make(map[string]bool, len(batch))
+	for _, rw := range batch {
+		if rw.email == "" {
+			stats.Skipped++
+			continue
+		}
+		key := strings.ToLower(rw.email)
+		if !seen[key] {
+			seen[key] = true
+			emails = append(emails, rw.email)
+		}
+	}
+	if len(emails) == 0 {
+		return nil
+	}
+
+	params := operations.NewEmailValidationRequestDataParamsWithContext(ctx).
+		WithBody(&models.EmailLookupRequest{Data: emails})
+	resp, err := p.Service.EmailValidationRequestData(params)
+	if err != nil {
+		return fmt.Errorf("enrich: validating batch of %d addresses: %w", len(emails), err)
+	}
+	stats.Batches++
+	stats.Validated += int64(len(emails))
+
+	var results []*models.EmailData
+	if resp.Payload != nil {
+		results = resp.Payload.Data
+	}
+	byEmail := make(map[string]*models.EmailData, len(results))
+	for i, ed := range results {
+		if ed == nil {
+			continue
+		}
+		key := strings.ToLower(ed.EmailAddress)
+		if key == "" && i < len(emails) {
+			// Fall back to the request order if the address is not echoed.
+			key = strings.ToLower(emails[i])
+		}
+		byEmail[key] = ed
+	}
+	for i := range batch {
+		batch[i].data = byEmail[strings.ToLower(batch[i].email)]
+	}
+	return nil
+}
+
+// row is a single input row waiting for its validation result.
+type row struct {
+	raw   any               // raw is the codec specific representation of the input row.
+	email string            // email is the value of the email column.
+	data  *models.EmailData // data is the validation result, nil if there is none.
+}
+
+// rowCodec reads input rows and writes enriched output rows.
+type rowCodec interface {
+	// read returns the next data row and its email address, or io.EOF.
+	read() (raw any, email string, err error)
+	// write writes raw with values appended. values are nil for rows
+	// without a validation result.
+	write(raw any, values []any) error
+	// flush flushes any buffered output to the underlying writer.
+	flush() error
+}
+
+// csvCodec reads and writes CSV rows.
+type csvCodec struct {
+	r      *csv.Reader
+	w      *csv.Writer
+	column int
+}
+
+// newCSVCodec reads the header row from r and, if writeHeader is set,
+// writes the extended header row to w.
+func newCSVCodec(r io.Reader, w io.Writer, column string, fields []string, writeHeader bool) (*csvCodec, error) {
+	c := &csvCodec{r: csv.NewReader(r), w: csv.NewWriter(w), column: -1}
+	c.r.FieldsPerRecord = -1
+
+	header, err := c.r.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("enrich: empty CSV input")
+		}
+		return nil, err
+	}
+	for i, name := range header {
+		if strings.TrimSpace(name) == column {
+			c.column = i
+			break
+		}
+	}
+	if c.column < 0 {
+		return nil, fmt.Errorf("%w: %q", ErrColumnNotFound, column)
+	}
+	if writeHeader {
+		if err := c.w.Write(append(header, fields...)); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *csvCodec) read() (any, string, error) {
+	record, err := c.r.Read()
+	if err != nil {
+		return nil, "", err
+	}
+	var email string
+	if c.column < len(record) {
+		email = record[c.column]
+	}
+	return record, email, nil
+}
+
+func (c *csvCodec) write(raw any, values []any) error {
+	record := raw.([]string)
+	for _, v := range values {
+		if v == nil {
+			record = append(record, "")
+			continue
+		}
+		record = append(record, fmt.Sprint(v))
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvCodec) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonCodec reads and writes newline-delimited JSON objects. Input objects
+// are written back byte for byte with the new fields appended, except
+// objects that already have a key of an appended field, which are
+// re-encoded without it so that the appended value replaces it.
+type ndjsonCodec struct {
+	r      *bufio.Reader
+	w      io.Writer
+	column string
+	fields []string
+	line   int
+}
+
+func newNDJSONCodec(r io.Reader, w io.Writer, column string, fields []string) *ndjsonCodec {
+	return &ndjsonCodec{r: bufio.NewReader(r), w: w, column: column, fields: fields}
+}
+
+func (c *ndjsonCodec) read() (any, string, error) {
+	for {
+		line, err := c.r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return nil, "", err
+		}
+		if err != nil && err != io.EOF {
+			return nil, "", err
+		}
+		c.line++
+
+		line = []byte(strings.TrimSpace(string(line)))
+		if len(line) == 0 {
+			continue
+		}
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(line, &obj); err != nil {
+			return nil, "", fmt.Errorf("enrich: line %d: %w", c.line, err)
+		}
+		if obj == nil {
+			// null unmarshals into a nil map without an error.
+			return nil, "", fmt.Errorf("enrich: line %d: not a JSON object", c.line)
+		}
+		var email string
+		if v, ok := obj[c.column]; ok {
+			// Non-string values are treated as a missing address.
+			_ = json.Unmarshal(v, &email)
+		}
+		replaced := false
+		for _, f := range c.fields {
+			if _, ok := obj[f]; ok {
+				delete(obj, f)
+				replaced = true
+			}
+		}
+		if replaced {
+			if line, err = json.Marshal(obj); err != nil {
+				return nil, "", err
+			}
+		}
+		return line, email, nil
+	}
+}
+
+func (c *ndjsonCodec) write(raw any, values []any) error {
+	line := raw.([]byte)
+	// line is a valid JSON object, so it ends with '}'.
+	body := strings.TrimSpace(string(line[:len(line)-1]))
+
+	var sb strings.Builder
+	sb.WriteString(body)
+	for i, v := range values {
+		if !strings.HasSuffix(body, "{") || i > 0 {
+			sb.WriteByte(',')
+		}
+		name, _ := json.Marshal(c.fields[i])
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		sb.Write(name)
+		sb.WriteByte(':')
+		sb.Write(value)
+	}
+	sb.WriteString("}\n")
+
+	_, err := io.WriteString(c.w, sb.String())
+	return err
+}
+
+func (c *ndjsonCodec) flush() error {
+	return nil
+}
+
+// fieldGetters returns one accessor per requested models.EmailData field.
+// Accessors return nil when the row has no validation result.
+func fieldGetters(fields []string) ([]func(*models.EmailData) any, error) {
+	t := reflect.TypeOf(models.EmailData{})
+	index := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		index[name] = i
+	}
+
+	getters := make([]func(*models.EmailData) any, len(fields))
+	for i, name := range fields {
+		idx, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("enrich: unknown EmailData field %q", name)
+		}
+		getters[i] = func(ed *models.EmailData) any {
+			if ed == nil {
+				return nil
+			}
+			return reflect.ValueOf(ed).Elem().Field(idx).Interface()
+		}
+	}
+	return getters, nil
+}
+
+// countingWriter counts the bytes written through it, starting at n.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,245 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+
+	"github.com/go-openapi/runtime"
+)
+
+// stubService validates every address containing "valid" with a score of 90
+// and everything else with a score of 10.
+type stubService struct {
+	batches  [][]string // email batches received, in order
+	failFrom int        // fail every batch from this (1-based) batch number if set
+}
+
+func (s *stubService) EmailValidationRequestData(params *operations.EmailValidationRequestDataParams, _ ...operations.ClientOption) (*operations.EmailValidationRequestDataOK, error) {
+	if s.failFrom > 0 && len(s.batches)+1 >= s.failFrom {
+		return nil, errors.New("connection reset")
+	}
+	s.batches = append(s.batches, params.Body.Data)
+
+	payload := &models.EmailLookupResponse{}
+	for _, email := range params.Body.Data {
+		ed := &models.EmailData{EmailAddress: email, ValidityScore: 10}
+		if strings.Contains(email, "valid") {
+			ed.ValidityScore = 90
+		}
+		payload.Data = append(payload.Data, ed)
+	}
+	return &operations.EmailValidationRequestDataOK{Payload: payload}, nil
+}
+
+func (s *stubService) IPLookupRequestData(*operations.IPLookupRequestDataParams, ...operations.ClientOption) (*operations.IPLookupRequestDataOK, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *stubService) PromptCheckRequestData(*operations.PromptCheckRequestDataParams, ...operations.ClientOption) (*operations.PromptCheckRequestDataOK, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *stubService) SetTransport(runtime.ClientTransport) {}
+
+func TestRunCSV(t *testing.T) {
+	input := "name,email\nAlice,alice@valid.example\nBob,\nCarol,carol@example.com\n"
+	svc := &stubService{}
+	p := &Pipeline{Service: svc, Format: FormatCSV, Column: "email", Fields: []string{"validity_score", "is_free"}, BatchSize: 2}
+
+	var out bytes.Buffer
+	stats, err := p.Run(context.Background(), strings.NewReader(input), &out)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	expected := "name,email,validity_score,is_free\n" +
+		"Alice,alice@valid.example,90,false\n" +
+		"Bob,,,\n" +
+		"Carol,carol@example.com,10,false\n"
+	if out.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", out.String(), expected)
+	}
+	if stats.Rows != 3 || stats.Validated != 2 || stats.Skipped != 1 || stats.Batches != 2 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestRunCSVMissingColumn(t *testing.T) {
+	p := &Pipeline{Service: &stubService{}, Format: FormatCSV, Column: "mail"}
+	_, err := p.Run(context.Background(), strings.NewReader("name,email\n"), &bytes.Buffer{})
+	if !errors.Is(err, ErrColumnNotFound) {
+		t.Errorf("Expected ErrColumnNotFound, got %v", err)
+	}
+}
+
+func TestRunCSVHeaderOnly(t *testing.T) {
+	p := &Pipeline{Service: &stubService{}, Format: FormatCSV, Column: "email", Fields: []string{"validity_score"}}
+
+	var out bytes.Buffer
+	if _, err := p.Run(context.Background(), strings.NewReader("name,email\n"), &out); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if out.String() != "name,email,validity_score\n" {
+		t.Errorf("Unexpected output: %q", out.String())
+	}
+}
+
+func TestRunNDJSON(t *testing.T) {
+	input := `{"id":1,"email":"a@valid.example"}` + "\n\n" + `{}` + "\n" + `{"id":3,"email":"b@example.com"}`
+	p := &Pipeline{Service: &stubService{}, Format: FormatNDJSON, Column: "email", Fields: []string{"validity_score"}}
+
+	var out bytes.Buffer
+	if _, err := p.Run(context.Background(), strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	expected := `{"id":1,"email":"a@valid.example","validity_score":90}` + "\n" +
+		`{"validity_score":null}` + "\n" +
+		`{"id":3,"email":"b@example.com","validity_score":10}` + "\n"
+	if out.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", out.String(), expected)
+	}
+}
+
+func TestRunNDJSONReplacesExistingField(t *testing.T) {
+	input := `{"email":"a@valid.example","validity_score":"stale"}`
+	p := &Pipeline{Service: &stubService{}, Format: FormatNDJSON, Column: "email", Fields: []string{"validity_score"}}
+
+	var out bytes.Buffer
+	if _, err := p.Run(context.Background(), strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	expected := `{"email":"a@valid.example","validity_score":90}` + "\n"
+	if out.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", out.String(), expected)
+	}
+}
+
+func TestRunNDJSONRejectsNonObjects(t *testing.T) {
+	p := &Pipeline{Service: &stubService{}, Format: FormatNDJSON, Column: "email", Fields: []string{"validity_score"}}
+	for _, input := range []string{"null", `[1,2]`, `"a@valid.example"`} {
+		var out bytes.Buffer
+		if _, err := p.Run(context.Background(), strings.NewReader(`{"email":"a@valid.example"}`+"\n"+input), &out); err == nil {
+			t.Errorf("Expected an error for %s, got output %q", input, out.String())
+		}
+	}
+}
+
+func TestRunFilesResumesFromCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "in.csv")
+	outPath := filepath.Join(dir, "out.csv")
+	cpPath := filepath.Join(dir, "out.csv.checkpoint")
+
+	input := "email\na@valid.example\nb@example.com\nc@valid.example\nd@example.com\ne@example.com\n"
+	if err := os.WriteFile(inPath, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first run dies on its second batch.
+	crashing := &stubService{failFrom: 2}
+	p := &Pipeline{Service: crashing, Format: FormatCSV, Column: "email", Fields: []string{"validity_score"}, BatchSize: 2, CheckpointPath: cpPath}
+	if _, err := p.RunFiles(context.Background(), inPath, outPath); err == nil {
+		t.Fatal("Expected first run to fail")
+	}
+	if _, err := os.Stat(cpPath); err != nil {
+		t.Fatalf("Expected checkpoint after failed run: %v", err)
+	}
+
+	// The second run must only validate the rows after the first batch.
+	svc := &stubService{}
+	p.Service = svc
+	stats, err := p.RunFiles(context.Background(), inPath, outPath)
+	if err != nil {
+		t.Fatalf("Resumed run failed: %v", err)
+	}
+	if stats.Resumed != 2 || stats.Rows != 3 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	for _, batch := range svc.batches {
+		for _, email := range batch {
+			if email == "a@valid.example" || email == "b@example.com" {
+				t.Errorf("Already processed address %q was validated again", email)
+			}
+		}
+	}
+
+	got, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "email,validity_score\na@valid.example,90\nb@example.com,10\nc@valid.example,90\nd@example.com,10\ne@example.com,10\n"
+	if string(got) != expected {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", got, expected)
+	}
+	if _, err := os.Stat(cpPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected checkpoint to be removed after a complete run, got %v", err)
+	}
+}
+
+func TestRunFilesRejectsForeignCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "in.csv")
+	outPath := filepath.Join(dir, "out.csv")
+	cpPath := filepath.Join(dir, "out.csv.checkpoint")
+	if err := os.WriteFile(inPath, []byte("email\na@valid.example\nb@example.com\nc@example.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Pipeline{Service: &stubService{failFrom: 2}, Format: FormatCSV, Column: "email", Fields: []string{"validity_score"}, BatchSize: 2, CheckpointPath: cpPath}
+	if _, err := p.RunFiles(context.Background(), inPath, outPath); err == nil {
+		t.Fatal("Expected first run to fail")
+	}
+
+	// Resuming with other fields would mix two column layouts.
+	p.Service = &stubService{}
+	p.Fields = []string{"validity_score", "is_free"}
+	if _, err := p.RunFiles(context.Background(), inPath, outPath); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Errorf("Expected ErrCheckpointMismatch for changed fields, got %v", err)
+	}
+
+	// So would resuming over a changed input file.
+	p.Fields = []string{"validity_score"}
+	if err := os.WriteFile(inPath, []byte("email\nz@example.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.RunFiles(context.Background(), inPath, outPath); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Errorf("Expected ErrCheckpointMismatch for changed input, got %v", err)
+	}
+}
+
+func TestRunFilesRejectsTruncatedOutput(t *testing.T) {
+	dir := t.TempDir()
+	inPath := filepath.Join(dir, "in.csv")
+	outPath := filepath.Join(dir, "out.csv")
+	cpPath := filepath.Join(dir, "out.csv.checkpoint")
+	if err := os.WriteFile(inPath, []byte("email\na@valid.example\nb@example.com\nc@example.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Pipeline{Service: &stubService{failFrom: 2}, Format: FormatCSV, Column: "email", Fields: []string{"validity_score"}, BatchSize: 2, CheckpointPath: cpPath}
+	if _, err := p.RunFiles(context.Background(), inPath, outPath); err == nil {
+		t.Fatal("Expected first run to fail")
+	}
+
+	// Resuming must not pad an output that is shorter than the checkpoint.
+	if err := os.WriteFile(outPath, []byte("email,"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p.Service = &stubService{}
+	if _, err := p.RunFiles(context.Background(), inPath, outPath); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Errorf("Expected ErrCheckpointMismatch for a truncated output, got %v", err)
+	}
+	if got, _ := os.ReadFile(outPath); string(got) != "email," {
+		t.Errorf("Expected the output to be left alone, got %q", got)
+	}
+}
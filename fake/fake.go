@@ -0,0 +1,54 @@
+// Package fake provides a programmable fake of operations.ClientService for
+// unit tests.
+//
+// The Fake type is generated from the operations package, so it always
+// implements the current interface:
+//
+//	svc := fake.New().
+//		ReturnIPLookupRequestData(&models.IPLookupResponse{Data: []*models.IPData{{IPAddress: "8.8.8.8"}}}).
+//		FailEmailValidationRequestData(402, 100402, "Not enough service credit balance for requested feature")
+//
+//	// ... exercise code that uses svc ...
+//
+//	svc.AssertIPLookupRequestDataCalled(t, 1)
+//	svc.AssertIPLookupRequestDataCalledWith(t, &models.IPLookupRequest{Data: []string{"8.8.8.8"}})
+//
+// Errors queued with the Fail methods are the real *Default response types
+// of the operations package, so error handling code is exercised exactly as
+// with the live service.
+package fake
+
+//go:generate go run ../internal/genfake -src ../generated/client/operations -out fake_gen.go
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoResponse is matched by errors.Is for every *NoResponseError.
+var ErrNoResponse = errors.New("fake: no response programmed")
+
+// NoResponseError is returned for a call to an operation that has no queued
+// response and no handler function.
+type NoResponseError struct {
+	Operation string
+}
+
+func (e *NoResponseError) Error() string {
+	return fmt.Sprintf("fake: no response programmed for %s", e.Operation)
+}
+
+// Is reports whether target is ErrNoResponse.
+func (e *NoResponseError) Is(target error) bool {
+	return target == ErrNoResponse
+}
+
+// formatBodies formats request bodies for assertion failures.
+func formatBodies[T any](bodies []T) string {
+	parts := make([]string, len(bodies))
+	for i, b := range bodies {
+		parts[i] = fmt.Sprintf("%+v", b)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
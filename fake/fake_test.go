@@ -0,0 +1,65 @@
+package fake
+
+import (
+	"errors"
+	"testing"
+
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+)
+
+func TestQueuedResponses(t *testing.T) {
+	f := New().
+		ReturnIPLookupRequestData(&models.IPLookupResponse{Data: []*models.IPData{{IPAddress: "8.8.8.8"}}}).
+		FailIPLookupRequestData(503, 100503, "Service unavailable")
+
+	params := operations.NewIPLookupRequestDataParams().WithBody(&models.IPLookupRequest{Data: []string{"8.8.8.8"}})
+	resp, err := f.IPLookupRequestData(params)
+	if err != nil {
+		t.Fatalf("Expected first call to succeed, got %v", err)
+	}
+	if resp.Payload.Data[0].IPAddress != "8.8.8.8" {
+		t.Errorf("Unexpected payload %+v", resp.Payload)
+	}
+
+	// The last queued response repeats.
+	for i := 0; i < 2; i++ {
+		_, err = f.IPLookupRequestData(params)
+		var apiErr *operations.IPLookupRequestDataDefault
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("Expected *operations.IPLookupRequestDataDefault, got %T", err)
+		}
+		payload, ok := apiErr.Payload.(*models.Response)
+		if apiErr.Code() != 503 || !ok || payload.Error.Code != 100503 {
+			t.Errorf("Unexpected error %v", apiErr)
+		}
+	}
+
+	f.AssertIPLookupRequestDataCalled(t, 3)
+	f.AssertIPLookupRequestDataCalledWith(t, &models.IPLookupRequest{Data: []string{"8.8.8.8"}})
+	f.AssertEmailValidationRequestDataCalled(t, 0)
+}
+
+func TestFuncAndNoResponse(t *testing.T) {
+	f := New()
+	if _, err := f.PromptCheckRequestData(nil); !errors.Is(err, ErrNoResponse) {
+		t.Errorf("Expected ErrNoResponse, got %v", err)
+	}
+
+	netErr := errors.New("connection refused")
+	f.ErrorEmailValidationRequestData(netErr)
+	f.EmailValidationRequestDataFunc = func(params *operations.EmailValidationRequestDataParams, _ ...operations.ClientOption) (*operations.EmailValidationRequestDataOK, error) {
+		return &operations.EmailValidationRequestDataOK{Payload: &models.EmailLookupResponse{}}, nil
+	}
+
+	params := operations.NewEmailValidationRequestDataParams().WithBody(&models.EmailLookupRequest{Data: []string{"user@example.com"}})
+	if _, err := f.EmailValidationRequestData(params); !errors.Is(err, netErr) {
+		t.Errorf("Expected queued error first, got %v", err)
+	}
+	if _, err := f.EmailValidationRequestData(params); err != nil {
+		t.Errorf("Expected Func to handle the call once the queue is empty, got %v", err)
+	}
+	if calls := f.EmailValidationRequestDataCalls(); len(calls) != 2 || calls[1].Params != params {
+		t.Errorf("Unexpected recorded calls %+v", calls)
+	}
+}
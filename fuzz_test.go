@@ -0,0 +1,137 @@
+package goclient_test
+
+// Fuzz targets for the generated response readers and models. The seed
+// corpus is built from the schema examples (see samplePayloads).
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+
+	"cerberius.com/go-client/generated/client/operations"
+
+	"github.com/go-openapi/runtime"
+	"github.com/go-openapi/strfmt"
+)
+
+// fuzzResponse is a runtime.ClientResponse with a fixed status and body.
+type fuzzResponse struct {
+	code int
+	body []byte
+}
+
+func (r *fuzzResponse) Code() int                  { return r.code }
+func (r *fuzzResponse) Message() string            { return "" }
+func (r *fuzzResponse) GetHeader(string) string    { return "" }
+func (r *fuzzResponse) GetHeaders(string) []string { return nil }
+func (r *fuzzResponse) Body() io.ReadCloser        { return io.NopCloser(bytes.NewReader(r.body)) }
+
+// addReaderSeeds seeds f with the example payload of definition for a
+// success and an error status, and with the example error payload.
+func addReaderSeeds(f *testing.F, definition string) {
+	payloads := samplePayloads(f)
+	f.Add(200, payloads[definition])
+	f.Add(422, payloads["Response"])
+	f.Add(503, []byte("Service unavailable"))
+	f.Add(200, []byte(`{"data":null}`))
+}
+
+// checkReader reads a response and checks that the result type matches the
+// status code: success responses for 200, the default response otherwise.
+func checkReader(t *testing.T, reader runtime.ClientResponseReader, okType, defaultType reflect.Type, code int, body []byte) {
+	if code < 100 || code > 999 {
+		return
+	}
+	result, err := reader.ReadResponse(&fuzzResponse{code: code, body: body}, runtime.JSONConsumer())
+
+	switch {
+	case err != nil:
+		// A non-2xx status is returned as the default response; anything
+		// else must be a decoding error with no result.
+		if reflect.TypeOf(err) == defaultType {
+			if code/100 == 2 {
+				t.Fatalf("Status %d returned as error %T", code, err)
+			}
+			return
+		}
+		if result != nil {
+			t.Fatalf("Got both result %T and error %v", result, err)
+		}
+	case code == 200:
+		if reflect.TypeOf(result) != okType {
+			t.Fatalf("Status 200 returned %T, want %v", result, okType)
+		}
+	case code/100 == 2:
+		if reflect.TypeOf(result) != defaultType {
+			t.Fatalf("Status %d returned %T, want %v", code, result, defaultType)
+		}
+	default:
+		t.Fatalf("Status %d returned result %T without error", code, result)
+	}
+}
+
+func FuzzEmailValidationRequestDataReader(f *testing.F) {
+	addReaderSeeds(f, "EmailLookupResponse")
+	f.Fuzz(func(t *testing.T, code int, body []byte) {
+		checkReader(t, &operations.EmailValidationRequestDataReader{},
+			reflect.TypeOf(&operations.EmailValidationRequestDataOK{}),
+			reflect.TypeOf(&operations.EmailValidationRequestDataDefault{}), code, body)
+	})
+}
+
+func FuzzIPLookupRequestDataReader(f *testing.F) {
+	addReaderSeeds(f, "IPLookupResponse")
+	f.Fuzz(func(t *testing.T, code int, body []byte) {
+		checkReader(t, &operations.IPLookupRequestDataReader{},
+			reflect.TypeOf(&operations.IPLookupRequestDataOK{}),
+			reflect.TypeOf(&operations.IPLookupRequestDataDefault{}), code, body)
+	})
+}
+
+func FuzzPromptCheckRequestDataReader(f *testing.F) {
+	addReaderSeeds(f, "PromptGuardResponse")
+	f.Fuzz(func(t *testing.T, code int, body []byte) {
+		checkReader(t, &operations.PromptCheckRequestDataReader{},
+			reflect.TypeOf(&operations.PromptCheckRequestDataOK{}),
+			reflect.TypeOf(&operations.PromptCheckRequestDataDefault{}), code, body)
+	})
+}
+
+// FuzzModelUnmarshalBinary checks that every model either rejects its
+// input or decodes it into a value that survives a marshal round trip.
+func FuzzModelUnmarshalBinary(f *testing.F) {
+	names := make([]string, 0, len(modelTypes))
+	for name := range modelTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	payloads := samplePayloads(f)
+	for i, name := range names {
+		f.Add(uint8(i), payloads[name])
+	}
+
+	f.Fuzz(func(t *testing.T, model uint8, data []byte) {
+		name := names[int(model)%len(names)]
+		m := modelTypes[name]()
+		if err := m.UnmarshalBinary(data); err != nil {
+			return
+		}
+		// Validation may fail on arbitrary input, but must not panic.
+		_ = m.Validate(strfmt.Default)
+
+		out, err := m.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: MarshalBinary failed after successful unmarshal: %v", name, err)
+		}
+		again := modelTypes[name]()
+		if err := again.UnmarshalBinary(out); err != nil {
+			t.Fatalf("%s: UnmarshalBinary(%s) failed: %v", name, out, err)
+		}
+		if !reflect.DeepEqual(m, again) {
+			t.Fatalf("%s: round trip mismatch: %+v != %+v", name, m, again)
+		}
+	})
+}
@@ -0,0 +1,322 @@
+package geo
+
+import (
+	"strings"
+
+	"cerberius.com/go-client/generated/models"
+)
+
+// countryNames maps lower-case English country names to ISO 3166-1 alpha-2
+// codes.
+var countryNames = map[string]string{
+	"afghanistan":                       "AF",
+	"aland islands":                     "AX",
+	"åland islands":                     "AX",
+	"albania":                           "AL",
+	"algeria":                           "DZ",
+	"american samoa":                    "AS",
+	"andorra":                           "AD",
+	"angola":                            "AO",
+	"anguilla":                          "AI",
+	"antarctica":                        "AQ",
+	"antigua and barbuda":               "AG",
+	"argentina":                         "AR",
+	"armenia":                           "AM",
+	"aruba":                             "AW",
+	"australia":                         "AU",
+	"austria":                           "AT",
+	"azerbaijan":                        "AZ",
+	"bahamas":                           "BS",
+	"the bahamas":                       "BS",
+	"bahrain":                           "BH",
+	"bangladesh":                        "BD",
+	"barbados":                          "BB",
+	"belarus":                           "BY",
+	"belgium":                           "BE",
+	"belize":                            "BZ",
+	"benin":                             "BJ",
+	"bermuda":                           "BM",
+	"bhutan":                            "BT",
+	"bolivia":                           "BO",
+	"bonaire, sint eustatius and saba":  "BQ",
+	"caribbean netherlands":             "BQ",
+	"bosnia and herzegovina":            "BA",
+	"botswana":                          "BW",
+	"bouvet island":                     "BV",
+	"brazil":                            "BR",
+	"british indian ocean territory":    "IO",
+	"brunei":                            "BN",
+	"brunei darussalam":                 "BN",
+	"bulgaria":                          "BG",
+	"burkina faso":                      "BF",
+	"burundi":                           "BI",
+	"cabo verde":                        "CV",
+	"cape verde":                        "CV",
+	"cambodia":                          "KH",
+	"cameroon":                          "CM",
+	"canada":                            "CA",
+	"cayman islands":                    "KY",
+	"central african republic":          "CF",
+	"chad":                              "TD",
+	"chile":                             "CL",
+	"china":                             "CN",
+	"christmas island":                  "CX",
+	"cocos (keeling) islands":           "CC",
+	"cocos islands":                     "CC",
+	"colombia":                          "CO",
+	"comoros":                           "KM",
+	"congo":                             "CG",
+	"republic of the congo":             "CG",
+	"congo-brazzaville":                 "CG",
+	"democratic republic of the congo":  "CD",
+	"dr congo":                          "CD",
+	"congo-kinshasa":                    "CD",
+	"cook islands":                      "CK",
+	"costa rica":                        "CR",
+	"cote d'ivoire":                     "CI",
+	"côte d'ivoire":                     "CI",
+	"ivory coast":                       "CI",
+	"croatia":                           "HR",
+	"cuba":                              "CU",
+	"curacao":                           "CW",
+	"curaçao":                           "CW",
+	"cyprus":                            "CY",
+	"czechia":                           "CZ",
+	"czech republic":                    "CZ",
+	"denmark":                           "DK",
+	"djibouti":                          "DJ",
+	"dominica":                          "DM",
+	"dominican republic":                "DO",
+	"ecuador":                           "EC",
+	"egypt":                             "EG",
+	"el salvador":                       "SV",
+	"equatorial guinea":                 "GQ",
+	"eritrea":                           "ER",
+	"estonia":                           "EE",
+	"eswatini":                          "SZ",
+	"swaziland":                         "SZ",
+	"ethiopia":                          "ET",
+	"falkland islands":                  "FK",
+	"faroe islands":                     "FO",
+	"fiji":                              "FJ",
+	"finland":                           "FI",
+	"france":                            "FR",
+	"french guiana":                     "GF",
+	"french polynesia":                  "PF",
+	"french southern territories":       "TF",
+	"gabon":                             "GA",
+	"gambia":                            "GM",
+	"the gambia":                        "GM",
+	"georgia":                           "GE",
+	"germany":                           "DE",
+	"ghana":                             "GH",
+	"gibraltar":                         "GI",
+	"greece":                            "GR",
+	"greenland":                         "GL",
+	"grenada":                           "GD",
+	"guadeloupe":                        "GP",
+	"guam":                              "GU",
+	"guatemala":                         "GT",
+	"guernsey":                          "GG",
+	"guinea":                            "GN",
+	"guinea-bissau":                     "GW",
+	"guyana":                            "GY",
+	"haiti":                             "HT",
+	"heard island and mcdonald islands": "HM",
+	"holy see":                          "VA",
+	"vatican city":                      "VA",
+	"vatican":                           "VA",
+	"honduras":                          "HN",
+	"hong kong":                         "HK",
+	"hungary":                           "HU",
+	"iceland":                           "IS",
+	"india":                             "IN",
+	"indonesia":                         "ID",
+	"iran":                              "IR",
+	"iraq":                              "IQ",
+	"ireland":                           "IE",
+	"isle of man":                       "IM",
+	"israel":                            "IL",
+	"italy":                             "IT",
+	"jamaica":                           "JM",
+	"japan":                             "JP",
+	"jersey":                            "JE",
+	"jordan":                            "JO",
+	"kazakhstan":                        "KZ",
+	"kenya":                             "KE",
+	"kiribati":                          "KI",
+	"north korea":                       "KP",
+	"south korea":                       "KR",
+	"korea":                             "KR",
+	"republic of korea":                 "KR",
+	"kosovo":                            "XK",
+	"kuwait":                            "KW",
+	"kyrgyzstan":                        "KG",
+	"laos":                              "LA",
+	"latvia":                            "LV",
+	"lebanon":                           "LB",
+	"lesotho":                           "LS",
+	"liberia":                           "LR",
+	"libya":                             "LY",
+	"liechtenstein":                     "LI",
+	"lithuania":                         "LT",
+	"luxembourg":                        "LU",
+	"macao":                             "MO",
+	"macau":                             "MO",
+	"madagascar":                        "MG",
+	"malawi":                            "MW",
+	"malaysia":                          "MY",
+	"maldives":                          "MV",
+	"mali":                              "ML",
+	"malta":                             "MT",
+	"marshall islands":                  "MH",
+	"martinique":                        "MQ",
+	"mauritania":                        "MR",
+	"mauritius":                         "MU",
+	"mayotte":                           "YT",
+	"mexico":                            "MX",
+	"micronesia":                        "FM",
+	"moldova":                           "MD",
+	"monaco":                            "MC",
+	"mongolia":                          "MN",
+	"montenegro":                        "ME",
+	"montserrat":                        "MS",
+	"morocco":                           "MA",
+	"mozambique":                        "MZ",
+	"myanmar":                           "MM",
+	"burma":                             "MM",
+	"namibia":                           "NA",
+	"nauru":                             "NR",
+	"nepal":                             "NP",
+	"netherlands":                       "NL",
+	"the netherlands":                   "NL",
+	"new caledonia":                     "NC",
+	"new zealand":                       "NZ",
+	"nicaragua":                         "NI",
+	"niger":                             "NE",
+	"nigeria":                           "NG",
+	"niue":                              "NU",
+	"norfolk island":                    "NF",
+	"north macedonia":                   "MK",
+	"macedonia":                         "MK",
+	"northern mariana islands":          "MP",
+	"norway":                            "NO",
+	"oman":                              "OM",
+	"pakistan":                          "PK",
+	"palau":                             "PW",
+	"palestine":                         "PS",
+	"panama":                            "PA",
+	"papua new guinea":                  "PG",
+	"paraguay":                          "PY",
+	"peru":                              "PE",
+	"philippines":                       "PH",
+	"pitcairn":                          "PN",
+	"pitcairn islands":                  "PN",
+	"poland":                            "PL",
+	"portugal":                          "PT",
+	"puerto rico":                       "PR",
+	"qatar":                             "QA",
+	"reunion":                           "RE",
+	"réunion":                           "RE",
+	"romania":                           "RO",
+	"russia":                            "RU",
+	"russian federation":                "RU",
+	"rwanda":                            "RW",
+	"saint barthelemy":                  "BL",
+	"saint barthélemy":                  "BL",
+	"saint helena":                      "SH",
+	"saint kitts and nevis":             "KN",
+	"saint lucia":                       "LC",
+	"saint martin":                      "MF",
+	"saint pierre and miquelon":         "PM",
+	"saint vincent and the grenadines":  "VC",
+	"samoa":                             "WS",
+	"san marino":                        "SM",
+	"sao tome and principe":             "ST",
+	"são tomé and príncipe":             "ST",
+	"saudi arabia":                      "SA",
+	"senegal":                           "SN",
+	"serbia":                            "RS",
+	"seychelles":                        "SC",
+	"sierra leone":                      "SL",
+	"singapore":                         "SG",
+	"sint maarten":                      "SX",
+	"slovakia":                          "SK",
+	"slovenia":                          "SI",
+	"solomon islands":                   "SB",
+	"somalia":                           "SO",
+	"south africa":                      "ZA",
+	"south georgia and the south sandwich islands": "GS",
+	"south sudan":                          "SS",
+	"spain":                                "ES",
+	"sri lanka":                            "LK",
+	"sudan":                                "SD",
+	"suriname":                             "SR",
+	"svalbard and jan mayen":               "SJ",
+	"sweden":                               "SE",
+	"switzerland":                          "CH",
+	"syria":                                "SY",
+	"taiwan":                               "TW",
+	"tajikistan":                           "TJ",
+	"tanzania":                             "TZ",
+	"thailand":                             "TH",
+	"timor-leste":                          "TL",
+	"east timor":                           "TL",
+	"togo":                                 "TG",
+	"tokelau":                              "TK",
+	"tonga":                                "TO",
+	"trinidad and tobago":                  "TT",
+	"tunisia":                              "TN",
+	"turkey":                               "TR",
+	"türkiye":                              "TR",
+	"turkmenistan":                         "TM",
+	"turks and caicos islands":             "TC",
+	"tuvalu":                               "TV",
+	"uganda":                               "UG",
+	"ukraine":                              "UA",
+	"united arab emirates":                 "AE",
+	"united kingdom":                       "GB",
+	"great britain":                        "GB",
+	"uk":                                   "GB",
+	"united states":                        "US",
+	"united states of america":             "US",
+	"usa":                                  "US",
+	"united states minor outlying islands": "UM",
+	"uruguay":                              "UY",
+	"uzbekistan":                           "UZ",
+	"vanuatu":                              "VU",
+	"venezuela":                            "VE",
+	"vietnam":                              "VN",
+	"viet nam":                             "VN",
+	"british virgin islands":               "VG",
+	"u.s. virgin islands":                  "VI",
+	"wallis and futuna":                    "WF",
+	"western sahara":                       "EH",
+	"yemen":                                "YE",
+	"zambia":                               "ZM",
+	"zimbabwe":                             "ZW",
+}
+
+// alpha2 is the set of codes in countryNames.
+var alpha2 = func() map[string]bool {
+	codes := make(map[string]bool, len(countryNames))
+	for _, code := range countryNames {
+		codes[code] = true
+	}
+	return codes
+}()
+
+// CountryOf returns the ISO 3166-1 alpha-2 code of the country of a lookup,
+// or false if it is not recognised. CountryCode is used if it is an
+// alpha-2 code; otherwise, as the schema documents dialing codes such as
+// "+1" for it, the code is mapped from the Country name.
+func CountryOf(ip *models.IPData) (string, bool) {
+	if ip == nil {
+		return "", false
+	}
+	if code := strings.ToUpper(strings.TrimSpace(ip.CountryCode)); alpha2[code] {
+		return code, true
+	}
+	code, ok := countryNames[strings.ToLower(strings.TrimSpace(ip.Country))]
+	return code, ok
+}
@@ -0,0 +1,107 @@
+package geo
+
+import (
+	"fmt"
+	"strings"
+
+	"cerberius.com/go-client/generated/models"
+)
+
+// Polygon is a named region bounded by its vertices, in order. The last
+// vertex connects back to the first. Polygons must not cross the
+// antimeridian.
+type Polygon struct {
+	Name   string  `json:"name"`
+	Points []Point `json:"points"`
+}
+
+// Contains reports whether p lies inside the polygon.
+func (poly Polygon) Contains(p Point) bool {
+	inside := false
+	n := len(poly.Points)
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		a, b := poly.Points[i], poly.Points[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Fence is a geofence. A lookup is inside the fence if it passes every
+// configured rule; the zero Fence admits everything.
+type Fence struct {
+	// AllowCountries and DenyCountries are ISO 3166-1 alpha-2 country
+	// codes, matched against the code returned by CountryOf ignoring case.
+	// A non-empty allow list admits only its countries; the deny list
+	// always wins. When either list is set, lookups whose country is not
+	// recognised are refused.
+	AllowCountries []string `json:"allow_countries,omitempty"`
+	DenyCountries  []string `json:"deny_countries,omitempty"`
+
+	// AllowContinents are continent codes such as EU or NA, matched
+	// against ContinentCode ignoring case.
+	AllowContinents []string `json:"allow_continents,omitempty"`
+
+	// EUOnly admits only lookups in the European Union.
+	EUOnly bool `json:"eu_only,omitempty"`
+
+	// Regions admit only lookups located inside at least one of them.
+	// Lookups without a location are refused when Regions is set.
+	Regions []Polygon `json:"regions,omitempty"`
+}
+
+// Verdict is the outcome of checking a lookup against a Fence.
+type Verdict struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"` // Reason explains a refusal.
+	Region  string `json:"region,omitempty"` // Region is the name of the region the lookup is in, if any.
+}
+
+// Check checks a lookup against the fence.
+func (f *Fence) Check(ip *models.IPData) Verdict {
+	if ip == nil {
+		return Verdict{Reason: "no lookup"}
+	}
+	country, known := CountryOf(ip)
+	if !known && (len(f.DenyCountries) > 0 || len(f.AllowCountries) > 0) {
+		return Verdict{Reason: fmt.Sprintf("country code %q is not recognised", ip.CountryCode)}
+	}
+	if contains(f.DenyCountries, country) {
+		return Verdict{Reason: fmt.Sprintf("country %s is denied", country)}
+	}
+	if len(f.AllowCountries) > 0 && !contains(f.AllowCountries, country) {
+		return Verdict{Reason: fmt.Sprintf("country %q is not allowed", country)}
+	}
+	continent := strings.ToUpper(strings.TrimSpace(ip.ContinentCode))
+	if len(f.AllowContinents) > 0 && !contains(f.AllowContinents, continent) {
+		return Verdict{Reason: fmt.Sprintf("continent %q is not allowed", continent)}
+	}
+	if f.EUOnly && !ip.InEU {
+		return Verdict{Reason: "not in the EU"}
+	}
+	if len(f.Regions) == 0 {
+		return Verdict{Allowed: true}
+	}
+
+	p, err := PointOf(ip)
+	if err != nil {
+		return Verdict{Reason: "no location"}
+	}
+	for _, r := range f.Regions {
+		if r.Contains(p) {
+			return Verdict{Allowed: true, Region: r.Name}
+		}
+	}
+	return Verdict{Reason: "outside every region"}
+}
+
+func contains(codes []string, code string) bool {
+	for _, c := range codes {
+		if strings.EqualFold(strings.TrimSpace(c), code) {
+			return true
+		}
+	}
+	return false
+}
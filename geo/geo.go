@@ -0,0 +1,154 @@
+// Package geo reasons about the locations of IP lookups: the great-circle
+// distance between two lookups, impossible travel between two logins, and
+// geofences.
+//
+// A Travel check flags a pair of logins whose locations are further apart
+// than anyone could have travelled in the time between them:
+//
+//	t, err := geo.TravelCheck{}.Check(
+//		geo.Login{IP: previous, Time: previousAt},
+//		geo.Login{IP: current, Time: time.Now()},
+//	)
+//	if err == nil && t.Impossible {
+//		requireStepUp()
+//	}
+//
+// A Fence admits or refuses a lookup by country, EU membership, continent
+// or polygon region.
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"cerberius.com/go-client/generated/models"
+)
+
+// EarthRadius is the mean radius of the Earth in kilometres.
+const EarthRadius = 6371.0088
+
+// Defaults for the zero fields of TravelCheck.
+const (
+	// DefaultMaxSpeed is the fastest plausible travel speed in km/h, about
+	// the cruise speed of an airliner.
+	DefaultMaxSpeed = 1000
+	// DefaultMinDistance is the distance in km below which two locations
+	// are considered the same, to absorb geolocation inaccuracy.
+	DefaultMinDistance = 100
+)
+
+// ErrNoLocation is returned for lookups without a usable latitude and
+// longitude.
+var ErrNoLocation = errors.New("geo: no location")
+
+// Point is a location in decimal degrees.
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// PointOf returns the location of an IP lookup. It returns an error
+// wrapping ErrNoLocation if the latitude or longitude is missing or out of
+// range.
+func PointOf(ip *models.IPData) (Point, error) {
+	if ip == nil || strings.TrimSpace(ip.Latitude) == "" || strings.TrimSpace(ip.Longitude) == "" {
+		return Point{}, ErrNoLocation
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(ip.Latitude), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return Point{}, fmt.Errorf("%w: invalid latitude %q of %s", ErrNoLocation, ip.Latitude, ip.IPAddress)
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(ip.Longitude), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return Point{}, fmt.Errorf("%w: invalid longitude %q of %s", ErrNoLocation, ip.Longitude, ip.IPAddress)
+	}
+	return Point{Lat: lat, Lon: lon}, nil
+}
+
+// Distance returns the great-circle distance between a and b in
+// kilometres.
+func Distance(a, b Point) float64 {
+	rad := math.Pi / 180
+	dLat := (b.Lat - a.Lat) * rad
+	dLon := (b.Lon - a.Lon) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// DistanceBetween returns the great-circle distance between two IP
+// lookups in kilometres.
+func DistanceBetween(a, b *models.IPData) (float64, error) {
+	pa, err := PointOf(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := PointOf(b)
+	if err != nil {
+		return 0, err
+	}
+	return Distance(pa, pb), nil
+}
+
+// Login is a login from a looked-up IP address.
+type Login struct {
+	IP   *models.IPData
+	Time time.Time
+}
+
+// Travel is the outcome of checking two logins.
+type Travel struct {
+	Distance float64       `json:"distance_km"` // Distance is the distance between the logins in km.
+	Elapsed  time.Duration `json:"elapsed"`     // Elapsed is the time between the logins.
+	Speed    float64       `json:"speed_kmh"`   // Speed is the implied travel speed in km/h, +Inf if Elapsed is zero.
+
+	// Impossible reports whether Speed exceeds the maximum speed for a
+	// distance of at least the minimum distance.
+	Impossible bool `json:"impossible"`
+}
+
+// TravelCheck flags impossible travel between logins.
+type TravelCheck struct {
+	// MaxSpeed is the fastest plausible travel speed in km/h,
+	// DefaultMaxSpeed if zero.
+	MaxSpeed float64
+
+	// MinDistance is the distance in km below which travel is never
+	// impossible, DefaultMinDistance if zero.
+	MinDistance float64
+}
+
+// Check checks the travel between two logins, in either order. It returns
+// an error wrapping ErrNoLocation if either login has no location.
+func (c TravelCheck) Check(from, to Login) (Travel, error) {
+	d, err := DistanceBetween(from.IP, to.IP)
+	if err != nil {
+		return Travel{}, err
+	}
+	maxSpeed, minDistance := c.MaxSpeed, c.MinDistance
+	if maxSpeed <= 0 {
+		maxSpeed = DefaultMaxSpeed
+	}
+	if minDistance <= 0 {
+		minDistance = DefaultMinDistance
+	}
+
+	t := Travel{Distance: d, Elapsed: to.Time.Sub(from.Time)}
+	if t.Elapsed < 0 {
+		t.Elapsed = -t.Elapsed
+	}
+	if t.Elapsed == 0 {
+		t.Speed = math.Inf(1)
+		if d == 0 {
+			t.Speed = 0
+		}
+	} else {
+		t.Speed = d / t.Elapsed.Hours()
+	}
+	t.Impossible = d >= minDistance && t.Speed > maxSpeed
+	return t, nil
+}
@@ -0,0 +1,99 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"cerberius.com/go-client/generated/models"
+)
+
+var (
+	berlin = &models.IPData{IPAddress: "1.1.1.1", Latitude: "52.5200", Longitude: "13.4050", CountryCode: "DE", ContinentCode: "EU", InEU: true}
+	paris  = &models.IPData{IPAddress: "2.2.2.2", Latitude: "48.8566", Longitude: "2.3522", CountryCode: "FR", ContinentCode: "EU", InEU: true}
+	nyc    = &models.IPData{IPAddress: "3.3.3.3", Latitude: "40.7128", Longitude: "-74.0060", CountryCode: "US", ContinentCode: "NA"}
+)
+
+func TestDistance(t *testing.T) {
+	d, err := DistanceBetween(berlin, paris)
+	if err != nil || math.Abs(d-878) > 5 {
+		t.Errorf("Expected Berlin-Paris to be about 878km, got %.1f, %v", d, err)
+	}
+	d, _ = DistanceBetween(berlin, nyc)
+	if math.Abs(d-6385) > 20 {
+		t.Errorf("Expected Berlin-New York to be about 6385km, got %.1f", d)
+	}
+	if d := Distance(Point{10, 20}, Point{10, 20}); d != 0 {
+		t.Errorf("Expected a zero distance, got %f", d)
+	}
+
+	for _, ip := range []*models.IPData{nil, {}, {Latitude: "abc", Longitude: "1"}, {Latitude: "91", Longitude: "0"}} {
+		if _, err := PointOf(ip); !errors.Is(err, ErrNoLocation) {
+			t.Errorf("PointOf(%+v) = %v, want ErrNoLocation", ip, err)
+		}
+	}
+}
+
+func TestTravelCheck(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	tests := []struct {
+		name       string
+		from, to   *models.IPData
+		elapsed    time.Duration
+		impossible bool
+	}{
+		{"flight", berlin, nyc, 8 * time.Hour, false},
+		{"too fast", berlin, nyc, time.Hour, true},
+		{"same place", berlin, berlin, 0, false},
+		{"instant", berlin, paris, 0, true},
+		{"reverse order", nyc, berlin, -time.Hour, true},
+	}
+	for _, tt := range tests {
+		tr, err := TravelCheck{}.Check(Login{tt.from, at}, Login{tt.to, at.Add(tt.elapsed)})
+		if err != nil || tr.Impossible != tt.impossible {
+			t.Errorf("%s: got %+v, %v", tt.name, tr, err)
+		}
+	}
+
+	// Nearby jumps are geolocation noise.
+	near := &models.IPData{Latitude: "52.60", Longitude: "13.50"}
+	if tr, _ := (TravelCheck{}).Check(Login{berlin, at}, Login{near, at}); tr.Impossible {
+		t.Errorf("Expected a nearby login not to be impossible, got %+v", tr)
+	}
+	if _, err := (TravelCheck{}).Check(Login{berlin, at}, Login{&models.IPData{}, at}); !errors.Is(err, ErrNoLocation) {
+		t.Errorf("Expected ErrNoLocation, got %v", err)
+	}
+}
+
+func TestFence(t *testing.T) {
+	// A rough box around Germany.
+	germany := Polygon{Name: "de", Points: []Point{{47, 5}, {55, 5}, {55, 15.5}, {47, 15.5}}}
+	tests := []struct {
+		name    string
+		fence   Fence
+		ip      *models.IPData
+		allowed bool
+		region  string
+	}{
+		{"zero", Fence{}, nyc, true, ""},
+		{"allowed country", Fence{AllowCountries: []string{"de", "FR"}}, paris, true, ""},
+		{"not allowed", Fence{AllowCountries: []string{"DE"}}, nyc, false, ""},
+		{"denied", Fence{AllowCountries: []string{"DE"}, DenyCountries: []string{"DE"}}, berlin, false, ""},
+		{"eu only", Fence{EUOnly: true}, nyc, false, ""},
+		{"continent", Fence{AllowContinents: []string{"eu"}}, paris, true, ""},
+		{"in region", Fence{Regions: []Polygon{germany}}, berlin, true, "de"},
+		{"outside region", Fence{Regions: []Polygon{germany}}, paris, false, ""},
+		{"region without location", Fence{Regions: []Polygon{germany}}, &models.IPData{CountryCode: "DE"}, false, ""},
+		// The schema documents a dialing code as the country code.
+		{"dialing code denied", Fence{DenyCountries: []string{"US"}}, &models.IPData{CountryCode: "+1", Country: "United States"}, false, ""},
+		{"dialing code allowed", Fence{AllowCountries: []string{"US"}}, &models.IPData{CountryCode: "+1", Country: "United States"}, true, ""},
+		{"unrecognised country", Fence{DenyCountries: []string{"US"}}, &models.IPData{CountryCode: "+1"}, false, ""},
+	}
+	for _, tt := range tests {
+		v := tt.fence.Check(tt.ip)
+		if v.Allowed != tt.allowed || v.Region != tt.region || !v.Allowed && v.Reason == "" {
+			t.Errorf("%s: got %+v", tt.name, v)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+// Package hedge reduces the tail latency of IP lookups with hedged
+// requests.
+//
+// Client wraps an operations.ClientService. When an IP lookup has not
+// answered within a delay derived from a percentile of recent latencies,
+// an identical second request is sent and the first response wins; the
+// other request is cancelled. The share of hedged requests is capped so
+// that credit usage stays bounded:
+//
+//	svc := hedge.New(apiClient.Operations, hedge.Policy{Percentile: 0.95, MaxExtra: 0.05})
+//
+// Email validations and prompt checks are passed through unchanged.
+package hedge
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"cerberius.com/go-client/generated/client/operations"
+
+	"github.com/go-openapi/runtime"
+)
+
+// Defaults for the zero fields of Policy.
+const (
+	DefaultPercentile   = 0.95
+	DefaultInitialDelay = 250 * time.Millisecond
+	DefaultMaxExtra     = 0.05
+	DefaultSamples      = 1000
+	DefaultMinSamples   = 20
+)
+
+// hedgeBurst is the number of hedges that may be saved up while traffic
+// is below the MaxExtra share.
+const hedgeBurst = 10
+
+// recomputeEvery is the number of new samples after which the delay is
+// recomputed.
+const recomputeEvery = 50
+
+// Policy configures hedging.
+type Policy struct {
+	// Percentile is the latency percentile, between 0 and 1, after which a
+	// request is hedged. DefaultPercentile if zero.
+	Percentile float64
+
+	// InitialDelay is the delay used until MinSamples latencies have been
+	// observed, DefaultInitialDelay if zero.
+	InitialDelay time.Duration
+
+	// MinDelay and MaxDelay bound the computed delay. MaxDelay is ignored
+	// if zero.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+
+	// MaxExtra is the largest number of hedged requests as a share of all
+	// requests, DefaultMaxExtra if zero. Hedging is disabled if negative.
+	MaxExtra float64
+
+	// Samples is the number of recent latencies the percentile is computed
+	// over, DefaultSamples if zero.
+	Samples int
+
+	// MinSamples is the number of latencies needed before the percentile
+	// is used, DefaultMinSamples if zero.
+	MinSamples int
+}
+
+// Stats are the counters of a Client.
+type Stats struct {
+	Requests  int64         // Requests is the number of IP lookups.
+	Hedged    int64         // Hedged is the number of hedged requests sent.
+	HedgeWins int64         // HedgeWins is the number of lookups answered by the hedged request.
+	Delay     time.Duration // Delay is the current hedging delay.
+}
+
+// Client is an operations.ClientService that hedges IP lookups.
+type Client struct {
+	next   operations.ClientService
+	policy Policy
+
+	mu        sync.Mutex
+	latencies []time.Duration // latencies is a ring buffer of recent latencies.
+	pos       int
+	fresh     int // fresh is the number of samples since the delay was computed.
+	delay     time.Duration
+	tokens    float64 // tokens is the number of hedges currently allowed.
+	stats     Stats
+}
+
+var _ operations.ClientService = (*Client)(nil)
+
+// New creates a new Client that hedges the IP lookups of next.
+func New(next operations.ClientService, policy Policy) *Client {
+	if policy.Percentile <= 0 || policy.Percentile > 1 {
+		policy.Percentile = DefaultPercentile
+	}
+	if policy.InitialDelay <= 0 {
+		policy.InitialDelay = DefaultInitialDelay
+	}
+	if policy.MaxExtra == 0 {
+		policy.MaxExtra = DefaultMaxExtra
+	}
+	if policy.Samples <= 0 {
+		policy.Samples = DefaultSamples
+	}
+	if policy.MinSamples <= 0 {
+		policy.MinSamples = DefaultMinSamples
+	}
+	c := &Client{next: next, policy: policy}
+	c.delay = c.clamp(policy.InitialDelay)
+	return c
+}
+
+// Stats returns the counters of the client.
+func (c *Client) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Delay = c.delay
+	return s
+}
+
+// result is the outcome of a single request.
+type result struct {
+	resp    *operations.IPLookupRequestDataOK
+	err     error
+	hedged  bool
+	latency time.Duration // latency is the time since the start of the lookup.
+}
+
+// IPLookupRequestData implements operations.ClientService. The request is
+// hedged if it has not answered within the hedging delay and the hedging
+// budget allows it. The first successful response is returned and the
+// other request is cancelled; if both fail, the last error is returned.
+func (c *Client) IPLookupRequestData(params *operations.IPLookupRequestDataParams, opts ...operations.ClientOption) (*operations.IPLookupRequestDataOK, error) {
+	if params == nil {
+		params = operations.NewIPLookupRequestDataParams()
+	}
+	parent := params.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	delay := c.begin()
+	// Latencies are measured from the start of the lookup, so that a
+	// hedged response counts with the delay it was sent after.
+	start := time.Now()
+	results := make(chan result, 2)
+	send := func(hedged bool) {
+		p := *params
+		p.Context = ctx
+		go func() {
+			resp, err := c.next.IPLookupRequestData(&p, opts...)
+			results <- result{resp: resp, err: err, hedged: hedged, latency: time.Since(start)}
+		}()
+	}
+	send(false)
+	inflight := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	hedgeC := timer.C
+
+	for {
+		select {
+		case <-hedgeC:
+			hedgeC = nil
+			if c.allowHedge() {
+				send(true)
+				inflight++
+			}
+		case r := <-results:
+			inflight--
+			if r.err == nil {
+				c.record(r)
+				return r.resp, nil
+			}
+			if inflight == 0 {
+				return nil, r.err
+			}
+			// The other request may still succeed; don't hedge a failure.
+			hedgeC = nil
+		case <-parent.Done():
+			return nil, parent.Err()
+		}
+	}
+}
+
+// begin counts a request, adds its share to the hedging budget and returns
+// the current delay.
+func (c *Client) begin() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Requests++
+	if c.policy.MaxExtra > 0 {
+		c.tokens = math.Min(c.tokens+c.policy.MaxExtra, hedgeBurst)
+	}
+	return c.delay
+}
+
+// allowHedge reports whether the budget allows a hedged request and
+// consumes it if so.
+func (c *Client) allowHedge() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tokens < 1 {
+		return false
+	}
+	c.tokens--
+	c.stats.Hedged++
+	return true
+}
+
+// record adds the latency of a successful lookup.
+func (c *Client) record(r result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r.hedged {
+		c.stats.HedgeWins++
+	}
+	if len(c.latencies) < c.policy.Samples {
+		c.latencies = append(c.latencies, r.latency)
+	} else {
+		c.latencies[c.pos] = r.latency
+		c.pos = (c.pos + 1) % c.policy.Samples
+	}
+	c.fresh++
+	if len(c.latencies) >= c.policy.MinSamples && (c.fresh >= recomputeEvery || len(c.latencies) == c.policy.MinSamples) {
+		c.fresh = 0
+		c.delay = c.clamp(percentile(c.latencies, c.policy.Percentile))
+	}
+}
+
+// clamp bounds d by the policy's MinDelay and MaxDelay.
+func (c *Client) clamp(d time.Duration) time.Duration {
+	if d < c.policy.MinDelay {
+		d = c.policy.MinDelay
+	}
+	if c.policy.MaxDelay > 0 && d > c.policy.MaxDelay {
+		d = c.policy.MaxDelay
+	}
+	return d
+}
+
+// percentile returns the p-th percentile of samples by the nearest-rank
+// method.
+func percentile(samples []time.Duration, p float64) time.Duration {
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// EmailValidationRequestData implements operations.ClientService.
+func (c *Client) EmailValidationRequestData(params *operations.EmailValidationRequestDataParams, opts ...operations.ClientOption) (*operations.EmailValidationRequestDataOK, error) {
+	return c.next.EmailValidationRequestData(params, opts...)
+}
+
+// PromptCheckRequestData implements operations.ClientService.
+func (c *Client) PromptCheckRequestData(params *operations.PromptCheckRequestDataParams, opts ...operations.ClientOption) (*operations.PromptCheckRequestDataOK, error) {
+	return c.next.PromptCheckRequestData(params, opts...)
+}
+
+// SetTransport implements operations.ClientService.
+func (c *Client) SetTransport(transport runtime.ClientTransport) {
+	c.next.SetTransport(transport)
+}
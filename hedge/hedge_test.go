@@ -0,0 +1,111 @@
+package hedge
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cerberius.com/go-client/fake"
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+)
+
+func ipParams() *operations.IPLookupRequestDataParams {
+	return operations.NewIPLookupRequestDataParams().WithBody(&models.IPLookupRequest{Data: []string{"8.8.8.8"}})
+}
+
+// stallFirst returns a handler whose first call blocks until it is
+// cancelled and whose later calls answer immediately.
+func stallFirst(calls *int32, cancelled chan<- struct{}) func(*operations.IPLookupRequestDataParams, ...operations.ClientOption) (*operations.IPLookupRequestDataOK, error) {
+	return func(params *operations.IPLookupRequestDataParams, _ ...operations.ClientOption) (*operations.IPLookupRequestDataOK, error) {
+		if atomic.AddInt32(calls, 1) == 1 {
+			<-params.Context.Done()
+			close(cancelled)
+			return nil, params.Context.Err()
+		}
+		return &operations.IPLookupRequestDataOK{Payload: &models.IPLookupResponse{Data: []*models.IPData{{IPAddress: "8.8.8.8"}}}}, nil
+	}
+}
+
+func TestHedgeWinsAndCancelsPrimary(t *testing.T) {
+	var calls int32
+	cancelled := make(chan struct{})
+	svc := fake.New()
+	svc.IPLookupRequestDataFunc = stallFirst(&calls, cancelled)
+	c := New(svc, Policy{InitialDelay: 10 * time.Millisecond, MaxExtra: 1})
+
+	resp, err := c.IPLookupRequestData(ipParams())
+	if err != nil || resp.Payload.Data[0].IPAddress != "8.8.8.8" {
+		t.Fatalf("Expected the hedged response, got %v, %v", resp, err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the stalled request to be cancelled")
+	}
+	if s := c.Stats(); s.Requests != 1 || s.Hedged != 1 || s.HedgeWins != 1 {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+	// The hedged response took at least the delay since the lookup began.
+	if len(c.latencies) != 1 || c.latencies[0] < 10*time.Millisecond {
+		t.Errorf("Expected the latency to include the hedging delay, got %v", c.latencies)
+	}
+	svc.AssertIPLookupRequestDataCalled(t, 2)
+}
+
+func TestNilParams(t *testing.T) {
+	svc := fake.New().ReturnIPLookupRequestData(&models.IPLookupResponse{})
+	c := New(svc, Policy{})
+	if _, err := c.IPLookupRequestData(nil); err != nil {
+		t.Fatalf("Lookup with nil params failed: %v", err)
+	}
+	svc.AssertIPLookupRequestDataCalled(t, 1)
+}
+
+func TestHedgeBudget(t *testing.T) {
+	var calls int32
+	svc := fake.New()
+	svc.IPLookupRequestDataFunc = stallFirst(&calls, make(chan struct{}))
+	c := New(svc, Policy{InitialDelay: 5 * time.Millisecond, MaxExtra: 0.5})
+
+	// The first request earns half a hedge, which is not enough: it runs
+	// until its context expires.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.IPLookupRequestData(ipParams().WithContext(ctx))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the unhedged request to time out, got %v", err)
+	}
+	if s := c.Stats(); s.Hedged != 0 {
+		t.Errorf("Expected no hedge within budget, got %+v", s)
+	}
+}
+
+func TestFailuresAreNotHedged(t *testing.T) {
+	svc := fake.New().FailIPLookupRequestData(503, 100503, "Service unavailable")
+	c := New(svc, Policy{InitialDelay: time.Second, MaxExtra: 1})
+
+	if _, err := c.IPLookupRequestData(ipParams()); err == nil {
+		t.Fatal("Expected the failure to be returned")
+	}
+	svc.AssertIPLookupRequestDataCalled(t, 1)
+}
+
+func TestDelayFollowsPercentile(t *testing.T) {
+	c := New(fake.New(), Policy{Percentile: 0.9, MinSamples: 10, MinDelay: 20 * time.Millisecond})
+	if got := c.Stats().Delay; got != DefaultInitialDelay {
+		t.Fatalf("Expected DefaultInitialDelay before samples, got %v", got)
+	}
+	for i := 1; i <= 10; i++ {
+		c.record(result{latency: time.Duration(i) * 10 * time.Millisecond})
+	}
+	// The 90th percentile of 10ms..100ms.
+	if got := c.Stats().Delay; got != 90*time.Millisecond {
+		t.Errorf("Expected 90ms, got %v", got)
+	}
+	if got := percentile([]time.Duration{3, 1, 2, 4}, 0.5); got != 2 {
+		t.Errorf("Expected median 2, got %v", got)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// operation describes a single method of operations.ClientService.
+type operation struct {
+	Name        string // Name is the method name, e.g. IPLookupRequestData.
+	Field       string // Field is the unexported field prefix, e.g. ipLookupRequestData.
+	Params      string // Params is the params type name, e.g. IPLookupRequestDataParams.
+	OK          string // OK is the success response type name.
+	Default     string // Default is the default (error) response type name.
+	BodyType    string // BodyType is the type expression of the params Body field.
+	PayloadType string // PayloadType is the type expression of the success Payload field.
+}
+
+// Generate parses the operations package in dir and returns the formatted
+// source of the fake.
+func Generate(dir string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	pkg, ok := pkgs["operations"]
+	if !ok {
+		return nil, fmt.Errorf("no operations package in %s", dir)
+	}
+
+	types := make(map[string]ast.Expr)
+	funcs := make(map[string]bool)
+	for _, f := range pkg.Files {
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						types[ts.Name.Name] = ts.Type
+					}
+				}
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					funcs[d.Name.Name] = true
+				}
+			}
+		}
+	}
+
+	iface, ok := types["ClientService"].(*ast.InterfaceType)
+	if !ok {
+		return nil, fmt.Errorf("no ClientService interface in %s", dir)
+	}
+
+	var ops []operation
+	for _, m := range iface.Methods.List {
+		fn, ok := m.Type.(*ast.FuncType)
+		if !ok || len(m.Names) != 1 || m.Names[0].Name == "SetTransport" {
+			continue
+		}
+		op := operation{Name: m.Names[0].Name}
+		op.Field = lowerInitialism(op.Name)
+		if len(fn.Params.List) == 0 || len(fn.Results.List) != 2 {
+			return nil, fmt.Errorf("%s: unexpected signature", op.Name)
+		}
+		if op.Params, err = starIdent(fn.Params.List[0].Type); err != nil {
+			return nil, fmt.Errorf("%s params: %w", op.Name, err)
+		}
+		if op.OK, err = starIdent(fn.Results.List[0].Type); err != nil {
+			return nil, fmt.Errorf("%s result: %w", op.Name, err)
+		}
+		op.Default = op.Name + "Default"
+		if _, ok := types[op.Default]; !ok || !funcs["New"+op.Default] {
+			return nil, fmt.Errorf("%s: no %s response type", op.Name, op.Default)
+		}
+		if op.BodyType, err = fieldType(types[op.Params], "Body"); err != nil {
+			return nil, fmt.Errorf("%s: %w", op.Params, err)
+		}
+		if op.PayloadType, err = fieldType(types[op.OK], "Payload"); err != nil {
+			return nil, fmt.Errorf("%s: %w", op.OK, err)
+		}
+		ops = append(ops, op)
+	}
+
+	var buf bytes.Buffer
+	if err := fakeTemplate.Execute(&buf, ops); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// lowerInitialism lowercases the leading word of name, treating a run of
+// upper case letters as a single initialism.
+func lowerInitialism(name string) string {
+	r := []rune(name)
+	i := 0
+	for i < len(r) && unicode.IsUpper(r[i]) {
+		i++
+	}
+	if i > 1 && i < len(r) {
+		// Keep the last upper case letter, it starts the next word.
+		i--
+	}
+	return strings.ToLower(string(r[:i])) + string(r[i:])
+}
+
+// starIdent returns the name of the *Ident type expression e.
+func starIdent(e ast.Expr) (string, error) {
+	star, ok := e.(*ast.StarExpr)
+	if !ok {
+		return "", fmt.Errorf("expected a pointer type")
+	}
+	id, ok := star.X.(*ast.Ident)
+	if !ok {
+		return "", fmt.Errorf("expected a named type")
+	}
+	return id.Name, nil
+}
+
+// fieldType returns the type expression of field name in struct type t.
+func fieldType(t ast.Expr, name string) (string, error) {
+	st, ok := t.(*ast.StructType)
+	if !ok {
+		return "", fmt.Errorf("not a struct")
+	}
+	for _, f := range st.Fields.List {
+		for _, n := range f.Names {
+			if n.Name == name {
+				var buf bytes.Buffer
+				if err := format.Node(&buf, token.NewFileSet(), f.Type); err != nil {
+					return "", err
+				}
+				return buf.String(), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no %s field", name)
+}
+
+var fakeTemplate = template.Must(template.New("fake").Parse(`// Code generated by genfake; DO NOT EDIT.
+
+package fake
+
+// This file was generated from the operations package by internal/genfake.
+// Run "go generate ./fake" after regenerating the client.
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+
+	"github.com/go-openapi/runtime"
+)
+
+// Ensure Fake stays in sync with the generated interface.
+var _ operations.ClientService = (*Fake)(nil)
+
+// Fake is a programmable operations.ClientService for unit tests.
+//
+// Responses are queued per operation with the Return, Fail and Error
+// methods and consumed in order; the last queued response is repeated for
+// all further calls. An operation's Func field, if set, handles calls once
+// its queue is empty. Calls without any response fail with ErrNoResponse.
+// Every call is recorded. Fake is safe for concurrent use.
+type Fake struct {
+	mu        sync.Mutex
+	transport runtime.ClientTransport
+{{range .}}
+	// {{.Name}}Func, if set, handles {{.Name}} calls once no queued response is left.
+	{{.Name}}Func func(params *operations.{{.Params}}, opts ...operations.ClientOption) (*operations.{{.OK}}, error)
+	{{.Field}}Queue []{{.Name}}Response
+	{{.Field}}Calls []{{.Name}}Call
+{{end}}}
+
+// New creates a new Fake without any responses.
+func New() *Fake {
+	return &Fake{}
+}
+
+// SetTransport records the transport. It is never used.
+func (f *Fake) SetTransport(transport runtime.ClientTransport) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.transport = transport
+}
+
+// Transport returns the transport passed to SetTransport.
+func (f *Fake) Transport() runtime.ClientTransport {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.transport
+}
+{{range .}}
+// {{.Name}}Call records a call to {{.Name}}.
+type {{.Name}}Call struct {
+	Params *operations.{{.Params}}
+	Opts   []operations.ClientOption
+}
+
+// {{.Name}}Response is a queued {{.Name}} response.
+type {{.Name}}Response struct {
+	OK  *operations.{{.OK}}
+	Err error
+}
+
+// {{.Name}} implements operations.ClientService.
+func (f *Fake) {{.Name}}(params *operations.{{.Params}}, opts ...operations.ClientOption) (*operations.{{.OK}}, error) {
+	f.mu.Lock()
+	f.{{.Field}}Calls = append(f.{{.Field}}Calls, {{.Name}}Call{Params: params, Opts: opts})
+	if n := len(f.{{.Field}}Queue); n > 0 {
+		resp := f.{{.Field}}Queue[0]
+		if n > 1 || f.{{.Name}}Func != nil {
+			f.{{.Field}}Queue = f.{{.Field}}Queue[1:]
+		}
+		f.mu.Unlock()
+		return resp.OK, resp.Err
+	}
+	fn := f.{{.Name}}Func
+	f.mu.Unlock()
+
+	if fn == nil {
+		return nil, &NoResponseError{Operation: "{{.Name}}"}
+	}
+	return fn(params, opts...)
+}
+
+// Return{{.Name}} queues a successful {{.Name}} response with payload.
+func (f *Fake) Return{{.Name}}(payload {{.PayloadType}}) *Fake {
+	return f.queue{{.Name}}({{.Name}}Response{OK: &operations.{{.OK}}{Payload: payload}})
+}
+
+// Fail{{.Name}} queues a {{.Name}} API error, as returned by the real client
+// for a non-2xx status, with a models.Response payload holding code and message.
+func (f *Fake) Fail{{.Name}}(statusCode int, code int64, message string) *Fake {
+	resp := operations.New{{.Default}}(statusCode)
+	resp.Payload = &models.Response{Error: &models.Data{Code: code, Message: message}}
+	return f.queue{{.Name}}({{.Name}}Response{Err: resp})
+}
+
+// Error{{.Name}} queues err, such as a network error, as a {{.Name}} response.
+func (f *Fake) Error{{.Name}}(err error) *Fake {
+	return f.queue{{.Name}}({{.Name}}Response{Err: err})
+}
+
+func (f *Fake) queue{{.Name}}(resp {{.Name}}Response) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.{{.Field}}Queue = append(f.{{.Field}}Queue, resp)
+	return f
+}
+
+// {{.Name}}CallCount returns the number of {{.Name}} calls.
+func (f *Fake) {{.Name}}CallCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.{{.Field}}Calls)
+}
+
+// {{.Name}}Calls returns a copy of the recorded {{.Name}} calls, in order.
+func (f *Fake) {{.Name}}Calls() []{{.Name}}Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]{{.Name}}Call(nil), f.{{.Field}}Calls...)
+}
+
+// {{.Name}}Bodies returns the request bodies of all {{.Name}} calls, in order.
+func (f *Fake) {{.Name}}Bodies() []{{.BodyType}} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bodies := make([]{{.BodyType}}, len(f.{{.Field}}Calls))
+	for i, c := range f.{{.Field}}Calls {
+		if c.Params != nil {
+			bodies[i] = c.Params.Body
+		}
+	}
+	return bodies
+}
+
+// Assert{{.Name}}Called fails t unless {{.Name}} was called exactly n times.
+func (f *Fake) Assert{{.Name}}Called(t testing.TB, n int) {
+	t.Helper()
+	if got := f.{{.Name}}CallCount(); got != n {
+		t.Errorf("Expected {{.Name}} to be called %d times, got %d", n, got)
+	}
+}
+
+// Assert{{.Name}}CalledWith fails t unless some {{.Name}} call had a body
+// deeply equal to body.
+func (f *Fake) Assert{{.Name}}CalledWith(t testing.TB, body {{.BodyType}}) {
+	t.Helper()
+	bodies := f.{{.Name}}Bodies()
+	for _, b := range bodies {
+		if reflect.DeepEqual(b, body) {
+			return
+		}
+	}
+	t.Errorf("Expected {{.Name}} to be called with %+v, got %d calls with %s", body, len(bodies), formatBodies(bodies))
+}
+{{end}}`))
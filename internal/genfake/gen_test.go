@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// TestFakeUpToDate fails when fake/fake_gen.go was not regenerated after the
+// operations package changed.
+func TestFakeUpToDate(t *testing.T) {
+	want, err := Generate("../../generated/client/operations")
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	got, err := os.ReadFile("../../fake/fake_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("fake/fake_gen.go is out of date, run \"go generate ./fake\"")
+	}
+}
+
+func TestLowerInitialism(t *testing.T) {
+	for in, want := range map[string]string{
+		"IPLookupRequestData":        "ipLookupRequestData",
+		"EmailValidationRequestData": "emailValidationRequestData",
+		"ID":                         "id",
+	} {
+		if got := lowerInitialism(in); got != want {
+			t.Errorf("lowerInitialism(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+// Command genfake generates the fake operations.ClientService in package
+// cerberius.com/go-client/fake from the generated operations package.
+//
+// It is run through go generate in the fake package after the client has
+// been regenerated from cerberus_schema.json:
+//
+//	go generate ./fake
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+)
+
+func main() {
+	src := flag.String("src", "../generated/client/operations", "directory of the generated operations package")
+	out := flag.String("out", "fake_gen.go", "output file")
+	flag.Parse()
+
+	b, err := Generate(*src)
+	if err != nil {
+		log.Fatalf("genfake: %v", err)
+	}
+	if err := os.WriteFile(*out, b, 0o644); err != nil {
+		log.Fatalf("genfake: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+// Package ratelimit implements the token bucket rate limiter shared by the
+// client packages.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrDeadline is returned by Wait when the context deadline expires before
+// a token becomes available. It wraps context.DeadlineExceeded.
+var ErrDeadline = fmt.Errorf("ratelimit: wait would exceed context deadline: %w", context.DeadlineExceeded)
+
+// Limiter is a token bucket that allows Rate events per second with bursts
+// of up to Burst events. A nil *Limiter allows every event.
+type Limiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// New creates a new Limiter allowing rate events per second and bursts of
+// burst events. The bucket starts full. If rate is not positive, New
+// returns nil, which allows every event. A burst below 1 is treated as 1.
+func New(rate float64, burst int) *Limiter {
+	if rate <= 0 || math.IsInf(rate, 1) {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{rate: rate, burst: float64(burst), tokens: float64(burst), now: time.Now}
+}
+
+// Wait blocks until an event is allowed or ctx is done. If ctx has a
+// deadline that expires before the event would be allowed, Wait returns
+// ErrDeadline immediately instead of sleeping. Waiting is cancelled with
+// the context error; a cancelled wait does not consume a token. Wait
+// returns the time spent waiting.
+func (l *Limiter) Wait(ctx context.Context) (time.Duration, error) {
+	if l == nil {
+		return 0, ctx.Err()
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	l.advance(now)
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+		l.tokens++
+		l.mu.Unlock()
+		return 0, ErrDeadline
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return 0, nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return delay, nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return 0, ctx.Err()
+	}
+}
+
+// Allow reports whether an event is allowed now, consuming a token if so.
+func (l *Limiter) Allow() bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(l.now())
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// advance refills the bucket for the time elapsed since the last call.
+func (l *Limiter) advance(now time.Time) {
+	if !l.last.IsZero() {
+		l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a Limiter with a controllable clock.
+func fakeClock(rate float64, burst int) (*Limiter, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	l := New(rate, burst)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestAllowRefills(t *testing.T) {
+	l, now := fakeClock(2, 2)
+	if !l.Allow() || !l.Allow() {
+		t.Fatal("Expected the initial burst to be allowed")
+	}
+	if l.Allow() {
+		t.Fatal("Expected an empty bucket to refuse")
+	}
+	*now = now.Add(500 * time.Millisecond)
+	if !l.Allow() {
+		t.Fatal("Expected one token after 500ms at 2/s")
+	}
+	*now = now.Add(time.Hour)
+	if !l.Allow() || !l.Allow() || l.Allow() {
+		t.Fatal("Expected the refill to be capped at the burst")
+	}
+}
+
+func TestWait(t *testing.T) {
+	l := New(50, 1)
+	ctx := context.Background()
+	if d, err := l.Wait(ctx); err != nil || d != 0 {
+		t.Fatalf("Expected the first event to pass immediately, got %v, %v", d, err)
+	}
+	start := time.Now()
+	if _, err := l.Wait(ctx); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("Expected to wait about 20ms, waited %v", elapsed)
+	}
+}
+
+func TestWaitDeadline(t *testing.T) {
+	l := New(1, 1)
+	l.Allow()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := l.Wait(ctx); !errors.Is(err, ErrDeadline) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected ErrDeadline, got %v", err)
+	}
+	if time.Since(start) > 50*time.Millisecond {
+		t.Error("Expected Wait to fail without sleeping")
+	}
+	if l.tokens < -0.5 {
+		t.Errorf("Expected the token to be returned, have %v", l.tokens)
+	}
+}
+
+func TestWaitCancel(t *testing.T) {
+	l := New(1, 1)
+	l.Allow()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := l.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter = New(0, 10)
+	if l != nil || !l.Allow() {
+		t.Fatal("Expected a nil Limiter that allows everything")
+	}
+	if _, err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,124 @@
+package ipdb
+
+import (
+	"sync/atomic"
+
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+
+	"github.com/go-openapi/runtime"
+)
+
+// Stats are the counters of a Client.
+type Stats struct {
+	Hits   int64 // Hits is the number of addresses answered by the DB.
+	Misses int64 // Misses is the number of addresses looked up remotely.
+}
+
+// Client is an operations.ClientService that answers IP lookups from a DB
+// first and looks up only the missing addresses with the next service.
+type Client struct {
+	db   *DB
+	next operations.ClientService
+
+	hits, misses atomic.Int64
+}
+
+var _ operations.ClientService = (*Client)(nil)
+
+// NewClient creates a new Client that answers IP lookups from db before
+// calling next.
+func NewClient(db *DB, next operations.ClientService) *Client {
+	return &Client{db: db, next: next}
+}
+
+// Stats returns the counters of the client.
+func (c *Client) Stats() Stats {
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// IPLookupRequestData implements operations.ClientService. Results are
+// returned in the order of the request, one for each requested address
+// including repeated ones; the next service is only called if some
+// addresses are not in the DB, and with each missing address once.
+func (c *Client) IPLookupRequestData(params *operations.IPLookupRequestDataParams, opts ...operations.ClientOption) (*operations.IPLookupRequestDataOK, error) {
+	if params == nil || params.Body == nil {
+		return c.next.IPLookupRequestData(params, opts...)
+	}
+
+	results := make([]*models.IPData, len(params.Body.Data))
+	var misses []string
+	missed := make(map[string]bool)
+	hits := 0
+	for i, ip := range params.Body.Data {
+		if data, ok := c.db.LookupIP(ip); ok {
+			results[i] = data
+			hits++
+		} else if !missed[ip] {
+			missed[ip] = true
+			misses = append(misses, ip)
+		}
+	}
+	c.hits.Add(int64(hits))
+	c.misses.Add(int64(len(misses)))
+	if len(misses) == 0 {
+		return &operations.IPLookupRequestDataOK{Payload: &models.IPLookupResponse{Data: results}}, nil
+	}
+
+	remote := *params
+	remote.Body = &models.IPLookupRequest{Data: misses}
+	resp, err := c.next.IPLookupRequestData(&remote, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Place the remote results where their addresses were requested, a
+	// copy for each repeated address, and append those whose address the
+	// API normalised.
+	var remoteData []*models.IPData
+	if resp.Payload != nil {
+		remoteData = resp.Payload.Data
+	}
+	byIP := make(map[string]*models.IPData, len(remoteData))
+	for _, data := range remoteData {
+		if data != nil && byIP[data.IPAddress] == nil {
+			byIP[data.IPAddress] = data
+		}
+	}
+	used := make(map[*models.IPData]bool, len(remoteData))
+	merged := make([]*models.IPData, 0, len(results))
+	for i, data := range results {
+		if data == nil {
+			if data = byIP[params.Body.Data[i]]; data == nil {
+				continue
+			}
+			if used[data] {
+				cp := *data
+				data = &cp
+			}
+			used[data] = true
+		}
+		merged = append(merged, data)
+	}
+	for _, data := range remoteData {
+		if data != nil && !used[data] {
+			merged = append(merged, data)
+		}
+	}
+	return &operations.IPLookupRequestDataOK{Payload: &models.IPLookupResponse{Data: merged}}, nil
+}
+
+// EmailValidationRequestData implements operations.ClientService.
+func (c *Client) EmailValidationRequestData(params *operations.EmailValidationRequestDataParams, opts ...operations.ClientOption) (*operations.EmailValidationRequestDataOK, error) {
+	return c.next.EmailValidationRequestData(params, opts...)
+}
+
+// PromptCheckRequestData implements operations.ClientService.
+func (c *Client) PromptCheckRequestData(params *operations.PromptCheckRequestDataParams, opts ...operations.ClientOption) (*operations.PromptCheckRequestDataOK, error) {
+	return c.next.PromptCheckRequestData(params, opts...)
+}
+
+// SetTransport implements operations.ClientService.
+func (c *Client) SetTransport(transport runtime.ClientTransport) {
+	c.next.SetTransport(transport)
+}
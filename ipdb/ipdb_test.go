@@ -0,0 +1,203 @@
+package ipdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cerberius.com/go-client/fake"
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+)
+
+func build(t *testing.T, path string, fn func(b *Builder)) {
+	t.Helper()
+	b := NewBuilder()
+	fn(b)
+	if err := b.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLookupIP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ips.cipdb")
+	build(t, path, func(b *Builder) {
+		b.AddPrefix(netip.MustParsePrefix("10.0.0.0/8"), &models.IPData{OrgName: "wide"})
+		b.AddPrefix(netip.MustParsePrefix("10.1.0.0/16"), &models.IPData{OrgName: "narrow"})
+		b.AddPrefix(netip.MustParsePrefix("0.0.0.0/0"), &models.IPData{OrgName: "default"})
+		if err := b.Add(
+			&models.IPData{IPAddress: "10.1.2.3", OrgName: "host", FraudScore: "90"},
+			&models.IPData{IPAddress: "2001:db8::1", OrgName: "v6"},
+			&models.IPData{IPAddress: "2001:db8::2", OrgName: "v6"},
+		); err != nil {
+			t.Fatal(err)
+		}
+	})
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for ip, want := range map[string]string{
+		"10.1.2.3":        "host",
+		"::ffff:10.1.2.3": "host",
+		"10.1.2.2":        "narrow",
+		"10.1.2.4":        "narrow",
+		"10.200.0.1":      "wide",
+		"11.0.0.1":        "default",
+		"0.0.0.0":         "default",
+		"255.255.255.255": "default",
+		"2001:db8::1":     "v6",
+		"2001:db8::3":     "",
+		"not an ip":       "",
+	} {
+		got, ok := db.LookupIP(ip)
+		if want == "" {
+			if ok {
+				t.Errorf("LookupIP(%q) = %+v, want a miss", ip, got)
+			}
+			continue
+		}
+		if !ok || got.OrgName != want || got.IPAddress != ip {
+			t.Errorf("LookupIP(%q) = %+v, %v; want %s", ip, got, ok, want)
+		}
+	}
+
+	// Results are copies, and identical records are stored once.
+	got, _ := db.LookupIP("10.1.2.3")
+	got.OrgName = "changed"
+	if again, _ := db.LookupIP("10.1.2.3"); again.OrgName != "host" {
+		t.Error("Expected lookups to return copies")
+	}
+	if n := len(db.table.Load().records); n != 5 {
+		t.Errorf("Expected 5 distinct records, got %d", n)
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ips.cipdb")
+	build(t, path, func(b *Builder) { b.Add(&models.IPData{IPAddress: "192.0.2.1", OrgName: "old"}) })
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	build(t, path, func(b *Builder) { b.Add(&models.IPData{IPAddress: "192.0.2.1", OrgName: "new"}) })
+	if err := db.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := db.LookupIP("192.0.2.1"); got.OrgName != "new" {
+		t.Errorf("Expected the new file, got %+v", got)
+	}
+
+	// A corrupt file is refused and the loaded file stays in use.
+	b, _ := os.ReadFile(path)
+	b[len(b)/2] ^= 0xff
+	os.WriteFile(path, b, 0o644)
+	if err := db.Reload(); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected ErrCorrupt, got %v", err)
+	}
+	if got, ok := db.LookupIP("192.0.2.1"); !ok || got.OrgName != "new" {
+		t.Errorf("Expected the previous file to stay loaded, got %+v", got)
+	}
+	if _, err := parse(bytes.Repeat([]byte{0}, 10)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Expected ErrCorrupt for garbage, got %v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ips.cipdb")
+	build(t, path, func(b *Builder) { b.Add(&models.IPData{IPAddress: "192.0.2.1", OrgName: "old"}) })
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi, _ := os.Stat(path)
+
+	// A replacement of the same size and modification time is noticed by
+	// its build time.
+	build(t, path, func(b *Builder) { b.Add(&models.IPData{IPAddress: "192.0.2.1", OrgName: "new"}) })
+	os.Chtimes(path, fi.ModTime(), fi.ModTime())
+	if again, _ := os.Stat(path); again.Size() != fi.Size() {
+		t.Fatalf("Expected the same size, got %d and %d", again.Size(), fi.Size())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		db.Watch(ctx, 0, nil) // A zero interval must not panic.
+	}()
+	cancel()
+	<-done
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go db.Watch(ctx, time.Millisecond, func(err error) { t.Error(err) })
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if got, _ := db.LookupIP("192.0.2.1"); got.OrgName == "new" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected Watch to reload the replaced file")
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ips.cipdb")
+	build(t, path, func(b *Builder) {
+		b.Add(&models.IPData{IPAddress: "192.0.2.1", OrgName: "cached"}, &models.IPData{IPAddress: "192.0.2.3", OrgName: "cached"})
+	})
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := fake.New().ReturnIPLookupRequestData(&models.IPLookupResponse{Data: []*models.IPData{{IPAddress: "192.0.2.2", OrgName: "remote"}}})
+	c := NewClient(db, svc)
+
+	params := operations.NewIPLookupRequestDataParams().WithBody(&models.IPLookupRequest{Data: []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}})
+	resp, err := c.IPLookupRequestData(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range resp.Payload.Data {
+		got = append(got, d.IPAddress+"="+d.OrgName)
+	}
+	if want := "[192.0.2.1=cached 192.0.2.2=remote 192.0.2.3=cached]"; fmt.Sprint(got) != want {
+		t.Errorf("Got %v, want %s", got, want)
+	}
+	svc.AssertIPLookupRequestDataCalledWith(t, &models.IPLookupRequest{Data: []string{"192.0.2.2"}})
+
+	// Full hits do not call the API.
+	c.IPLookupRequestData(operations.NewIPLookupRequestDataParams().WithBody(&models.IPLookupRequest{Data: []string{"192.0.2.3"}}))
+	svc.AssertIPLookupRequestDataCalled(t, 1)
+	if s := c.Stats(); s.Hits != 3 || s.Misses != 1 {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+
+	// Repeated addresses get a result each, and are looked up once.
+	params = operations.NewIPLookupRequestDataParams().WithBody(&models.IPLookupRequest{Data: []string{"192.0.2.2", "192.0.2.1", "192.0.2.2", "192.0.2.1"}})
+	resp, err = c.IPLookupRequestData(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, d := range resp.Payload.Data {
+		got = append(got, d.IPAddress+"="+d.OrgName)
+	}
+	if want := "[192.0.2.2=remote 192.0.2.1=cached 192.0.2.2=remote 192.0.2.1=cached]"; fmt.Sprint(got) != want {
+		t.Errorf("Got %v, want %s", got, want)
+	}
+	if resp.Payload.Data[0] == resp.Payload.Data[2] {
+		t.Error("Expected repeated results to be copies")
+	}
+	svc.AssertIPLookupRequestDataCalledWith(t, &models.IPLookupRequest{Data: []string{"192.0.2.2"}})
+}
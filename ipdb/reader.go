@@ -0,0 +1,211 @@
+package ipdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"cerberius.com/go-client/generated/models"
+)
+
+// ErrCorrupt is returned for files that are not valid DB files.
+var ErrCorrupt = errors.New("ipdb: corrupt file")
+
+// table is a loaded DB file.
+type table struct {
+	built   time.Time
+	ranges  []byte // ranges holds the raw range entries.
+	n       int
+	records []models.IPData
+	modTime time.Time
+	size    int64
+}
+
+// parse parses a DB file.
+func parse(b []byte) (*table, error) {
+	le := binary.LittleEndian
+	if len(b) < headerSize+4 || string(b[:len(magic)]) != magic {
+		return nil, fmt.Errorf("%w: bad header", ErrCorrupt)
+	}
+	body := b[:len(b)-4]
+	if crc32.ChecksumIEEE(body) != le.Uint32(b[len(b)-4:]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+	p := len(magic)
+	t := &table{built: time.Unix(0, int64(le.Uint64(body[p:])))}
+	n := int(le.Uint32(body[p+8:]))
+	records := int(le.Uint32(body[p+12:]))
+	p = headerSize
+
+	rangesEnd := p + n*rangeSize
+	offsetsEnd := rangesEnd + (records+1)*4
+	if offsetsEnd > len(body) {
+		return nil, fmt.Errorf("%w: truncated index", ErrCorrupt)
+	}
+	t.ranges, t.n = body[p:rangesEnd], n
+	blob := body[offsetsEnd:]
+
+	t.records = make([]models.IPData, records)
+	for i := range t.records {
+		start, end := le.Uint32(body[rangesEnd+4*i:]), le.Uint32(body[rangesEnd+4*i+4:])
+		if start > end || int(end) > len(blob) {
+			return nil, fmt.Errorf("%w: bad record offset", ErrCorrupt)
+		}
+		if err := json.Unmarshal(blob[start:end], &t.records[i]); err != nil {
+			return nil, fmt.Errorf("%w: record %d: %v", ErrCorrupt, i, err)
+		}
+	}
+	for i := 0; i < n; i++ {
+		if int(le.Uint32(t.ranges[i*rangeSize+32:])) >= records {
+			return nil, fmt.Errorf("%w: bad record index", ErrCorrupt)
+		}
+	}
+	return t, nil
+}
+
+// lookup returns the record of addr.
+func (t *table) lookup(addr netip.Addr) (*models.IPData, bool) {
+	key := addr.As16()
+	// Find the last range starting at or before addr.
+	i := sort.Search(t.n, func(i int) bool {
+		return bytes.Compare(t.ranges[i*rangeSize:i*rangeSize+16], key[:]) > 0
+	}) - 1
+	if i < 0 {
+		return nil, false
+	}
+	r := t.ranges[i*rangeSize : (i+1)*rangeSize]
+	if bytes.Compare(key[:], r[16:32]) > 0 {
+		return nil, false
+	}
+	return &t.records[binary.LittleEndian.Uint32(r[32:])], true
+}
+
+// DB answers IP lookups from a DB file. It is safe for concurrent use.
+type DB struct {
+	path  string
+	table atomic.Pointer[table]
+}
+
+// Open loads the DB file at path.
+func Open(path string) (*DB, error) {
+	db := &DB{path: path}
+	if err := db.Reload(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Reload loads the DB file again and swaps it in atomically. Lookups in
+// progress complete against the previous file. If the file cannot be
+// loaded, the previous file stays in use and the error is returned.
+func (db *DB) Reload() error {
+	fi, err := os.Stat(db.path)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(db.path)
+	if err != nil {
+		return err
+	}
+	t, err := parse(b)
+	if err != nil {
+		return fmt.Errorf("%w (%s)", err, db.path)
+	}
+	t.modTime, t.size = fi.ModTime(), fi.Size()
+	db.table.Store(t)
+	return nil
+}
+
+// DefaultWatchInterval is the interval Watch uses if none is given.
+const DefaultWatchInterval = time.Minute
+
+// Watch reloads the DB file whenever it changes, checking every interval
+// (DefaultWatchInterval if it is not positive), until ctx is done. A
+// change is a new modification time or size, or a new build time in the
+// file header, so that files replaced within one modification time tick
+// are noticed as well. Reload errors are passed to onError if it is not
+// nil.
+func (db *DB) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed, err := db.changed()
+		if err == nil && !changed {
+			continue
+		}
+		if err == nil {
+			err = db.Reload()
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// changed reports whether the DB file differs from the loaded file.
+func (db *DB) changed() (bool, error) {
+	t := db.table.Load()
+	fi, err := os.Stat(db.path)
+	if err != nil {
+		return false, err
+	}
+	if !fi.ModTime().Equal(t.modTime) || fi.Size() != t.size {
+		return true, nil
+	}
+	f, err := os.Open(db.path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	var header [headerSize]byte
+	if _, err := io.ReadFull(f, header[:]); err != nil {
+		// A short file is not the loaded one; let Reload report it.
+		return true, nil
+	}
+	built := time.Unix(0, int64(binary.LittleEndian.Uint64(header[len(magic):])))
+	return !built.Equal(t.built), nil
+}
+
+// LookupIP returns the stored lookup result of ip, with its IPAddress set
+// to ip. The result is a copy the caller may modify.
+func (db *DB) LookupIP(ip string) (*models.IPData, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, false
+	}
+	rec, ok := db.table.Load().lookup(addr)
+	if !ok {
+		return nil, false
+	}
+	cp := *rec
+	cp.IPAddress = ip
+	return &cp, true
+}
+
+// Len returns the number of address ranges of the loaded file.
+func (db *DB) Len() int {
+	return db.table.Load().n
+}
+
+// Built returns the time the loaded file was written.
+func (db *DB) Built() time.Time {
+	return db.table.Load().built
+}
@@ -0,0 +1,229 @@
+// Package ipdb stores IP lookup results in a compact, indexed, read-only
+// file for components that cannot call the API.
+//
+// A Builder collects models.IPData results keyed by IP address or prefix
+// and writes them to a file. A DB loads the file and answers LookupIP from
+// memory in microseconds; Reload swaps in a new file atomically while
+// lookups continue. Client puts a DB in front of the live API as a first
+// tier, so that only misses are looked up remotely:
+//
+//	b := ipdb.NewBuilder()
+//	b.Add(resp.Payload.Data...)
+//	err := b.WriteFile("ips.cipdb")
+//
+//	db, err := ipdb.Open("ips.cipdb")
+//	ip, ok := db.LookupIP("192.0.2.1")
+//
+// Prefixes may be nested; the most specific prefix containing an address
+// answers its lookups.
+package ipdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"cerberius.com/go-client/generated/models"
+)
+
+// File layout, all integers little endian:
+//
+//	magic       [6]byte "CIPDB\x01"
+//	built       int64   Unix nanoseconds
+//	ranges      uint32  number of ranges
+//	records     uint32  number of records
+//	ranges      ranges × (first [16]byte, last [16]byte, record uint32)
+//	offsets     (records+1) × uint32, offsets of the records in the blob
+//	blob        JSON encoded records without their IP address
+//	checksum    uint32  CRC-32 (IEEE) of everything before it
+//
+// Ranges are sorted, disjoint and hold IPv4 addresses in their IPv4-mapped
+// IPv6 form.
+const (
+	magic      = "CIPDB\x01"
+	headerSize = len(magic) + 8 + 4 + 4
+	rangeSize  = 16 + 16 + 4
+)
+
+// Builder collects IP lookup results for a DB file.
+type Builder struct {
+	entries map[netip.Prefix]*models.IPData
+}
+
+// NewBuilder creates a new, empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{entries: make(map[netip.Prefix]*models.IPData)}
+}
+
+// Len returns the number of addresses and prefixes collected.
+func (b *Builder) Len() int {
+	return len(b.entries)
+}
+
+// Add adds lookup results keyed by their IP address. A later result for
+// the same address replaces an earlier one.
+func (b *Builder) Add(ips ...*models.IPData) error {
+	for _, ip := range ips {
+		if ip == nil {
+			continue
+		}
+		addr, err := netip.ParseAddr(ip.IPAddress)
+		if err != nil {
+			return fmt.Errorf("ipdb: %w", err)
+		}
+		b.entries[netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())] = ip
+	}
+	return nil
+}
+
+// AddPrefix adds a lookup result for every address of prefix.
+func (b *Builder) AddPrefix(prefix netip.Prefix, ip *models.IPData) error {
+	if !prefix.IsValid() || ip == nil {
+		return fmt.Errorf("ipdb: invalid prefix %s", prefix)
+	}
+	b.entries[prefix.Masked()] = ip
+	return nil
+}
+
+// span is a range of addresses answered by a record.
+type span struct {
+	first, last netip.Addr
+	record      int
+}
+
+// WriteTo writes the DB file to w.
+func (b *Builder) WriteTo(w io.Writer) (int64, error) {
+	// Deduplicate records, which share the data of their network.
+	var blob bytes.Buffer
+	offsets := []uint32{0}
+	records := make(map[string]int)
+	type entry struct {
+		prefix      netip.Prefix
+		first, last netip.Addr
+		record      int
+	}
+	entries := make([]entry, 0, len(b.entries))
+	for prefix, ip := range b.entries {
+		rec := *ip
+		rec.IPAddress = ""
+		data, err := json.Marshal(&rec)
+		if err != nil {
+			return 0, fmt.Errorf("ipdb: %w", err)
+		}
+		idx, ok := records[string(data)]
+		if !ok {
+			idx = len(records)
+			records[string(data)] = idx
+			blob.Write(data)
+			offsets = append(offsets, uint32(blob.Len()))
+		}
+		first, last := bounds(prefix)
+		entries = append(entries, entry{prefix, first, last, idx})
+	}
+
+	// Sort wider prefixes before the prefixes they contain and flatten the
+	// nesting into disjoint ranges.
+	sort.Slice(entries, func(i, j int) bool {
+		if c := entries[i].first.Compare(entries[j].first); c != 0 {
+			return c < 0
+		}
+		return entries[i].prefix.Bits() < entries[j].prefix.Bits()
+	})
+	var spans []span
+	emit := func(first, last netip.Addr, record int) {
+		if !first.IsValid() || first.Compare(last) > 0 {
+			return
+		}
+		if n := len(spans); n > 0 && spans[n-1].record == record && spans[n-1].last.Next() == first {
+			spans[n-1].last = last
+			return
+		}
+		spans = append(spans, span{first, last, record})
+	}
+	var stack []entry
+	var cursor netip.Addr
+	pop := func() {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		emit(cursor, top.last, top.record)
+		cursor = top.last.Next()
+	}
+	for _, e := range entries {
+		for len(stack) > 0 && stack[len(stack)-1].last.Compare(e.first) < 0 {
+			pop()
+		}
+		if len(stack) > 0 {
+			emit(cursor, e.first.Prev(), stack[len(stack)-1].record)
+		}
+		cursor = e.first
+		stack = append(stack, e)
+	}
+	for len(stack) > 0 {
+		pop()
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	le := binary.LittleEndian
+	buf.Write(le.AppendUint64(nil, uint64(time.Now().UnixNano())))
+	buf.Write(le.AppendUint32(nil, uint32(len(spans))))
+	buf.Write(le.AppendUint32(nil, uint32(len(records))))
+	for _, s := range spans {
+		first, last := s.first.As16(), s.last.As16()
+		buf.Write(first[:])
+		buf.Write(last[:])
+		buf.Write(le.AppendUint32(nil, uint32(s.record)))
+	}
+	for _, off := range offsets {
+		buf.Write(le.AppendUint32(nil, off))
+	}
+	buf.Write(blob.Bytes())
+	buf.Write(le.AppendUint32(nil, crc32.ChecksumIEEE(buf.Bytes())))
+	return buf.WriteTo(w)
+}
+
+// WriteFile writes the DB file to path. The file is replaced atomically,
+// so a DB reloading it never sees a partial file.
+func (b *Builder) WriteFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := b.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// bounds returns the first and last address of prefix in their 16 byte
+// form.
+func bounds(prefix netip.Prefix) (first, last netip.Addr) {
+	bits := prefix.Bits()
+	if prefix.Addr().Is4() {
+		bits += 96
+	}
+	a := prefix.Addr().As16()
+	for i := range a {
+		if n := bits - 8*i; n < 8 {
+			a[i] |= 0xff >> max(n, 0)
+		}
+	}
+	return netip.AddrFrom16(prefix.Addr().As16()), netip.AddrFrom16(a)
+}
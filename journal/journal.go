@@ -0,0 +1,491 @@
+// Package journal runs long bulk IP lookups and email validations as
+// resumable jobs.
+//
+// A Job splits its input into fixed-size batches and records every
+// submitted batch and its results in a local append-only journal file. When
+// a job is reopened after a crash, the journal is replayed and only the
+// batches without a recorded result are submitted again, so work that has
+// already been paid for is never repeated.
+package journal
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+)
+
+// Kind is the operation a job runs.
+type Kind string
+
+const (
+	// KindIPLookup runs IPLookupRequestData.
+	KindIPLookup Kind = "ip-lookup"
+	// KindEmailValidation runs EmailValidationRequestData.
+	KindEmailValidation Kind = "email-validation"
+)
+
+// DefaultBatchSize is the number of items per batch when
+// Config.BatchSize is not set.
+const DefaultBatchSize = 100
+
+// ErrJournalMismatch is returned by Open when the journal file belongs to a
+// job with a different kind, batch size or input.
+var ErrJournalMismatch = errors.New("journal: journal does not match job")
+
+// ErrBatchesFailed is returned by Job.Run when some batches failed. The
+// failures are recorded in the journal and retried by the next Run.
+var ErrBatchesFailed = errors.New("journal: some batches failed")
+
+// Config describes a job.
+type Config struct {
+	Kind    Kind                     // Kind is the operation to run.
+	Service operations.ClientService // Service is the operations client used to submit batches.
+	Items   []string                 // Items are the IP addresses or email addresses to process.
+
+	// BatchSize is the number of items per request, DefaultBatchSize if
+	// zero. It must not change between runs of the same journal.
+	BatchSize int
+
+	// Concurrency is the number of batches submitted in parallel, 1 if zero.
+	Concurrency int
+}
+
+// batchState is the replayed state of a single batch.
+type batchState int
+
+const (
+	batchPending batchState = iota
+	batchDone
+	batchFailed
+)
+
+// Job is a resumable bulk job backed by a journal file.
+type Job struct {
+	cfg  Config
+	path string
+
+	mu       sync.Mutex
+	file     *os.File
+	states   []batchState
+	errs     map[int]string
+	credits  int64
+	excess   bool
+	runStart time.Time
+	runDone  int // batches completed since runStart
+}
+
+// Open opens or creates the journal at path for the job described by cfg
+// and replays it. A journal written for a different job is rejected with
+// ErrJournalMismatch.
+func Open(path string, cfg Config) (*Job, error) {
+	if cfg.Kind != KindIPLookup && cfg.Kind != KindEmailValidation {
+		return nil, fmt.Errorf("journal: unknown job kind %q", cfg.Kind)
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &Job{
+		cfg:    cfg,
+		path:   path,
+		file:   f,
+		states: make([]batchState, (len(cfg.Items)+cfg.BatchSize-1)/cfg.BatchSize),
+		errs:   make(map[int]string),
+	}
+	if err := j.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// Close closes the journal file.
+func (j *Job) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// Run submits every batch that has no recorded result, including batches
+// that failed in earlier runs. It returns ErrBatchesFailed if any batch
+// failed, and the context error if ctx is cancelled. If the journal
+// cannot be written, no further batches are submitted and the write error
+// is returned. Progress made before an error is kept in the journal.
+func (j *Job) Run(ctx context.Context) error {
+	var todo []int
+	j.mu.Lock()
+	for i, s := range j.states {
+		if s != batchDone {
+			todo = append(todo, i)
+		}
+	}
+	j.runStart = time.Now()
+	j.runDone = 0
+	j.mu.Unlock()
+
+	// Results that cannot be journaled would be paid for again by the
+	// next run, so the first journal error stops the run.
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	work := make(chan int)
+	var wg sync.WaitGroup
+	var writeErr error
+	var writeErrOnce sync.Once
+	for w := 0; w < j.cfg.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range work {
+				if runCtx.Err() != nil {
+					continue
+				}
+				if err := j.runBatch(runCtx, batch); err != nil {
+					writeErrOnce.Do(func() { writeErr = err })
+					stop()
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, batch := range todo {
+		select {
+		case work <- batch:
+		case <-runCtx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if writeErr != nil {
+		return writeErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed := len(j.Status().Failed); failed > 0 {
+		return fmt.Errorf("%w: %d of %d", ErrBatchesFailed, failed, len(j.states))
+	}
+	return nil
+}
+
+// runBatch submits a single batch and journals the outcome. The returned
+// error is only set when the journal itself could not be written.
+func (j *Job) runBatch(ctx context.Context, batch int) error {
+	if err := j.append(&record{Type: recordSubmit, Batch: batch}); err != nil {
+		return err
+	}
+
+	items := j.batchItems(batch)
+	rec := &record{Type: recordResult, Batch: batch}
+	var err error
+	switch j.cfg.Kind {
+	case KindIPLookup:
+		params := operations.NewIPLookupRequestDataParamsWithContext(ctx).
+			WithBody(&models.IPLookupRequest{Data: items})
+		var resp *operations.IPLookupRequestDataOK
+		if resp, err = j.cfg.Service.IPLookupRequestData(params); err == nil && resp.Payload != nil {
+			rec.IPData = resp.Payload.Data
+			rec.ExcessChargesApply = resp.Payload.ExcessChargesApply
+		}
+	case KindEmailValidation:
+		params := operations.NewEmailValidationRequestDataParamsWithContext(ctx).
+			WithBody(&models.EmailLookupRequest{Data: items})
+		var resp *operations.EmailValidationRequestDataOK
+		if resp, err = j.cfg.Service.EmailValidationRequestData(params); err == nil && resp.Payload != nil {
+			rec.EmailData = resp.Payload.Data
+			rec.ExcessChargesApply = resp.Payload.ExcessChargesApply
+		}
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			// A cancelled batch is left pending rather than failed.
+			return nil
+		}
+		rec = &record{Type: recordFailure, Batch: batch, Error: err.Error()}
+	}
+
+	if err := j.append(rec); err != nil {
+		return err
+	}
+	j.mu.Lock()
+	j.apply(rec)
+	if rec.Type == recordResult {
+		j.runDone++
+	}
+	j.mu.Unlock()
+	return nil
+}
+
+// batchItems returns the items of batch.
+func (j *Job) batchItems(batch int) []string {
+	start := batch * j.cfg.BatchSize
+	end := min(start+j.cfg.BatchSize, len(j.cfg.Items))
+	return j.cfg.Items[start:end]
+}
+
+// FailedBatch describes a batch whose last attempt failed.
+type FailedBatch struct {
+	Batch int    // Batch is the zero-based batch number.
+	Items int    // Items is the number of items in the batch.
+	Error string // Error is the error of the last attempt.
+}
+
+// Status is a snapshot of the progress of a job.
+type Status struct {
+	TotalBatches     int           // TotalBatches is the number of batches in the job.
+	CompletedBatches int           // CompletedBatches is the number of batches with a recorded result.
+	PendingBatches   int           // PendingBatches is the number of batches not yet attempted or interrupted.
+	Failed           []FailedBatch // Failed lists the batches whose last attempt failed.
+
+	// CreditsConsumed is the number of items with a recorded result, which
+	// is what the service bills for.
+	CreditsConsumed int64
+
+	// ExcessChargesApply reports whether any response flagged excess charges.
+	ExcessChargesApply bool
+
+	// ETA is the estimated time to finish the remaining batches, based on the
+	// throughput of the current Run. It is zero when unknown or done.
+	ETA time.Duration
+}
+
+// Progress returns the completed fraction of the job, between 0 and 1.
+func (s Status) Progress() float64 {
+	if s.TotalBatches == 0 {
+		return 1
+	}
+	return float64(s.CompletedBatches) / float64(s.TotalBatches)
+}
+
+// Status reports the progress of the job. It is safe to call while Run is
+// in progress.
+func (j *Job) Status() Status {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	st := Status{
+		TotalBatches:       len(j.states),
+		CreditsConsumed:    j.credits,
+		ExcessChargesApply: j.excess,
+	}
+	for i, s := range j.states {
+		switch s {
+		case batchDone:
+			st.CompletedBatches++
+		case batchFailed:
+			st.Failed = append(st.Failed, FailedBatch{Batch: i, Items: len(j.batchItems(i)), Error: j.errs[i]})
+		default:
+			st.PendingBatches++
+		}
+	}
+
+	remaining := st.TotalBatches - st.CompletedBatches
+	if j.runDone > 0 && remaining > 0 {
+		perBatch := time.Since(j.runStart) / time.Duration(j.runDone)
+		st.ETA = perBatch * time.Duration(remaining)
+	}
+	return st
+}
+
+// EachIPData calls fn for every IP lookup result recorded in the journal,
+// in the order the batches completed. Only the latest result of a batch is
+// reported.
+func (j *Job) EachIPData(fn func(batch int, data *models.IPData) error) error {
+	return j.each(func(rec *record) error {
+		for _, d := range rec.IPData {
+			if err := fn(rec.Batch, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// EachEmailData calls fn for every email validation result recorded in the
+// journal, in the order the batches completed. Only the latest result of a
+// batch is reported.
+func (j *Job) EachEmailData(fn func(batch int, data *models.EmailData) error) error {
+	return j.each(func(rec *record) error {
+		for _, d := range rec.EmailData {
+			if err := fn(rec.Batch, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// each streams the result records of the journal to fn, skipping results
+// that were superseded by a later result for the same batch.
+func (j *Job) each(fn func(rec *record) error) error {
+	f, err := os.Open(j.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// First pass: find the last result record of every batch.
+	last := make(map[int]int)
+	if err := scan(f, func(n int, rec *record) error {
+		if rec.Type == recordResult {
+			last[rec.Batch] = n
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return scan(f, func(n int, rec *record) error {
+		if rec.Type != recordResult || last[rec.Batch] != n {
+			return nil
+		}
+		return fn(rec)
+	})
+}
+
+// replay restores the job state from the journal file, writing the header
+// if the journal is new. A partially written last line, left behind by a
+// crash, is truncated.
+func (j *Job) replay() error {
+	hdr := j.header()
+
+	var valid int64
+	var seenHeader bool
+	r := bufio.NewReader(j.file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// Anything after the last newline is an incomplete record.
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("journal: corrupt record at offset %d: %w", valid, err)
+		}
+		valid += int64(len(line))
+
+		if !seenHeader {
+			if rec.Type != recordHeader || rec.Header == nil || *rec.Header != *hdr {
+				return ErrJournalMismatch
+			}
+			seenHeader = true
+			continue
+		}
+		if rec.Batch < 0 || rec.Batch >= len(j.states) {
+			return fmt.Errorf("journal: record for unknown batch %d", rec.Batch)
+		}
+		j.apply(&rec)
+	}
+
+	if err := j.file.Truncate(valid); err != nil {
+		return err
+	}
+	if _, err := j.file.Seek(valid, io.SeekStart); err != nil {
+		return err
+	}
+	if !seenHeader {
+		return j.append(&record{Type: recordHeader, Header: hdr})
+	}
+	return nil
+}
+
+// apply updates the in-memory state with rec. The caller must hold j.mu
+// unless the job is not shared yet.
+func (j *Job) apply(rec *record) {
+	switch rec.Type {
+	case recordResult:
+		if j.states[rec.Batch] != batchDone {
+			j.credits += int64(len(j.batchItems(rec.Batch)))
+		}
+		j.states[rec.Batch] = batchDone
+		j.excess = j.excess || rec.ExcessChargesApply
+		delete(j.errs, rec.Batch)
+	case recordFailure:
+		if j.states[rec.Batch] != batchDone {
+			j.states[rec.Batch] = batchFailed
+			j.errs[rec.Batch] = rec.Error
+		}
+	}
+}
+
+// append writes rec to the journal and syncs it to disk.
+func (j *Job) append(rec *record) error {
+	rec.Time = time.Now().UTC()
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.file.Write(b); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+// header returns the header identifying the job in its journal.
+func (j *Job) header() *header {
+	h := sha256.New()
+	for _, item := range j.cfg.Items {
+		io.WriteString(h, item)
+		h.Write([]byte{0})
+	}
+	return &header{
+		Kind:      j.cfg.Kind,
+		BatchSize: j.cfg.BatchSize,
+		Items:     len(j.cfg.Items),
+		Digest:    hex.EncodeToString(h.Sum(nil)),
+	}
+}
+
+// scan decodes the complete records of a journal and calls fn with the
+// record number and the record.
+func scan(r io.Reader, fn func(n int, rec *record) error) error {
+	br := bufio.NewReader(r)
+	for n := 0; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return err
+		}
+		if err := fn(n, &rec); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+
+	"github.com/go-openapi/runtime"
+)
+
+// stubService answers IP lookups and fails the batches that contain an
+// address listed in fail.
+type stubService struct {
+	mu      sync.Mutex
+	fail    map[string]bool
+	batches [][]string
+	before  func() // before is called before each IP lookup if not nil.
+}
+
+func (s *stubService) EmailValidationRequestData(params *operations.EmailValidationRequestDataParams, _ ...operations.ClientOption) (*operations.EmailValidationRequestDataOK, error) {
+	payload := &models.EmailLookupResponse{}
+	for _, email := range params.Body.Data {
+		payload.Data = append(payload.Data, &models.EmailData{EmailAddress: email, ValidityScore: 90})
+	}
+	return &operations.EmailValidationRequestDataOK{Payload: payload}, nil
+}
+
+func (s *stubService) IPLookupRequestData(params *operations.IPLookupRequestDataParams, _ ...operations.ClientOption) (*operations.IPLookupRequestDataOK, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.before != nil {
+		s.before()
+	}
+	for _, ip := range params.Body.Data {
+		if s.fail[ip] {
+			return nil, errors.New("service unavailable")
+		}
+	}
+	s.batches = append(s.batches, params.Body.Data)
+
+	payload := &models.IPLookupResponse{}
+	for _, ip := range params.Body.Data {
+		payload.Data = append(payload.Data, &models.IPData{IPAddress: ip, LookupStatus: "success"})
+	}
+	return &operations.IPLookupRequestDataOK{Payload: payload}, nil
+}
+
+func (s *stubService) PromptCheckRequestData(*operations.PromptCheckRequestDataParams, ...operations.ClientOption) (*operations.PromptCheckRequestDataOK, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *stubService) SetTransport(runtime.ClientTransport) {}
+
+func testItems(n int) []string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+	}
+	return items
+}
+
+func TestRunResumesAfterFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.journal")
+	items := testItems(10)
+
+	svc := &stubService{fail: map[string]bool{items[5]: true}}
+	job, err := Open(path, Config{Kind: KindIPLookup, Service: svc, Items: items, BatchSize: 3})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := job.Run(context.Background()); !errors.Is(err, ErrBatchesFailed) {
+		t.Fatalf("Expected ErrBatchesFailed, got %v", err)
+	}
+
+	st := job.Status()
+	if st.TotalBatches != 4 || st.CompletedBatches != 3 || len(st.Failed) != 1 || st.Failed[0].Batch != 1 {
+		t.Errorf("Unexpected status after first run: %+v", st)
+	}
+	if st.CreditsConsumed != 7 {
+		t.Errorf("Expected 7 credits consumed, got %d", st.CreditsConsumed)
+	}
+	if err := job.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash in the middle of writing a record.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"type":"result","batch":1,"ip_d`)
+	f.Close()
+
+	svc = &stubService{}
+	job, err = Open(path, Config{Kind: KindIPLookup, Service: svc, Items: items, BatchSize: 3})
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer job.Close()
+
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Resumed run failed: %v", err)
+	}
+	if len(svc.batches) != 1 || svc.batches[0][0] != items[3] {
+		t.Errorf("Expected only the failed batch to be resubmitted, got %q", svc.batches)
+	}
+
+	st = job.Status()
+	if st.CompletedBatches != 4 || len(st.Failed) != 0 || st.PendingBatches != 0 || st.Progress() != 1 {
+		t.Errorf("Unexpected status after resumed run: %+v", st)
+	}
+	if st.CreditsConsumed != 10 {
+		t.Errorf("Expected 10 credits consumed, got %d", st.CreditsConsumed)
+	}
+
+	seen := make(map[string]bool)
+	err = job.EachIPData(func(batch int, data *models.IPData) error {
+		if seen[data.IPAddress] {
+			t.Errorf("Result for %s reported twice", data.IPAddress)
+		}
+		seen[data.IPAddress] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachIPData failed: %v", err)
+	}
+	if len(seen) != len(items) {
+		t.Errorf("Expected %d results, got %d", len(items), len(seen))
+	}
+}
+
+func TestOpenRejectsDifferentJob(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.journal")
+	job, err := Open(path, Config{Kind: KindEmailValidation, Service: &stubService{}, Items: []string{"a@example.com"}})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	job.Close()
+
+	_, err = Open(path, Config{Kind: KindEmailValidation, Service: &stubService{}, Items: []string{"b@example.com"}})
+	if !errors.Is(err, ErrJournalMismatch) {
+		t.Errorf("Expected ErrJournalMismatch, got %v", err)
+	}
+}
+
+func TestRunStopsOnJournalError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.journal")
+	svc := &stubService{}
+	job, err := Open(path, Config{Kind: KindIPLookup, Service: svc, Items: testItems(50), BatchSize: 10, Concurrency: 1})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	svc.before = func() { job.Close() }
+
+	if err := job.Run(context.Background()); err == nil {
+		t.Fatal("Expected the journal error")
+	}
+	if len(svc.batches) != 1 {
+		t.Errorf("Expected no batches to be submitted after the journal error, got %d", len(svc.batches))
+	}
+}
+
+func TestRunConcurrentEmailValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.journal")
+	items := make([]string, 25)
+	for i := range items {
+		items[i] = fmt.Sprintf("user%d@example.com", i)
+	}
+
+	job, err := Open(path, Config{Kind: KindEmailValidation, Service: &stubService{}, Items: items, BatchSize: 4, Concurrency: 3})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer job.Close()
+
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	var n int
+	job.EachEmailData(func(int, *models.EmailData) error { n++; return nil })
+	if n != len(items) {
+		t.Errorf("Expected %d results, got %d", len(items), n)
+	}
+}
@@ -0,0 +1,39 @@
+package journal
+
+import (
+	"time"
+
+	"cerberius.com/go-client/generated/models"
+)
+
+// recordType identifies a journal record.
+type recordType string
+
+const (
+	recordHeader  recordType = "header"  // recordHeader is the first record and identifies the job.
+	recordSubmit  recordType = "submit"  // recordSubmit marks a batch as sent.
+	recordResult  recordType = "result"  // recordResult holds the response of a batch.
+	recordFailure recordType = "failure" // recordFailure holds the error of a batch.
+)
+
+// record is a single line of the journal file.
+type record struct {
+	Type  recordType `json:"type"`
+	Time  time.Time  `json:"time"`
+	Batch int        `json:"batch"`
+
+	Header *header `json:"header,omitempty"`
+	Error  string  `json:"error,omitempty"`
+
+	IPData             []*models.IPData    `json:"ip_data,omitempty"`
+	EmailData          []*models.EmailData `json:"email_data,omitempty"`
+	ExcessChargesApply bool                `json:"excess_charges_apply,omitempty"`
+}
+
+// header identifies the job a journal belongs to.
+type header struct {
+	Kind      Kind   `json:"kind"`
+	BatchSize int    `json:"batch_size"`
+	Items     int    `json:"items"`
+	Digest    string `json:"digest"` // Digest is the SHA-256 of the NUL-separated items.
+}
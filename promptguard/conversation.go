@@ -0,0 +1,237 @@
+// Package promptguard provides conversation-level prompt injection checking
+// on top of the Cerberius prompt check operation.
+//
+// Injection attempts often arrive in a tool output or an earlier user turn
+// rather than in the latest message, so a Session checks every untrusted
+// message of a conversation and remembers the verdicts it has already
+// obtained, so that re-submitting a growing conversation only pays for the
+// new turns.
+//
+// A Prefilter decides locally, from pattern rules, known jailbreak phrases
+// and length and entropy heuristics, whether a prompt must be checked, can
+// be blocked as malicious or, if enabled, can be skipped as benign. Client wraps an
+// operations client with a Prefilter and returns local verdicts as prompt
+// check results, so that a Checker only pays for the prompts that need it:
+//
+//	checker := promptguard.NewChecker(promptguard.NewClient(promptguard.NewPrefilter(), apiClient.Operations))
+package promptguard
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+)
+
+// Role identifies the author of a conversation message.
+type Role string
+
+// Roles understood by the Checker. Any other role is treated as untrusted.
+const (
+	RoleSystem    Role = "system"    // RoleSystem is an instruction written by the application.
+	RoleAssistant Role = "assistant" // RoleAssistant is a message produced by the model.
+	RoleUser      Role = "user"      // RoleUser is a message typed by the end user.
+	RoleTool      Role = "tool"      // RoleTool is the output of a tool or function call.
+	RoleDocument  Role = "document"  // RoleDocument is retrieved content, such as a RAG chunk.
+)
+
+// ErrEmptyVerdict is returned when the prompt check operation answers
+// without a verdict.
+var ErrEmptyVerdict = errors.New("promptguard: empty verdict")
+
+// DefaultTrustedRoles are the roles that are not sent for checking unless
+// the Checker is configured otherwise.
+var DefaultTrustedRoles = []Role{RoleSystem, RoleAssistant}
+
+// Message is a single role-tagged turn of a conversation.
+type Message struct {
+	Role    Role   // Role is the author of the message.
+	Content string // Content is the text of the message.
+}
+
+// MessageVerdict is the outcome of checking a single message.
+type MessageVerdict struct {
+	Index   int  // Index is the position of the message in the conversation.
+	Role    Role // Role is the role of the message.
+	Checked bool // Checked reports whether the message was considered untrusted and has a verdict.
+	Cached  bool // Cached reports whether the verdict was reused from an earlier call in the session.
+
+	// Verdict is the prompt check result for the message. It is nil when
+	// Checked is false.
+	Verdict *models.PromptGuardData
+}
+
+// ConversationVerdict is the outcome of checking a whole conversation.
+type ConversationVerdict struct {
+	// Messages holds one verdict per input message, in input order.
+	Messages []MessageVerdict
+
+	// Malicious reports whether any checked message was found malicious.
+	Malicious bool
+
+	// ConfidenceScore is the highest confidence among the verdicts that agree
+	// with Malicious, on the same 0 - 100 scale as models.PromptGuardData.
+	ConfidenceScore int64
+
+	// FirstMalicious is the index of the first malicious message, or -1.
+	FirstMalicious int
+}
+
+// Checker checks conversations against the prompt check operation.
+type Checker struct {
+	Service operations.ClientService // Service is the operations client used for prompt checks.
+	Trusted map[Role]bool            // Trusted holds the roles that are never sent for checking.
+}
+
+// NewChecker creates a new Checker that uses svc for prompt checks and
+// trusts DefaultTrustedRoles.
+func NewChecker(svc operations.ClientService) *Checker {
+	trusted := make(map[Role]bool, len(DefaultTrustedRoles))
+	for _, r := range DefaultTrustedRoles {
+		trusted[r] = true
+	}
+	return &Checker{
+		Service: svc,
+		Trusted: trusted,
+	}
+}
+
+// NewSession starts a new conversation session. Verdicts are cached for the
+// lifetime of the session, keyed by message content.
+func (c *Checker) NewSession() *Session {
+	return &Session{
+		checker:  c,
+		verdicts: make(map[[sha256.Size]byte]*models.PromptGuardData),
+		inflight: make(map[[sha256.Size]byte]*pendingCheck),
+	}
+}
+
+// Check checks msgs in a throwaway session. Use NewSession to reuse verdicts
+// across calls.
+func (c *Checker) Check(ctx context.Context, msgs []Message) (*ConversationVerdict, error) {
+	return c.NewSession().Check(ctx, msgs)
+}
+
+// Session checks successive versions of the same conversation and skips
+// messages it has already checked. It is safe for concurrent use.
+type Session struct {
+	checker *Checker
+
+	mu       sync.Mutex
+	verdicts map[[sha256.Size]byte]*models.PromptGuardData
+	inflight map[[sha256.Size]byte]*pendingCheck
+}
+
+// pendingCheck is a prompt check in progress that concurrent checks of the
+// same content wait for.
+type pendingCheck struct {
+	done    chan struct{}
+	verdict *models.PromptGuardData
+	err     error
+}
+
+// Check checks every untrusted, non-empty message in msgs and returns a
+// per-message and an overall verdict. Messages whose content was already
+// checked in this session are not sent again, and concurrent checks of
+// the same content share one request. Failed checks are not cached.
+func (s *Session) Check(ctx context.Context, msgs []Message) (*ConversationVerdict, error) {
+	result := &ConversationVerdict{
+		Messages:       make([]MessageVerdict, len(msgs)),
+		FirstMalicious: -1,
+	}
+
+	for i, msg := range msgs {
+		mv := MessageVerdict{Index: i, Role: msg.Role}
+		if s.checker.Trusted[msg.Role] || msg.Content == "" {
+			result.Messages[i] = mv
+			continue
+		}
+
+		verdict, ok, err := s.verdict(ctx, msg.Content)
+		if err != nil {
+			return nil, fmt.Errorf("promptguard: checking message %d (%s): %w", i, msg.Role, err)
+		}
+
+		mv.Checked = true
+		mv.Cached = ok
+		mv.Verdict = verdict
+		result.Messages[i] = mv
+
+		if verdict.Malicious && result.FirstMalicious < 0 {
+			result.FirstMalicious = i
+		}
+	}
+
+	result.Malicious = result.FirstMalicious >= 0
+	for _, mv := range result.Messages {
+		if mv.Verdict != nil && mv.Verdict.Malicious == result.Malicious && mv.Verdict.ConfidenceScore > result.ConfidenceScore {
+			result.ConfidenceScore = mv.Verdict.ConfidenceScore
+		}
+	}
+	return result, nil
+}
+
+// Reset forgets all cached verdicts of the session.
+func (s *Session) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.verdicts = make(map[[sha256.Size]byte]*models.PromptGuardData)
+}
+
+// verdict returns the verdict for content and whether it was reused from
+// the cache or from a concurrent check. If a concurrent check fails
+// because its caller's context ended, content is checked again.
+func (s *Session) verdict(ctx context.Context, content string) (*models.PromptGuardData, bool, error) {
+	key := sha256.Sum256([]byte(content))
+	for {
+		s.mu.Lock()
+		if verdict, ok := s.verdicts[key]; ok {
+			s.mu.Unlock()
+			return verdict, true, nil
+		}
+		if p, ok := s.inflight[key]; ok {
+			s.mu.Unlock()
+			select {
+			case <-p.done:
+			case <-ctx.Done():
+				return nil, false, ctx.Err()
+			}
+			if (errors.Is(p.err, context.Canceled) || errors.Is(p.err, context.DeadlineExceeded)) && ctx.Err() == nil {
+				continue
+			}
+			return p.verdict, p.err == nil, p.err
+		}
+		p := &pendingCheck{done: make(chan struct{})}
+		s.inflight[key] = p
+		s.mu.Unlock()
+
+		p.verdict, p.err = s.check(ctx, content)
+		s.mu.Lock()
+		delete(s.inflight, key)
+		if p.err == nil {
+			s.verdicts[key] = p.verdict
+		}
+		s.mu.Unlock()
+		close(p.done)
+		return p.verdict, false, p.err
+	}
+}
+
+// check sends a single prompt to the prompt check operation.
+func (s *Session) check(ctx context.Context, content string) (*models.PromptGuardData, error) {
+	params := operations.NewPromptCheckRequestDataParamsWithContext(ctx).
+		WithBody(&models.PromptGuardRequest{Data: &models.Prompt{Prompt: content}})
+
+	resp, err := s.checker.Service.PromptCheckRequestData(params)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Payload == nil || resp.Payload.Data == nil {
+		return nil, ErrEmptyVerdict
+	}
+	return resp.Payload.Data, nil
+}
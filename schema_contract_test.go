@@ -0,0 +1,346 @@
+package goclient_test
+
+// These tests check that the generated models still match
+// cerberus_schema.json: every definition has a model, every property maps to
+// a field with the same JSON name, Go name and type, and sample payloads
+// built from the schema's "example" values survive a round trip through the
+// models.
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"unicode"
+
+	"cerberius.com/go-client/generated/models"
+
+	"github.com/go-openapi/strfmt"
+)
+
+// binaryModel is implemented by every generated model.
+type binaryModel interface {
+	MarshalBinary() ([]byte, error)
+	UnmarshalBinary([]byte) error
+	Validate(strfmt.Registry) error
+}
+
+// modelTypes maps schema definitions to their generated models.
+var modelTypes = map[string]func() binaryModel{
+	"Data":                func() binaryModel { return new(models.Data) },
+	"EmailData":           func() binaryModel { return new(models.EmailData) },
+	"EmailLookupRequest":  func() binaryModel { return new(models.EmailLookupRequest) },
+	"EmailLookupResponse": func() binaryModel { return new(models.EmailLookupResponse) },
+	"IPData":              func() binaryModel { return new(models.IPData) },
+	"IPLookupRequest":     func() binaryModel { return new(models.IPLookupRequest) },
+	"IPLookupResponse":    func() binaryModel { return new(models.IPLookupResponse) },
+	"Prompt":              func() binaryModel { return new(models.Prompt) },
+	"PromptGuardData":     func() binaryModel { return new(models.PromptGuardData) },
+	"PromptGuardRequest":  func() binaryModel { return new(models.PromptGuardRequest) },
+	"PromptGuardResponse": func() binaryModel { return new(models.PromptGuardResponse) },
+	"Response":            func() binaryModel { return new(models.Response) },
+}
+
+// knownMisspelledProperties are property names that do not follow from
+// their x-go-name. The service sends these names, so the models must keep
+// them; any new mismatch fails TestSchemaPropertyNames.
+var knownMisspelledProperties = map[string]bool{
+	"IPData.continet_code":  true,
+	"IPData.continet_name":  true,
+	"IPData.currencysymbol": true,
+	"IPData.is_anonimous":   true,
+	"EmailData.mx_hosts":    true,
+}
+
+// knownWrongTitles are definitions whose title was copied from another
+// definition. Any new mismatch fails TestSchemaTitles.
+var knownWrongTitles = map[string]bool{
+	"EmailData":       true,
+	"PromptGuardData": true,
+}
+
+// schemaProperty is the subset of a Swagger schema used by these tests.
+type schemaProperty struct {
+	Type       string                     `json:"type"`
+	Format     string                     `json:"format"`
+	Ref        string                     `json:"$ref"`
+	Title      string                     `json:"title"`
+	GoName     string                     `json:"x-go-name"`
+	Example    any                        `json:"example"`
+	Items      *schemaProperty            `json:"items"`
+	Properties map[string]*schemaProperty `json:"properties"`
+}
+
+type schemaDocument struct {
+	Definitions map[string]*schemaProperty `json:"definitions"`
+}
+
+func loadSchema(tb testing.TB) *schemaDocument {
+	tb.Helper()
+	b, err := os.ReadFile("cerberus_schema.json")
+	if err != nil {
+		tb.Fatalf("Failed to read schema: %v", err)
+	}
+	var doc schemaDocument
+	if err := json.Unmarshal(b, &doc); err != nil {
+		tb.Fatalf("Failed to parse schema: %v", err)
+	}
+	return &doc
+}
+
+// refName returns the definition name of a "#/definitions/X" reference.
+func refName(ref string) string {
+	return strings.TrimPrefix(ref, "#/definitions/")
+}
+
+func TestSchemaDefinitionsHaveModels(t *testing.T) {
+	doc := loadSchema(t)
+	for name := range doc.Definitions {
+		if _, ok := modelTypes[name]; !ok {
+			t.Errorf("Definition %s has no entry in modelTypes", name)
+		}
+	}
+	for name := range modelTypes {
+		if _, ok := doc.Definitions[name]; !ok {
+			t.Errorf("Model %s has no schema definition", name)
+		}
+	}
+}
+
+func TestSchemaPropertiesMatchModels(t *testing.T) {
+	doc := loadSchema(t)
+	for name, def := range doc.Definitions {
+		newModel, ok := modelTypes[name]
+		if !ok {
+			continue
+		}
+		typ := reflect.TypeOf(newModel()).Elem()
+
+		fields := make(map[string]reflect.StructField)
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			fields[tag] = f
+		}
+
+		for prop, ps := range def.Properties {
+			f, ok := fields[prop]
+			if !ok {
+				t.Errorf("%s.%s: no field with JSON name %q", name, prop, prop)
+				continue
+			}
+			delete(fields, prop)
+			if ps.GoName != "" && f.Name != ps.GoName {
+				t.Errorf("%s.%s: field is %s, schema x-go-name is %s", name, prop, f.Name, ps.GoName)
+			}
+			if err := checkType(ps, f.Type); err != nil {
+				t.Errorf("%s.%s: %v", name, prop, err)
+			}
+		}
+		for tag, f := range fields {
+			t.Errorf("%s.%s: field %s is not in the schema", name, tag, f.Name)
+		}
+	}
+}
+
+// checkType reports whether t is the Go type go-swagger generates for ps.
+func checkType(ps *schemaProperty, t reflect.Type) error {
+	if ps.Ref != "" {
+		if t.Kind() != reflect.Ptr || t.Elem().Name() != refName(ps.Ref) {
+			return fmt.Errorf("expected *%s, got %s", refName(ps.Ref), t)
+		}
+		return nil
+	}
+
+	var want reflect.Kind
+	switch ps.Type {
+	case "string":
+		want = reflect.String
+	case "boolean":
+		want = reflect.Bool
+	case "integer":
+		want = reflect.Int64
+		if ps.Format == "int32" {
+			want = reflect.Int32
+		}
+	case "number":
+		want = reflect.Float64
+		if ps.Format == "float" {
+			want = reflect.Float32
+		}
+	case "array":
+		if t.Kind() != reflect.Slice {
+			return fmt.Errorf("expected a slice, got %s", t)
+		}
+		if ps.Items == nil {
+			return fmt.Errorf("array without items")
+		}
+		return checkType(ps.Items, t.Elem())
+	default:
+		return fmt.Errorf("unsupported schema type %q", ps.Type)
+	}
+	if t.Kind() != want {
+		return fmt.Errorf("expected %s, got %s", want, t)
+	}
+	return nil
+}
+
+// snakeCase converts a Go name such as MXReverseDNS to mx_reverse_dns,
+// treating runs of upper case letters as initialisms.
+func snakeCase(goName string) string {
+	r := []rune(goName)
+	var sb strings.Builder
+	for i, c := range r {
+		if i > 0 && unicode.IsUpper(c) {
+			prevLower := !unicode.IsUpper(r[i-1])
+			nextLower := i+1 < len(r) && unicode.IsLower(r[i+1])
+			if prevLower || nextLower {
+				sb.WriteByte('_')
+			}
+		}
+		sb.WriteRune(unicode.ToLower(c))
+	}
+	return sb.String()
+}
+
+func TestSchemaPropertyNames(t *testing.T) {
+	doc := loadSchema(t)
+	for name, def := range doc.Definitions {
+		for prop, ps := range def.Properties {
+			key := name + "." + prop
+			consistent := ps.GoName == "" || snakeCase(ps.GoName) == prop
+			switch {
+			case !consistent && !knownMisspelledProperties[key]:
+				t.Errorf("%s: property name does not match x-go-name %s (expected %q)", key, ps.GoName, snakeCase(ps.GoName))
+			case consistent && knownMisspelledProperties[key]:
+				t.Errorf("%s: listed in knownMisspelledProperties but is spelled correctly", key)
+			}
+		}
+	}
+}
+
+func TestSchemaTitles(t *testing.T) {
+	doc := loadSchema(t)
+	for name, def := range doc.Definitions {
+		if def.Title == "" {
+			continue
+		}
+		// A title naming another definition was copied by mistake.
+		var wrong bool
+		for other := range doc.Definitions {
+			if other != name && strings.HasPrefix(def.Title, other) {
+				wrong = true
+			}
+		}
+		if strings.HasPrefix(def.Title, "IPInfo") && name != "IPData" {
+			wrong = true
+		}
+		if wrong != knownWrongTitles[name] {
+			t.Errorf("%s: unexpected title %q (known wrong: %v)", name, def.Title, knownWrongTitles[name])
+		}
+	}
+}
+
+// samplePayload builds a JSON value for ps from the schema examples.
+func samplePayload(doc *schemaDocument, ps *schemaProperty) any {
+	switch {
+	case ps.Ref != "":
+		return samplePayload(doc, doc.Definitions[refName(ps.Ref)])
+	case ps.Example != nil:
+		return ps.Example
+	case ps.Type == "array" && ps.Items != nil:
+		return []any{samplePayload(doc, ps.Items)}
+	case ps.Properties != nil:
+		obj := make(map[string]any, len(ps.Properties))
+		for prop, child := range ps.Properties {
+			if v := samplePayload(doc, child); v != nil {
+				obj[prop] = v
+			}
+		}
+		return obj
+	}
+	return nil
+}
+
+// samplePayloads returns a sample JSON payload per schema definition.
+func samplePayloads(tb testing.TB) map[string][]byte {
+	doc := loadSchema(tb)
+	payloads := make(map[string][]byte, len(doc.Definitions))
+	for name, def := range doc.Definitions {
+		b, err := json.Marshal(samplePayload(doc, def))
+		if err != nil {
+			tb.Fatalf("%s: %v", name, err)
+		}
+		payloads[name] = b
+	}
+	return payloads
+}
+
+// dropZero removes the values that omitempty fields do not marshal.
+func dropZero(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, child := range x {
+			switch c := child.(type) {
+			case bool:
+				if !c {
+					continue
+				}
+			case float64:
+				if c == 0 {
+					continue
+				}
+			case string:
+				if c == "" {
+					continue
+				}
+			}
+			out[k] = dropZero(child)
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for i, child := range x {
+			out[i] = dropZero(child)
+		}
+		return out
+	}
+	return v
+}
+
+func TestSchemaExamplesRoundTrip(t *testing.T) {
+	payloads := samplePayloads(t)
+	names := make([]string, 0, len(payloads))
+	for name := range payloads {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			payload := payloads[name]
+			m := modelTypes[name]()
+			if err := m.UnmarshalBinary(payload); err != nil {
+				t.Fatalf("UnmarshalBinary(%s) failed: %v", payload, err)
+			}
+			if err := m.Validate(strfmt.Default); err != nil {
+				t.Errorf("Schema example %s does not validate: %v", payload, err)
+			}
+			out, err := m.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary failed: %v", err)
+			}
+
+			var want, got any
+			json.Unmarshal(payload, &want)
+			json.Unmarshal(out, &got)
+			if !reflect.DeepEqual(dropZero(want), got) {
+				t.Errorf("Round trip mismatch:\n sent: %s\n  got: %s", payload, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package goclient
+
+import (
+	"context"
+	"iter"
+	"sync"
+
+	"cerberius.com/go-client/generated/models"
+)
+
+// SeqOptions configures the streaming lookups of a Client.
+type SeqOptions struct {
+	// BatchSize is the number of items per request, the client's batch
+	// size if zero.
+	BatchSize int
+
+	// Concurrency is the number of batches sent in parallel, the client's
+	// concurrency if zero. At most twice as many batches are held in
+	// memory at once; reading the input pauses until the consumer catches
+	// up.
+	Concurrency int
+
+	// Unordered yields the results of every batch as soon as it completes
+	// instead of in input order.
+	Unordered bool
+
+	// ContinueOnError yields the error of a failed batch and goes on with
+	// the next one. By default the first error ends the sequence.
+	ContinueOnError bool
+}
+
+// LookupIPsSeq looks up the IP addresses of ips in batches and yields the
+// results, or the error of a failed batch, with a nil IPData. Batches are
+// sent with the retries and deadlines of LookupIPs. Breaking out of the
+// loop, or cancelling ctx, stops reading ips and cancels the batches in
+// flight; a cancelled ctx is reported as a final error. The sequence does
+// not end before ips has returned, so an input that may block, such as a
+// channel read with FromChan, must return once ctx is done.
+func (c *Client) LookupIPsSeq(ctx context.Context, ips iter.Seq[string], opts SeqOptions) iter.Seq2[*models.IPData, error] {
+	return seqBatches(ctx, c, ips, opts, c.lookupIPBatch)
+}
+
+// ValidateEmailsSeq validates the email addresses of emails in batches and
+// yields the results like LookupIPsSeq.
+func (c *Client) ValidateEmailsSeq(ctx context.Context, emails iter.Seq[string], opts SeqOptions) iter.Seq2[*models.EmailData, error] {
+	return seqBatches(ctx, c, emails, opts, c.validateEmailBatch)
+}
+
+// FromChan returns a sequence of the values received from ch until it is
+// closed or ctx is done.
+func FromChan[T any](ctx context.Context, ch <-chan T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			select {
+			case v, ok := <-ch:
+				if !ok || !yield(v) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// seqBatches reads items in batches, runs them concurrently and yields the
+// results.
+func seqBatches[T any](parent context.Context, c *Client, items iter.Seq[string], opts SeqOptions, run func(ctx context.Context, batch []string) ([]T, error)) iter.Seq2[T, error] {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = c.batchSize
+	}
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = c.workers
+	}
+
+	type batch struct {
+		index int
+		items []string
+	}
+	type result struct {
+		index   int
+		results []T
+		err     error
+	}
+
+	return func(yield func(T, error) bool) {
+		ctx, cancel := context.WithCancel(parent)
+		defer cancel()
+
+		// window bounds the batches between the reader and the consumer.
+		window := make(chan struct{}, 2*workers)
+		work := make(chan batch)
+		done := make(chan result, 2*workers)
+		readerDone := make(chan struct{})
+
+		go func() {
+			defer close(readerDone)
+			defer close(work)
+			index := 0
+			buf := make([]string, 0, batchSize)
+			send := func() bool {
+				select {
+				case window <- struct{}{}:
+				case <-ctx.Done():
+					return false
+				}
+				select {
+				case work <- batch{index: index, items: buf}:
+				case <-ctx.Done():
+					return false
+				}
+				index++
+				buf = make([]string, 0, batchSize)
+				return true
+			}
+			for item := range items {
+				if ctx.Err() != nil {
+					return
+				}
+				if buf = append(buf, item); len(buf) == batchSize && !send() {
+					return
+				}
+			}
+			if len(buf) > 0 {
+				send()
+			}
+		}()
+
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case b, ok := <-work:
+						if !ok {
+							return
+						}
+						results, err := run(ctx, b.items)
+						done <- result{index: b.index, results: results, err: err}
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		defer func() {
+			// Stop the reader and the workers, and wait for both.
+			cancel()
+			for range done {
+			}
+			<-readerDone
+		}()
+
+		var zero T
+		emit := func(r result) bool {
+			<-window
+			if r.err != nil {
+				return yield(zero, r.err) && opts.ContinueOnError
+			}
+			for _, v := range r.results {
+				if !yield(v, nil) {
+					return false
+				}
+			}
+			return true
+		}
+
+		pending := make(map[int]result)
+		next := 0
+		for r := range done {
+			if opts.Unordered {
+				if !emit(r) {
+					return
+				}
+				continue
+			}
+			pending[r.index] = r
+			for {
+				p, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				if !emit(p) {
+					return
+				}
+			}
+		}
+		if err := parent.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
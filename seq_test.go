@@ -0,0 +1,175 @@
+package goclient_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	goclient "cerberius.com/go-client"
+	"cerberius.com/go-client/fake"
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+)
+
+// countingSeq yields the items and counts how many were read.
+func countingSeq(items []string, read *int32) func(func(string) bool) {
+	return func(yield func(string) bool) {
+		for _, item := range items {
+			atomic.AddInt32(read, 1)
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+func TestLookupIPsSeqOrdered(t *testing.T) {
+	svc := fake.New()
+	svc.IPLookupRequestDataFunc = func(params *operations.IPLookupRequestDataParams, opts ...operations.ClientOption) (*operations.IPLookupRequestDataOK, error) {
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+		return echoIPs(params, opts...)
+	}
+	c, _ := goclient.New(goclient.Config{Service: svc})
+
+	input := ips(500)
+	var got []string
+	for d, err := range c.LookupIPsSeq(context.Background(), slices.Values(input), goclient.SeqOptions{BatchSize: 7, Concurrency: 4}) {
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		got = append(got, d.IPAddress)
+	}
+	if !slices.Equal(got, input) {
+		t.Fatalf("Expected results in input order, got %d results", len(got))
+	}
+	svc.AssertIPLookupRequestDataCalled(t, 72)
+}
+
+func TestLookupIPsSeqUnordered(t *testing.T) {
+	svc := fake.New()
+	svc.IPLookupRequestDataFunc = echoIPs
+	c, _ := goclient.New(goclient.Config{Service: svc})
+
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for _, ip := range ips(50) {
+			ch <- ip
+		}
+	}()
+	var got []string
+	for d, err := range c.LookupIPsSeq(context.Background(), goclient.FromChan(context.Background(), ch), goclient.SeqOptions{BatchSize: 10, Concurrency: 3, Unordered: true}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, d.IPAddress)
+	}
+	slices.Sort(got)
+	want := ips(50)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Fatalf("Expected every result once, got %v", got)
+	}
+}
+
+func TestSeqBreakStopsReading(t *testing.T) {
+	svc := fake.New()
+	svc.IPLookupRequestDataFunc = echoIPs
+	c, _ := goclient.New(goclient.Config{Service: svc})
+
+	var read int32
+	n := 0
+	for _, err := range c.LookupIPsSeq(context.Background(), countingSeq(ips(10000), &read), goclient.SeqOptions{BatchSize: 10, Concurrency: 2}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n++; n == 15 {
+			break
+		}
+	}
+	// Backpressure: at most a few windows of batches are read ahead.
+	r := atomic.LoadInt32(&read)
+	if r > 100 {
+		t.Errorf("Expected reading to stop shortly after the break, read %d items", r)
+	}
+	// The reader has returned with the sequence.
+	time.Sleep(20 * time.Millisecond)
+	if after := atomic.LoadInt32(&read); after != r {
+		t.Errorf("Expected no reads after the loop ended, read %d more items", after-r)
+	}
+}
+
+func TestSeqFromChanStopsOnCancel(t *testing.T) {
+	svc := fake.New()
+	svc.IPLookupRequestDataFunc = echoIPs
+	c, _ := goclient.New(goclient.Config{Service: svc})
+
+	// The channel is never closed.
+	ch := make(chan string, 1)
+	ch <- "8.8.8.8"
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	var lastErr error
+	for _, err := range c.LookupIPsSeq(ctx, goclient.FromChan(ctx, ch), goclient.SeqOptions{BatchSize: 10}) {
+		lastErr = err
+	}
+	if !errors.Is(lastErr, context.Canceled) {
+		t.Errorf("Expected the sequence to end with context.Canceled, got %v", lastErr)
+	}
+}
+
+func TestValidateEmailsSeqErrors(t *testing.T) {
+	svc := fake.New()
+	svc.EmailValidationRequestDataFunc = func(params *operations.EmailValidationRequestDataParams, _ ...operations.ClientOption) (*operations.EmailValidationRequestDataOK, error) {
+		if strings.HasPrefix(params.Body.Data[0], "bad") {
+			return nil, operations.NewEmailValidationRequestDataDefault(422)
+		}
+		payload := &models.EmailLookupResponse{}
+		for _, e := range params.Body.Data {
+			payload.Data = append(payload.Data, &models.EmailData{EmailAddress: e})
+		}
+		return &operations.EmailValidationRequestDataOK{Payload: payload}, nil
+	}
+	c, _ := goclient.New(goclient.Config{Service: svc})
+	input := []string{"a@example.com", "bad@example.com", "c@example.com"}
+
+	collect := func(opts goclient.SeqOptions) (emails []string, errs int) {
+		for d, err := range c.ValidateEmailsSeq(context.Background(), slices.Values(input), opts) {
+			if err != nil {
+				errs++
+				continue
+			}
+			emails = append(emails, d.EmailAddress)
+		}
+		return emails, errs
+	}
+
+	if emails, errs := collect(goclient.SeqOptions{BatchSize: 1}); errs != 1 || !slices.Equal(emails, []string{"a@example.com"}) {
+		t.Errorf("Expected the first error to end the sequence, got %v and %d errors", emails, errs)
+	}
+	if emails, errs := collect(goclient.SeqOptions{BatchSize: 1, ContinueOnError: true}); errs != 1 || len(emails) != 2 {
+		t.Errorf("Expected to continue after the error, got %v and %d errors", emails, errs)
+	}
+}
+
+func TestSeqCancelledContext(t *testing.T) {
+	svc := fake.New()
+	svc.IPLookupRequestDataFunc = echoIPs
+	c, _ := goclient.New(goclient.Config{Service: svc})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var last error
+	for _, err := range c.LookupIPsSeq(ctx, slices.Values(ips(10)), goclient.SeqOptions{}) {
+		last = err
+	}
+	if !errors.Is(last, context.Canceled) {
+		t.Errorf("Expected a final context.Canceled, got %v", last)
+	}
+}
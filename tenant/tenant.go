@@ -0,0 +1,579 @@
+// Package tenant serves many Cerberus accounts from a single process.
+//
+// A Pool lazily creates one HMAC-authenticated API client per tenant, all
+// sharing a single underlying http.RoundTripper and its connections. The
+// tenant of an operation is taken from its context, set with WithTenant:
+//
+//	pool := tenant.NewPool(tenant.Config{Resolver: tenant.StaticResolver(accounts)})
+//	ops := operations.New(pool, strfmt.Default)
+//
+//	ctx := tenant.WithTenant(ctx, "acme")
+//	resp, err := ops.IPLookupRequestData(operations.NewIPLookupRequestDataParamsWithContext(ctx).WithBody(body))
+//
+// Each tenant has its own rate limit and credit budget, and the pool keeps
+// per-tenant metrics. Tenants that have been idle for Config.IdleTimeout
+// are evicted and recreated on their next use.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"cerberius.com/go-client/auth"
+	"cerberius.com/go-client/generated/client"
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/internal/ratelimit"
+
+	"github.com/go-openapi/runtime"
+	httptransport "github.com/go-openapi/runtime/client"
+	"github.com/go-openapi/strfmt"
+)
+
+// DefaultIdleTimeout is the time after which an unused tenant is evicted
+// when Config.IdleTimeout is not set.
+const DefaultIdleTimeout = 10 * time.Minute
+
+// DefaultResolveTimeout is the time a Resolver call may take when
+// Config.ResolveTimeout is not set.
+const DefaultResolveTimeout = 30 * time.Second
+
+var (
+	// ErrNoTenant is returned for operations whose context carries no tenant.
+	ErrNoTenant = errors.New("tenant: no tenant in context")
+	// ErrUnknownTenant is returned by StaticResolver for unknown tenants.
+	ErrUnknownTenant = errors.New("tenant: unknown tenant")
+	// ErrBudgetExceeded is returned for operations that would take a tenant
+	// over its credit budget. The operation is not sent.
+	ErrBudgetExceeded = errors.New("tenant: credit budget exceeded")
+
+	// errEvicted is returned by entry.begin for entries no longer in the
+	// pool.
+	errEvicted = errors.New("tenant: evicted")
+)
+
+type contextKey struct{}
+
+// WithTenant returns a copy of ctx that selects the tenant id.
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant selected by ctx, if any.
+func FromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
+
+// Limits are the per-tenant limits enforced by a Pool.
+type Limits struct {
+	// Rate is the number of operations per second, unlimited if zero.
+	// Operations over the rate wait for their turn, or fail with the
+	// context error if their context is done first.
+	Rate float64
+	// Burst is the number of operations allowed at once, 1 if zero.
+	Burst int
+
+	// Budget is the number of credits that may be used per BudgetPeriod,
+	// unlimited if zero. Every IP address and email address costs one
+	// credit, and every prompt check one.
+	Budget int64
+	// BudgetPeriod is the length of a budget window, which starts with the
+	// first operation of the window. If zero, the budget covers the
+	// lifetime of the Pool.
+	BudgetPeriod time.Duration
+}
+
+// Account holds the credentials and limits of a tenant.
+type Account struct {
+	APIKey    string // APIKey is the tenant's Cerberus API key.
+	APISecret string // APISecret is the tenant's Cerberus API secret.
+	Limits    Limits // Limits are the limits enforced for the tenant.
+}
+
+// Resolver returns the account of a tenant. It is called when a tenant is
+// first used and again after the tenant has been evicted. A single call
+// serves all concurrent operations of the tenant, so its context carries the
+// values of the first operation's context but not its cancellation.
+type Resolver func(ctx context.Context, id string) (Account, error)
+
+// StaticResolver returns a Resolver for a fixed set of accounts. Unknown
+// tenants fail with ErrUnknownTenant.
+func StaticResolver(accounts map[string]Account) Resolver {
+	return func(_ context.Context, id string) (Account, error) {
+		acct, ok := accounts[id]
+		if !ok {
+			return Account{}, fmt.Errorf("%w %q", ErrUnknownTenant, id)
+		}
+		return acct, nil
+	}
+}
+
+// Config configures a Pool.
+type Config struct {
+	Resolver Resolver // Resolver looks up tenant accounts.
+
+	// Transport is the round tripper shared by all tenants,
+	// http.DefaultTransport if nil.
+	Transport http.RoundTripper
+
+	// Host, BasePath and Schemes locate the API, client.DefaultHost,
+	// client.DefaultBasePath and client.DefaultSchemes if empty.
+	Host     string
+	BasePath string
+	Schemes  []string
+
+	// SignBody enables body signing for all tenants. See
+	// auth.HMACAuthTransport.
+	SignBody bool
+
+	// ResolveTimeout bounds every Resolver call, DefaultResolveTimeout if
+	// zero.
+	ResolveTimeout time.Duration
+
+	// IdleTimeout is the time after which an unused tenant is evicted,
+	// DefaultIdleTimeout if zero. Eviction is disabled if negative.
+	IdleTimeout time.Duration
+
+	// OnEvict, if set, is called with the final metrics of every evicted
+	// tenant.
+	OnEvict func(id string, m Metrics)
+
+	// Now returns the current time, time.Now if nil.
+	Now func() time.Time
+}
+
+// Metrics are the counters kept for a tenant.
+type Metrics struct {
+	Requests      int64         // Requests is the number of operations sent.
+	Failures      int64         // Failures is the number of operations that returned an error.
+	Credits       int64         // Credits is the number of credits used by successful operations.
+	Rejected      int64         // Rejected is the number of operations refused by the budget.
+	RateLimitWait time.Duration // RateLimitWait is the total time spent waiting for the rate limit.
+	Latency       time.Duration // Latency is the total time spent in sent operations.
+	LastUsed      time.Time     // LastUsed is the time of the last operation.
+
+	// BudgetRemaining is the number of credits left in the current budget
+	// window, or -1 if the tenant has no budget.
+	BudgetRemaining int64
+}
+
+// Pool is a runtime.ClientTransport that sends every operation with the
+// client of the tenant selected by the operation's context. It is safe for
+// concurrent use.
+type Pool struct {
+	cfg Config
+
+	mu        sync.Mutex
+	tenants   map[string]*entry
+	budgets   map[string]budget // budgets holds the running budget windows of evicted tenants.
+	lastSweep time.Time
+}
+
+// budget is the state of a budget window.
+type budget struct {
+	start  time.Time
+	used   int64
+	period time.Duration
+}
+
+// running reports whether the window is still running at now.
+func (b budget) running(now time.Time) bool {
+	return b.period == 0 || now.Sub(b.start) < b.period
+}
+
+// NewPool creates a new Pool.
+func NewPool(cfg Config) *Pool {
+	if cfg.Transport == nil {
+		cfg.Transport = http.DefaultTransport
+	}
+	if cfg.Host == "" {
+		cfg.Host = client.DefaultHost
+	}
+	if cfg.BasePath == "" {
+		cfg.BasePath = client.DefaultBasePath
+	}
+	if len(cfg.Schemes) == 0 {
+		cfg.Schemes = client.DefaultSchemes
+	}
+	if cfg.ResolveTimeout == 0 {
+		cfg.ResolveTimeout = DefaultResolveTimeout
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = DefaultIdleTimeout
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Pool{cfg: cfg, tenants: make(map[string]*entry), budgets: make(map[string]budget)}
+}
+
+// Submit implements the runtime.ClientTransport interface. Operations
+// without a tenant in their context fail with ErrNoTenant.
+func (p *Pool) Submit(op *runtime.ClientOperation) (interface{}, error) {
+	ctx := op.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	id, ok := FromContext(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+	e, err := p.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return e.Submit(op)
+}
+
+// Client returns the API client of the tenant selected by ctx. Operations
+// sent with it are subject to the tenant's limits regardless of their own
+// context. The client stays usable after the tenant is evicted, but its
+// operations are then no longer counted in the pool.
+func (p *Pool) Client(ctx context.Context) (*client.CerberusAPI, error) {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+	e, err := p.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return e.api, nil
+}
+
+// Metrics returns the metrics of a tenant, or false if the tenant is not in
+// the pool.
+func (p *Pool) Metrics(id string) (Metrics, bool) {
+	p.mu.Lock()
+	e, ok := p.tenants[id]
+	p.mu.Unlock()
+	if !ok || !e.isReady() {
+		return Metrics{}, false
+	}
+	return e.snapshot(p.cfg.Now()), true
+}
+
+// Tenants returns the sorted IDs of the tenants in the pool.
+func (p *Pool) Tenants() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := make([]string, 0, len(p.tenants))
+	for id, e := range p.tenants {
+		if e.isReady() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// EvictIdle evicts the tenants that have been idle for longer than the
+// idle timeout and returns their IDs. It is also called periodically by
+// Submit. A tenant is never evicted while it has operations in flight. The
+// running budget window of an evicted tenant is kept and restored when the
+// tenant is recreated, so eviction never resets a budget.
+func (p *Pool) EvictIdle() []string {
+	p.mu.Lock()
+	evicted := p.sweepLocked(p.cfg.Now())
+	p.mu.Unlock()
+	return p.notifyEvicted(evicted)
+}
+
+// get returns the ready entry of tenant id, creating it if necessary.
+func (p *Pool) get(ctx context.Context, id string) (*entry, error) {
+	now := p.cfg.Now()
+
+	p.mu.Lock()
+	var evicted []*entry
+	if p.cfg.IdleTimeout > 0 && now.Sub(p.lastSweep) >= p.cfg.IdleTimeout/2 {
+		evicted = p.sweepLocked(now)
+	}
+	e, ok := p.tenants[id]
+	if !ok {
+		e = &entry{id: id, pool: p, ready: make(chan struct{}), lastUsed: now}
+		p.tenants[id] = e
+	} else {
+		// Keep the entry from being evicted before the caller uses it.
+		e.mu.Lock()
+		e.lastUsed = now
+		e.mu.Unlock()
+	}
+	p.mu.Unlock()
+	p.notifyEvicted(evicted)
+
+	if !ok {
+		// The account is resolved for every waiter, so a caller giving up
+		// must not cancel it.
+		go func() {
+			rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), p.cfg.ResolveTimeout)
+			defer cancel()
+			e.init(rctx)
+			if e.err != nil {
+				p.mu.Lock()
+				if p.tenants[id] == e {
+					delete(p.tenants, id)
+				}
+				p.mu.Unlock()
+			}
+			close(e.ready)
+		}()
+	}
+
+	select {
+	case <-e.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e, nil
+}
+
+// sweepLocked removes the idle entries from the pool and returns them.
+// p.mu must be held.
+func (p *Pool) sweepLocked(now time.Time) []*entry {
+	p.lastSweep = now
+	if p.cfg.IdleTimeout < 0 {
+		return nil
+	}
+	for id, b := range p.budgets {
+		if !b.running(now) {
+			delete(p.budgets, id)
+		}
+	}
+	var evicted []*entry
+	for id, e := range p.tenants {
+		if e.isReady() && e.idle(now, p.cfg.IdleTimeout) {
+			delete(p.tenants, id)
+			if b, ok := e.runningBudget(now); ok {
+				p.budgets[id] = b
+			}
+			evicted = append(evicted, e)
+		}
+	}
+	return evicted
+}
+
+// notifyEvicted calls OnEvict for the evicted entries and returns their
+// sorted IDs.
+func (p *Pool) notifyEvicted(evicted []*entry) []string {
+	ids := make([]string, len(evicted))
+	for i, e := range evicted {
+		ids[i] = e.id
+		if p.cfg.OnEvict != nil {
+			p.cfg.OnEvict(e.id, e.snapshot(p.cfg.Now()))
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// entry is the client and state of a single tenant.
+type entry struct {
+	id    string
+	pool  *Pool
+	ready chan struct{} // ready is closed once init has run.
+	err   error         // err is the init error.
+
+	limits  Limits
+	limiter *ratelimit.Limiter
+	next    runtime.ClientTransport
+	api     *client.CerberusAPI
+
+	mu          sync.Mutex
+	metrics     Metrics
+	lastUsed    time.Time
+	inflight    int
+	windowStart time.Time
+	used        int64 // used is the number of credits used or reserved in the window.
+}
+
+// init resolves the tenant's account and creates its client, restoring the
+// budget window the tenant had when it was evicted.
+func (e *entry) init(ctx context.Context) {
+	cfg := &e.pool.cfg
+	acct, err := cfg.Resolver(ctx, e.id)
+	if err != nil {
+		e.err = err
+		return
+	}
+
+	signer := auth.NewHMACAuthTransport(acct.APIKey, acct.APISecret, cfg.Transport)
+	signer.SignBody = cfg.SignBody
+	e.next = httptransport.NewWithClient(cfg.Host, cfg.BasePath, cfg.Schemes, &http.Client{Transport: signer})
+	e.limits = acct.Limits
+	e.limiter = ratelimit.New(acct.Limits.Rate, acct.Limits.Burst)
+	e.api = client.New(e, strfmt.Default)
+
+	e.pool.mu.Lock()
+	b, ok := e.pool.budgets[e.id]
+	delete(e.pool.budgets, e.id)
+	e.pool.mu.Unlock()
+	if ok {
+		e.mu.Lock()
+		e.windowStart, e.used = b.start, b.used
+		e.mu.Unlock()
+	}
+}
+
+// isReady reports whether init has run successfully.
+func (e *entry) isReady() bool {
+	select {
+	case <-e.ready:
+		return e.err == nil
+	default:
+		return false
+	}
+}
+
+// Submit implements the runtime.ClientTransport interface.
+func (e *entry) Submit(op *runtime.ClientOperation) (interface{}, error) {
+	ctx := op.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	credits := operationCredits(op.Params)
+	window, err := e.begin(credits)
+	if errors.Is(err, errEvicted) {
+		// The budget window moved to the pool when the entry was evicted,
+		// so the operation is charged to the tenant's current entry.
+		cur, err := e.pool.get(ctx, e.id)
+		if err != nil {
+			return nil, err
+		}
+		return cur.Submit(op)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	waited, err := e.limiter.Wait(ctx)
+	if err != nil {
+		e.end(credits, window, waited, 0, false, err)
+		return nil, err
+	}
+
+	start := time.Now()
+	result, err := e.next.Submit(op)
+	e.end(credits, window, waited, time.Since(start), true, err)
+	return result, err
+}
+
+// begin reserves credits from the budget and marks an operation in flight,
+// which keeps the entry from being evicted until end. It returns the start
+// of the budget window the credits were reserved in, or errEvicted if the
+// entry is no longer in the pool.
+func (e *entry) begin(credits int64) (time.Time, error) {
+	now := e.pool.cfg.Now()
+	e.pool.mu.Lock()
+	defer e.pool.mu.Unlock()
+	if e.pool.tenants[e.id] != e {
+		return time.Time{}, errEvicted
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastUsed = now
+	e.metrics.LastUsed = now
+	if e.limits.Budget > 0 {
+		e.rollWindow(now)
+		if e.used+credits > e.limits.Budget {
+			e.metrics.Rejected++
+			return time.Time{}, fmt.Errorf("%w: tenant %q has %d of %d credits left", ErrBudgetExceeded, e.id, e.limits.Budget-e.used, e.limits.Budget)
+		}
+		e.used += credits
+	}
+	e.inflight++
+	return e.windowStart, nil
+}
+
+// end records the outcome of an operation started with begin in window.
+// Credits reserved for failed operations are returned to the budget if its
+// window has not ended since.
+func (e *entry) end(credits int64, window time.Time, waited, latency time.Duration, sent bool, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.inflight--
+	e.lastUsed = e.pool.cfg.Now()
+	e.metrics.RateLimitWait += waited
+	if sent {
+		e.metrics.Requests++
+		e.metrics.Latency += latency
+	}
+	if err != nil {
+		e.metrics.Failures++
+		if e.limits.Budget > 0 && e.windowStart.Equal(window) {
+			e.used -= credits
+		}
+		return
+	}
+	e.metrics.Credits += credits
+}
+
+// rollWindow starts a new budget window if the current one has ended.
+// e.mu must be held.
+func (e *entry) rollWindow(now time.Time) {
+	if e.windowStart.IsZero() || (e.limits.BudgetPeriod > 0 && now.Sub(e.windowStart) >= e.limits.BudgetPeriod) {
+		e.windowStart = now
+		e.used = 0
+	}
+}
+
+// idle reports whether the entry may be evicted.
+func (e *entry) idle(now time.Time, timeout time.Duration) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.inflight == 0 && now.Sub(e.lastUsed) >= timeout
+}
+
+// runningBudget returns the entry's budget window, or false if it has no
+// budget or its window has ended.
+func (e *entry) runningBudget(now time.Time) (budget, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.limits.Budget <= 0 || e.windowStart.IsZero() {
+		return budget{}, false
+	}
+	b := budget{start: e.windowStart, used: e.used, period: e.limits.BudgetPeriod}
+	return b, b.running(now)
+}
+
+// snapshot returns a copy of the entry's metrics.
+func (e *entry) snapshot(now time.Time) Metrics {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	m := e.metrics
+	m.BudgetRemaining = -1
+	if e.limits.Budget > 0 {
+		m.BudgetRemaining = e.limits.Budget
+		if !e.windowStart.IsZero() && (e.limits.BudgetPeriod == 0 || now.Sub(e.windowStart) < e.limits.BudgetPeriod) {
+			m.BudgetRemaining -= e.used
+		}
+	}
+	return m
+}
+
+// operationCredits returns the number of credits an operation uses.
+func operationCredits(params runtime.ClientRequestWriter) int64 {
+	switch p := params.(type) {
+	case *operations.IPLookupRequestDataParams:
+		if p.Body != nil {
+			return int64(len(p.Body.Data))
+		}
+	case *operations.EmailValidationRequestDataParams:
+		if p.Body != nil {
+			return int64(len(p.Body.Data))
+		}
+	case *operations.PromptCheckRequestDataParams:
+		return 1
+	}
+	return 0
+}
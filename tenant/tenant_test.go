@@ -0,0 +1,312 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"cerberius.com/go-client/auth"
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+
+	"github.com/go-openapi/strfmt"
+)
+
+var testAccounts = map[string]Account{
+	"acme":     {APIKey: "acmeKey", APISecret: "acmeSecret"},
+	"globex":   {APIKey: "globexKey", APISecret: "globexSecret", Limits: Limits{Budget: 3}},
+	"initech":  {APIKey: "initechKey", APISecret: "initechSecret", Limits: Limits{Rate: 1}},
+	"umbrella": {APIKey: "umbrellaKey", APISecret: "umbrellaSecret", Limits: Limits{Budget: 3, BudgetPeriod: time.Minute}},
+}
+
+// newTestPool returns a pool talking to a server that verifies the tenant
+// signatures and echoes the looked up IPs, and the API keys it received.
+func newTestPool(t *testing.T, cfg Config) (*Pool, func() []string) {
+	t.Helper()
+	secrets := map[string]string{}
+	for _, acct := range testAccounts {
+		secrets[acct.APIKey] = acct.APISecret
+	}
+
+	var mu sync.Mutex
+	var keys []string
+	srv := httptest.NewServer(auth.NewVerifier(secrets).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("X-API-Key"))
+		mu.Unlock()
+
+		var req models.IPLookupRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		resp := models.IPLookupResponse{}
+		for _, ip := range req.Data {
+			resp.Data = append(resp.Data, &models.IPData{IPAddress: ip})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})))
+	t.Cleanup(srv.Close)
+
+	u, _ := url.Parse(srv.URL)
+	cfg.Resolver = StaticResolver(testAccounts)
+	cfg.Host = u.Host
+	cfg.Schemes = []string{"http"}
+	return NewPool(cfg), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), keys...)
+	}
+}
+
+func lookup(ctx context.Context, ops operations.ClientService, ips ...string) error {
+	params := operations.NewIPLookupRequestDataParamsWithContext(ctx).WithBody(&models.IPLookupRequest{Data: ips})
+	_, err := ops.IPLookupRequestData(params)
+	return err
+}
+
+func TestPoolRoutesByContext(t *testing.T) {
+	pool, keys := newTestPool(t, Config{})
+	ops := operations.New(pool, strfmt.Default)
+	ctx := context.Background()
+
+	if err := lookup(WithTenant(ctx, "acme"), ops, "8.8.8.8"); err != nil {
+		t.Fatalf("acme lookup failed: %v", err)
+	}
+	if err := lookup(WithTenant(ctx, "globex"), ops, "1.1.1.1", "9.9.9.9"); err != nil {
+		t.Fatalf("globex lookup failed: %v", err)
+	}
+	if err := lookup(WithTenant(ctx, "acme"), ops, "8.8.4.4"); err != nil {
+		t.Fatalf("acme lookup failed: %v", err)
+	}
+
+	want := []string{"acmeKey", "globexKey", "acmeKey"}
+	if got := keys(); len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("Expected keys %v, got %v", want, got)
+	}
+	if got := pool.Tenants(); len(got) != 2 || got[0] != "acme" || got[1] != "globex" {
+		t.Errorf("Unexpected tenants %v", got)
+	}
+	m, _ := pool.Metrics("acme")
+	if m.Requests != 2 || m.Credits != 2 || m.Failures != 0 || m.BudgetRemaining != -1 {
+		t.Errorf("Unexpected acme metrics %+v", m)
+	}
+
+	if err := lookup(ctx, ops, "8.8.8.8"); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("Expected ErrNoTenant, got %v", err)
+	}
+	if err := lookup(WithTenant(ctx, "hooli"), ops, "8.8.8.8"); !errors.Is(err, ErrUnknownTenant) {
+		t.Errorf("Expected ErrUnknownTenant, got %v", err)
+	}
+}
+
+func TestPoolBudget(t *testing.T) {
+	pool, keys := newTestPool(t, Config{})
+	ctx := WithTenant(context.Background(), "globex")
+	api, err := pool.Client(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lookup(ctx, api.Operations, "1.1.1.1", "9.9.9.9"); err != nil {
+		t.Fatalf("Lookup within budget failed: %v", err)
+	}
+	if err := lookup(ctx, api.Operations, "8.8.8.8", "8.8.4.4"); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Expected ErrBudgetExceeded, got %v", err)
+	}
+	if err := lookup(ctx, api.Operations, "8.8.8.8"); err != nil {
+		t.Fatalf("Lookup of the last credit failed: %v", err)
+	}
+	if n := len(keys()); n != 2 {
+		t.Errorf("Expected 2 requests to reach the server, got %d", n)
+	}
+	m, _ := pool.Metrics("globex")
+	if m.Rejected != 1 || m.Credits != 3 || m.BudgetRemaining != 0 {
+		t.Errorf("Unexpected metrics %+v", m)
+	}
+}
+
+func TestPoolRateLimit(t *testing.T) {
+	pool, _ := newTestPool(t, Config{})
+	ops := operations.New(pool, strfmt.Default)
+	ctx := WithTenant(context.Background(), "initech")
+
+	if err := lookup(ctx, ops, "8.8.8.8"); err != nil {
+		t.Fatal(err)
+	}
+	// The next token is a second away, past the deadline.
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := lookup(short, ops, "8.8.8.8"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the rate limit to exceed the deadline, got %v", err)
+	}
+	// Other tenants are not affected.
+	if err := lookup(WithTenant(short, "acme"), ops, "8.8.8.8"); err != nil {
+		t.Errorf("acme lookup failed: %v", err)
+	}
+}
+
+func TestPoolEvictsIdleTenants(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var evicted []string
+	pool, _ := newTestPool(t, Config{
+		IdleTimeout: time.Minute,
+		Now:         func() time.Time { return now },
+		OnEvict:     func(id string, m Metrics) { evicted = append(evicted, id) },
+	})
+	ops := operations.New(pool, strfmt.Default)
+	ctx := context.Background()
+
+	lookup(WithTenant(ctx, "acme"), ops, "8.8.8.8")
+	lookup(WithTenant(ctx, "globex"), ops, "8.8.8.8")
+
+	now = now.Add(2 * time.Minute)
+	if got := pool.EvictIdle(); len(got) != 2 || got[0] != "acme" || got[1] != "globex" {
+		t.Fatalf("Expected acme and globex to be evicted, got %v", got)
+	}
+	if len(evicted) != 2 {
+		t.Errorf("Expected OnEvict for acme and globex, got %v", evicted)
+	}
+	if _, ok := pool.Metrics("acme"); ok {
+		t.Error("Expected acme metrics to be gone")
+	}
+
+	// An evicted tenant is recreated on its next use.
+	if err := lookup(WithTenant(ctx, "acme"), ops, "8.8.8.8"); err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := pool.Metrics("acme"); !ok || m.Requests != 1 {
+		t.Errorf("Expected fresh acme metrics, got %+v", m)
+	}
+}
+
+func TestPoolEvictionKeepsBudget(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	pool, _ := newTestPool(t, Config{IdleTimeout: time.Minute, Now: func() time.Time { return now }})
+	ops := operations.New(pool, strfmt.Default)
+	ctx := WithTenant(context.Background(), "globex")
+
+	if err := lookup(ctx, ops, "1.1.1.1", "9.9.9.9"); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Minute)
+	if got := pool.EvictIdle(); len(got) != 1 {
+		t.Fatalf("Expected globex to be evicted, got %v", got)
+	}
+
+	// The recreated tenant continues its lifetime budget.
+	if err := lookup(ctx, ops, "8.8.8.8", "8.8.4.4"); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Expected ErrBudgetExceeded, got %v", err)
+	}
+	if m, _ := pool.Metrics("globex"); m.BudgetRemaining != 1 {
+		t.Errorf("Expected 1 credit left, got %+v", m)
+	}
+}
+
+func TestPoolEvictedClientChargesCurrentEntry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	pool, _ := newTestPool(t, Config{IdleTimeout: time.Minute, Now: func() time.Time { return now }})
+	ctx := WithTenant(context.Background(), "globex")
+	api, err := pool.Client(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lookup(ctx, api.Operations, "1.1.1.1", "9.9.9.9"); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Minute)
+	if got := pool.EvictIdle(); len(got) != 1 {
+		t.Fatalf("Expected globex to be evicted, got %v", got)
+	}
+
+	// The client of the evicted entry spends the budget of the tenant, not
+	// of the evicted entry.
+	if err := lookup(ctx, api.Operations, "8.8.8.8"); err != nil {
+		t.Fatal(err)
+	}
+	if err := lookup(ctx, operations.New(pool, strfmt.Default), "8.8.4.4"); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Expected ErrBudgetExceeded, got %v", err)
+	}
+	if m, _ := pool.Metrics("globex"); m.BudgetRemaining != 0 || m.Credits != 1 {
+		t.Errorf("Unexpected metrics %+v", m)
+	}
+}
+
+func TestEntryRefundsOnlyItsWindow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	pool, _ := newTestPool(t, Config{Now: func() time.Time { return now }})
+	e, err := pool.get(context.Background(), "umbrella")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := e.begin(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Minute)
+	cur, err := e.begin(3)
+	if err != nil {
+		t.Fatalf("Expected a new window, got %v", err)
+	}
+
+	// The failure of the operation of the ended window refunds nothing.
+	e.end(2, old, 0, 0, true, errors.New("failed"))
+	if _, err := e.begin(1); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected ErrBudgetExceeded, got %v", err)
+	}
+	e.end(3, cur, 0, 0, true, errors.New("failed"))
+	if m, _ := pool.Metrics("umbrella"); m.BudgetRemaining != 3 {
+		t.Errorf("Expected the refund of the current window, got %+v", m)
+	}
+}
+
+func TestPoolResolveIgnoresCallerCancellation(t *testing.T) {
+	release := make(chan struct{})
+	var calls int
+	var mu sync.Mutex
+	pool := NewPool(Config{Resolver: func(ctx context.Context, id string) (Account, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		select {
+		case <-release:
+			return testAccounts["acme"], nil
+		case <-ctx.Done():
+			return Account{}, ctx.Err()
+		}
+	}})
+
+	first, cancel := context.WithCancel(WithTenant(context.Background(), "acme"))
+	errc := make(chan error, 1)
+	go func() {
+		_, err := pool.Client(first)
+		errc <- err
+	}()
+	second := make(chan error, 1)
+	go func() {
+		// Give the first caller time to start the resolve.
+		time.Sleep(20 * time.Millisecond)
+		_, err := pool.Client(WithTenant(context.Background(), "acme"))
+		second <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the first caller to give up, got %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if err := <-second; err != nil {
+		t.Errorf("Expected the second caller to get the tenant, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 1 {
+		t.Errorf("Expected a single resolve, got %d", calls)
+	}
+}
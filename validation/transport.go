@@ -0,0 +1,66 @@
+package validation
+
+import (
+	"github.com/go-openapi/runtime"
+	"github.com/go-openapi/strfmt"
+)
+
+// Options selects what a Transport validates.
+type Options struct {
+	// Requests enables validation of request bodies. Invalid requests are
+	// not sent and fail with an *Error.
+	Requests bool
+
+	// Responses enables validation of successful response payloads.
+	Responses bool
+
+	// OnInvalidResponse, if set, is called with the violations of an invalid
+	// response, which is then returned to the caller as usual. If nil, an
+	// invalid response fails the call with an *Error.
+	OnInvalidResponse func(*Error)
+
+	// Limits are the service limits checked, the defaults if zero.
+	Limits Limits
+
+	// Formats is the format registry used for validation, strfmt.Default if nil.
+	Formats strfmt.Registry
+}
+
+// Transport is a runtime.ClientTransport that validates requests and
+// responses of the wrapped transport.
+type Transport struct {
+	next runtime.ClientTransport
+	opts Options
+}
+
+// NewTransport creates a new Transport that validates the operations
+// submitted to next according to opts.
+func NewTransport(next runtime.ClientTransport, opts Options) *Transport {
+	if opts.Formats == nil {
+		opts.Formats = strfmt.Default
+	}
+	return &Transport{next: next, opts: opts}
+}
+
+// Submit implements the runtime.ClientTransport interface.
+func (t *Transport) Submit(op *runtime.ClientOperation) (interface{}, error) {
+	if t.opts.Requests {
+		if fields := validateParams(op.Params, t.opts.Formats, t.opts.Limits); len(fields) > 0 {
+			return nil, &Error{Operation: op.ID, Fields: fields}
+		}
+	}
+
+	result, err := t.next.Submit(op)
+	if err != nil || !t.opts.Responses {
+		return result, err
+	}
+
+	if fields := validateResult(result, t.opts.Formats, t.opts.Limits); len(fields) > 0 {
+		verr := &Error{Operation: op.ID, Response: true, Fields: fields}
+		if t.opts.OnInvalidResponse == nil {
+			return nil, verr
+		}
+		t.opts.OnInvalidResponse(verr)
+	}
+	return result, nil
+}
@@ -0,0 +1,283 @@
+// Package validation validates Cerberius requests before they are sent and,
+// optionally, responses after they are received.
+//
+// Request validation checks for non-empty batches, email and IP address
+// syntax and the service limits on batch size and prompt length. Response
+// validation range-checks scores and flags malformed coordinates. The limits
+// are not part of the API schema and can be adjusted with Limits.
+//
+// Validation is enabled per client by wrapping its runtime transport:
+//
+//	rt := httptransport.NewWithClient(client.DefaultHost, client.DefaultBasePath, client.DefaultSchemes, httpClient)
+//	apiClient := client.New(validation.NewTransport(rt, validation.Options{Requests: true, Responses: true}), strfmt.Default)
+//
+// Violations are reported as an *Error holding one FieldError per field.
+package validation
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+
+	"github.com/go-openapi/strfmt"
+)
+
+// Default limits of the Cerberius API.
+const (
+	DefaultMaxBatchSize    = 100
+	DefaultMaxPromptLength = 10000
+	DefaultMaxScore        = 100
+)
+
+// Limits are the service limits requests and responses are checked against.
+// A zero field selects its default, a negative field disables the check.
+type Limits struct {
+	// MaxBatchSize is the maximum number of addresses in a request,
+	// DefaultMaxBatchSize if zero.
+	MaxBatchSize int
+
+	// MaxPromptLength is the maximum length of a prompt in characters,
+	// DefaultMaxPromptLength if zero.
+	MaxPromptLength int
+
+	// MaxScore is the upper bound of the validity, fraud and confidence
+	// scores in responses, DefaultMaxScore if zero. Scores are never
+	// negative.
+	MaxScore int64
+}
+
+// orDefault returns v, or def if v is zero.
+func orDefault[T int | int64](v, def T) T {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+func (l Limits) maxBatchSize() int    { return orDefault(l.MaxBatchSize, DefaultMaxBatchSize) }
+func (l Limits) maxPromptLength() int { return orDefault(l.MaxPromptLength, DefaultMaxPromptLength) }
+func (l Limits) maxScore() int64      { return orDefault(l.MaxScore, DefaultMaxScore) }
+
+// FieldError is a single validation violation.
+type FieldError struct {
+	Field   string // Field is the JSON path of the field, e.g. "data.3" or "data.0.latitude".
+	Value   any    // Value is the offending value, if known.
+	Message string // Message describes the violation.
+}
+
+func (e FieldError) String() string {
+	return e.Field + ": " + e.Message
+}
+
+// Error reports the violations found in a request or response.
+type Error struct {
+	Operation string       // Operation is the operation ID, e.g. "ipLookupRequestData".
+	Response  bool         // Response reports whether the violations are in the response.
+	Fields    []FieldError // Fields lists the violations.
+}
+
+func (e *Error) Error() string {
+	kind := "request"
+	if e.Response {
+		kind = "response"
+	}
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.String()
+	}
+	return fmt.Sprintf("validation: invalid %s %s: %s", e.Operation, kind, strings.Join(msgs, "; "))
+}
+
+// fieldErrors collects violations, ignoring repeated reports for a field.
+type fieldErrors struct {
+	list []FieldError
+	seen map[string]bool
+}
+
+func (fe *fieldErrors) add(field string, value any, msg string) {
+	if fe.seen == nil {
+		fe.seen = make(map[string]bool)
+	}
+	if fe.seen[field] {
+		return
+	}
+	fe.seen[field] = true
+	fe.list = append(fe.list, FieldError{Field: field, Value: value, Message: msg})
+}
+
+// checkBatch adds a violation if data is empty or larger than the batch
+// size limit.
+func checkBatch(fe *fieldErrors, data []string, limits Limits) {
+	if len(data) == 0 {
+		fe.add("data", nil, "data in body is required")
+		return
+	}
+	if max := limits.maxBatchSize(); max > 0 && len(data) > max {
+		fe.add("data", len(data), fmt.Sprintf("must contain at most %d items", max))
+	}
+}
+
+// checkScore adds a violation if score is outside [0, limits.MaxScore].
+func checkScore(fe *fieldErrors, field string, score int64, limits Limits) {
+	if score < 0 {
+		fe.add(field, score, "must not be negative")
+		return
+	}
+	if max := limits.maxScore(); max > 0 && score > max {
+		fe.add(field, score, fmt.Sprintf("must be at most %d", max))
+	}
+}
+
+// ValidateEmailLookupRequest returns the violations in an email validation
+// request body.
+func ValidateEmailLookupRequest(body *models.EmailLookupRequest, formats strfmt.Registry, limits Limits) []FieldError {
+	var fe fieldErrors
+	if body == nil {
+		fe.add("data", nil, "data in body is required")
+		return fe.list
+	}
+	checkBatch(&fe, body.Data, limits)
+	for i, email := range body.Data {
+		if !formats.Validates("email", email) {
+			fe.add("data."+strconv.Itoa(i), email, "must be a valid email address")
+		}
+	}
+	return fe.list
+}
+
+// ValidateIPLookupRequest returns the violations in an IP lookup request
+// body.
+func ValidateIPLookupRequest(body *models.IPLookupRequest, formats strfmt.Registry, limits Limits) []FieldError {
+	var fe fieldErrors
+	if body == nil {
+		fe.add("data", nil, "data in body is required")
+		return fe.list
+	}
+	checkBatch(&fe, body.Data, limits)
+	for i, ip := range body.Data {
+		// The address is checked as it is sent; the API cannot use zones.
+		if addr, err := netip.ParseAddr(ip); err != nil || addr.Zone() != "" {
+			fe.add("data."+strconv.Itoa(i), ip, "must be a valid IPv4 or IPv6 address without spaces or zone")
+		}
+	}
+	return fe.list
+}
+
+// ValidatePromptGuardRequest returns the violations in a prompt check
+// request body.
+func ValidatePromptGuardRequest(body *models.PromptGuardRequest, formats strfmt.Registry, limits Limits) []FieldError {
+	var fe fieldErrors
+	if body == nil || body.Data == nil || strings.TrimSpace(body.Data.Prompt) == "" {
+		fe.add("data.prompt", nil, "data.prompt in body is required")
+		return fe.list
+	}
+	if max := limits.maxPromptLength(); max > 0 && utf8.RuneCountInString(body.Data.Prompt) > max {
+		fe.add("data.prompt", nil, fmt.Sprintf("must be at most %d characters long", max))
+	}
+	return fe.list
+}
+
+// ValidateEmailLookupResponse returns the violations in an email validation
+// response payload.
+func ValidateEmailLookupResponse(payload *models.EmailLookupResponse, formats strfmt.Registry, limits Limits) []FieldError {
+	var fe fieldErrors
+	if payload == nil {
+		return nil
+	}
+	for i, ed := range payload.Data {
+		if ed != nil {
+			checkScore(&fe, "data."+strconv.Itoa(i)+".validity_score", ed.ValidityScore, limits)
+		}
+	}
+	return fe.list
+}
+
+// ValidateIPLookupResponse returns the violations in an IP lookup response
+// payload, including malformed or out of range coordinates.
+func ValidateIPLookupResponse(payload *models.IPLookupResponse, formats strfmt.Registry, limits Limits) []FieldError {
+	var fe fieldErrors
+	if payload == nil {
+		return nil
+	}
+	for i, ip := range payload.Data {
+		if ip == nil {
+			continue
+		}
+		prefix := "data." + strconv.Itoa(i) + "."
+		if ip.FraudScore != "" {
+			// The score is a decimal integer in a string.
+			n, err := strconv.ParseInt(ip.FraudScore, 10, 64)
+			if err != nil || strconv.FormatInt(n, 10) != ip.FraudScore {
+				fe.add(prefix+"fraud_score", ip.FraudScore, "must be a decimal integer")
+			} else {
+				checkScore(&fe, prefix+"fraud_score", n, limits)
+			}
+		}
+		checkCoordinate(&fe, prefix+"latitude", ip.Latitude, 90)
+		checkCoordinate(&fe, prefix+"longitude", ip.Longitude, 180)
+		if (ip.Latitude == "") != (ip.Longitude == "") {
+			fe.add(prefix+"latitude", ip.Latitude, "latitude and longitude must both be set or both be empty")
+		}
+	}
+	return fe.list
+}
+
+// checkCoordinate adds a violation unless value is empty or a decimal
+// number within ±limit.
+func checkCoordinate(fe *fieldErrors, field, value string, limit float64) {
+	if value == "" {
+		return
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		fe.add(field, value, "must be a decimal number")
+		return
+	}
+	if f < -limit || f > limit {
+		fe.add(field, value, fmt.Sprintf("must be between -%g and %g", limit, limit))
+	}
+}
+
+// ValidatePromptGuardResponse returns the violations in a prompt check
+// response payload.
+func ValidatePromptGuardResponse(payload *models.PromptGuardResponse, formats strfmt.Registry, limits Limits) []FieldError {
+	var fe fieldErrors
+	if payload == nil || payload.Data == nil {
+		return nil
+	}
+	checkScore(&fe, "data.confidence_score", payload.Data.ConfidenceScore, limits)
+	return fe.list
+}
+
+// validateParams returns the violations in the request body of params, or
+// nil for parameters of an unknown operation.
+func validateParams(params any, formats strfmt.Registry, limits Limits) []FieldError {
+	switch p := params.(type) {
+	case *operations.EmailValidationRequestDataParams:
+		return ValidateEmailLookupRequest(p.Body, formats, limits)
+	case *operations.IPLookupRequestDataParams:
+		return ValidateIPLookupRequest(p.Body, formats, limits)
+	case *operations.PromptCheckRequestDataParams:
+		return ValidatePromptGuardRequest(p.Body, formats, limits)
+	}
+	return nil
+}
+
+// validateResult returns the violations in a successful result, or nil for
+// results of an unknown operation.
+func validateResult(result any, formats strfmt.Registry, limits Limits) []FieldError {
+	switch r := result.(type) {
+	case *operations.EmailValidationRequestDataOK:
+		return ValidateEmailLookupResponse(r.Payload, formats, limits)
+	case *operations.IPLookupRequestDataOK:
+		return ValidateIPLookupResponse(r.Payload, formats, limits)
+	case *operations.PromptCheckRequestDataOK:
+		return ValidatePromptGuardResponse(r.Payload, formats, limits)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"cerberius.com/go-client/generated/client/operations"
+	"cerberius.com/go-client/generated/models"
+
+	"github.com/go-openapi/runtime"
+	"github.com/go-openapi/strfmt"
+)
+
+// stubTransport returns result for every submitted operation.
+type stubTransport struct {
+	result    interface{}
+	submitted int
+}
+
+func (s *stubTransport) Submit(*runtime.ClientOperation) (interface{}, error) {
+	s.submitted++
+	return s.result, nil
+}
+
+func fieldNames(fields []FieldError) []string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Field
+	}
+	return names
+}
+
+func TestRequestValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields []FieldError
+		want   []string
+	}{
+		{"EmptyEmails", ValidateEmailLookupRequest(&models.EmailLookupRequest{Data: []string{}}, strfmt.Default, Limits{}), []string{"data"}},
+		{"NilEmails", ValidateEmailLookupRequest(&models.EmailLookupRequest{}, strfmt.Default, Limits{}), []string{"data"}},
+		{"BadEmails", ValidateEmailLookupRequest(&models.EmailLookupRequest{Data: []string{"user@example.com", "invalid-email", "also bad"}}, strfmt.Default, Limits{}), []string{"data.1", "data.2"}},
+		{"TooManyIPs", ValidateIPLookupRequest(&models.IPLookupRequest{Data: make101IPs()}, strfmt.Default, Limits{}), []string{"data"}},
+		{"BadIPs", ValidateIPLookupRequest(&models.IPLookupRequest{Data: []string{"8.8.8.8", "::1", "999.1.1.1", " 1.2.3.4 ", "fe80::1%eth0"}}, strfmt.Default, Limits{}), []string{"data.2", "data.3", "data.4"}},
+		{"EmptyPrompt", ValidatePromptGuardRequest(&models.PromptGuardRequest{Data: &models.Prompt{Prompt: " "}}, strfmt.Default, Limits{}), []string{"data.prompt"}},
+		{"LongPrompt", ValidatePromptGuardRequest(&models.PromptGuardRequest{Data: &models.Prompt{Prompt: strings.Repeat("a", 10001)}}, strfmt.Default, Limits{}), []string{"data.prompt"}},
+		{"CustomBatchLimit", ValidateIPLookupRequest(&models.IPLookupRequest{Data: []string{"8.8.8.8", "1.1.1.1"}}, strfmt.Default, Limits{MaxBatchSize: 1}), []string{"data"}},
+		{"BatchLimitDisabled", ValidateIPLookupRequest(&models.IPLookupRequest{Data: make101IPs()}, strfmt.Default, Limits{MaxBatchSize: -1}), []string{}},
+		{"CustomPromptLimit", ValidatePromptGuardRequest(&models.PromptGuardRequest{Data: &models.Prompt{Prompt: "Hello"}}, strfmt.Default, Limits{MaxPromptLength: 4}), []string{"data.prompt"}},
+		{"ValidPrompt", ValidatePromptGuardRequest(&models.PromptGuardRequest{Data: &models.Prompt{Prompt: "Hello"}}, strfmt.Default, Limits{}), []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fieldNames(tt.fields)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected violations %v, got %v", tt.want, tt.fields)
+			}
+		})
+	}
+}
+
+func make101IPs() []string {
+	ips := make([]string, 101)
+	for i := range ips {
+		ips[i] = fmt.Sprintf("10.0.0.%d", i)
+	}
+	return ips
+}
+
+func TestTransportRejectsInvalidRequest(t *testing.T) {
+	stub := &stubTransport{}
+	ops := operations.New(NewTransport(stub, Options{Requests: true}), strfmt.Default)
+
+	params := operations.NewIPLookupRequestDataParams().WithBody(&models.IPLookupRequest{Data: []string{"not-an-ip"}})
+	_, err := ops.IPLookupRequestData(params)
+
+	var verr *Error
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected *Error, got %v", err)
+	}
+	if verr.Operation != "ipLookupRequestData" || verr.Response || len(verr.Fields) != 1 || verr.Fields[0].Field != "data.0" {
+		t.Errorf("Unexpected error %+v", verr)
+	}
+	if stub.submitted != 0 {
+		t.Error("Invalid request was submitted")
+	}
+}
+
+func TestTransportValidatesResponses(t *testing.T) {
+	result := &operations.IPLookupRequestDataOK{Payload: &models.IPLookupResponse{Data: []*models.IPData{
+		{IPAddress: "8.8.8.8", FraudScore: "30", Latitude: "37.773972", Longitude: "-122.431297"},
+		{IPAddress: "1.1.1.1", FraudScore: "130", Latitude: "91", Longitude: "east"},
+	}}}
+	params := operations.NewIPLookupRequestDataParams().WithBody(&models.IPLookupRequest{Data: []string{"8.8.8.8", "1.1.1.1"}})
+
+	// Strict: invalid responses fail the call.
+	ops := operations.New(NewTransport(&stubTransport{result: result}, Options{Responses: true}), strfmt.Default)
+	_, err := ops.IPLookupRequestData(params)
+	var verr *Error
+	if !errors.As(err, &verr) || !verr.Response {
+		t.Fatalf("Expected response *Error, got %v", err)
+	}
+	want := "data.1.fraud_score,data.1.latitude,data.1.longitude"
+	if got := strings.Join(fieldNames(verr.Fields), ","); got != want {
+		t.Errorf("Expected violations %s, got %s", want, got)
+	}
+
+	// Reporting: the response is returned and the violations are reported.
+	var reported *Error
+	ops = operations.New(NewTransport(&stubTransport{result: result}, Options{
+		Responses:         true,
+		OnInvalidResponse: func(e *Error) { reported = e },
+	}), strfmt.Default)
+	resp, err := ops.IPLookupRequestData(params)
+	if err != nil || resp != result {
+		t.Fatalf("Expected the response to be returned, got %v, %v", resp, err)
+	}
+	if reported == nil || len(reported.Fields) != 3 {
+		t.Errorf("Expected 3 reported violations, got %+v", reported)
+	}
+
+	// Disabled: nothing is checked.
+	ops = operations.New(NewTransport(&stubTransport{result: result}, Options{}), strfmt.Default)
+	if _, err := ops.IPLookupRequestData(params); err != nil {
+		t.Errorf("Expected no validation, got %v", err)
+	}
+}
+
+func TestValidateScores(t *testing.T) {
+	fields := ValidateEmailLookupResponse(&models.EmailLookupResponse{Data: []*models.EmailData{{ValidityScore: 90}, {ValidityScore: 101}}}, strfmt.Default, Limits{})
+	if got := strings.Join(fieldNames(fields), ","); got != "data.1.validity_score" {
+		t.Errorf("Unexpected email violations %v", fields)
+	}
+
+	fields = ValidatePromptGuardResponse(&models.PromptGuardResponse{Data: &models.PromptGuardData{ConfidenceScore: -1}}, strfmt.Default, Limits{})
+	if got := strings.Join(fieldNames(fields), ","); got != "data.confidence_score" {
+		t.Errorf("Unexpected prompt violations %v", fields)
+	}
+
+	fields = ValidateIPLookupResponse(&models.IPLookupResponse{Data: []*models.IPData{{FraudScore: "08"}, {FraudScore: "100"}, {FraudScore: "100"}}}, strfmt.Default, Limits{})
+	if got := strings.Join(fieldNames(fields), ","); got != "data.0.fraud_score" {
+		t.Errorf("Unexpected IP violations %v", fields)
+	}
+	fields = ValidateIPLookupResponse(&models.IPLookupResponse{Data: []*models.IPData{{FraudScore: "100"}}}, strfmt.Default, Limits{MaxScore: 10})
+	if got := strings.Join(fieldNames(fields), ","); got != "data.0.fraud_score" {
+		t.Errorf("Unexpected IP violations with custom limit %v", fields)
+	}
+}
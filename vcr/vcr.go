@@ -0,0 +1,362 @@
+// Package vcr provides a recording and replaying http.RoundTripper for
+// deterministic tests against real Cerberius responses.
+//
+// A Recorder is placed below auth.HMACAuthTransport, so that it sees the
+// signed requests:
+//
+//	rec, err := vcr.New("testdata/ip-lookup.json", vcr.ModeReplay, http.DefaultTransport)
+//	authTransport := auth.NewHMACAuthTransport(apiKey, apiSecret, rec)
+//
+// In ModeRecord every request is sent to the service and the interaction is
+// written to a cassette file, with the authentication headers and any
+// configured secrets scrubbed. In ModeReplay no request leaves the process:
+// interactions are served from the cassette and a request without a match
+// fails with an *UnmatchedError. ModeNewEpisodes replays what it can and
+// records only the misses.
+//
+// Requests are matched on method, URL path and body. JSON bodies are
+// normalized before matching, so key order and whitespace do not matter.
+package vcr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Mode selects how a Recorder handles requests.
+type Mode int
+
+const (
+	// ModeReplay serves every request from the cassette and fails on
+	// requests that were not recorded.
+	ModeReplay Mode = iota
+	// ModeRecord sends every request and records it, replacing the cassette.
+	ModeRecord
+	// ModeNewEpisodes serves recorded requests from the cassette and sends
+	// and records the others.
+	ModeNewEpisodes
+)
+
+// Redacted replaces scrubbed header values and secrets in cassettes.
+const Redacted = "[REDACTED]"
+
+// DefaultScrubHeaders are the headers whose values are never written to a
+// cassette.
+var DefaultScrubHeaders = []string{"X-API-Key", "X-Timestamp", "X-Signature", "Authorization", "Cookie", "Set-Cookie"}
+
+// ErrUnmatched is matched by errors.Is for every *UnmatchedError.
+var ErrUnmatched = errors.New("vcr: no recorded interaction matches request")
+
+// UnmatchedError is returned in ModeReplay for a request that has no
+// recorded interaction.
+type UnmatchedError struct {
+	Method string
+	Path   string
+	Body   string
+}
+
+func (e *UnmatchedError) Error() string {
+	return fmt.Sprintf("vcr: no recorded interaction matches %s %s with body %q", e.Method, e.Path, e.Body)
+}
+
+// Is reports whether target is ErrUnmatched.
+func (e *UnmatchedError) Is(target error) bool {
+	return target == ErrUnmatched
+}
+
+// Recorder is an http.RoundTripper that records and replays interactions
+// using a cassette file. It is safe for concurrent use.
+type Recorder struct {
+	Mode      Mode              // Mode selects between replaying and recording.
+	Path      string            // Path is the cassette file.
+	Transport http.RoundTripper // Transport sends the requests that are recorded.
+
+	// ScrubHeaders are the request and response headers whose values are
+	// replaced with Redacted in the cassette.
+	ScrubHeaders []string
+
+	// Secrets are strings, such as the API key and secret, that are replaced
+	// with Redacted wherever they appear in recorded headers or bodies.
+	Secrets []string
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// New creates a Recorder for the cassette at path. In ModeReplay and
+// ModeNewEpisodes the cassette is loaded; it must exist in ModeReplay. In
+// ModeRecord the cassette is replaced by the first recorded interaction.
+// If next is nil, http.DefaultTransport is used for recording.
+func New(path string, mode Mode, next http.RoundTripper) (*Recorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	r := &Recorder{
+		Mode:         mode,
+		Path:         path,
+		Transport:    next,
+		ScrubHeaders: DefaultScrubHeaders,
+		cassette:     &Cassette{Version: CassetteVersion},
+	}
+
+	if mode != ModeRecord {
+		c, err := LoadCassette(path)
+		switch {
+		case err == nil:
+			r.cassette = c
+		case mode == ModeNewEpisodes && errors.Is(err, os.ErrNotExist):
+			// Start a new cassette.
+		default:
+			return nil, err
+		}
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	// Secrets are scrubbed before matching, since the cassette only holds
+	// scrubbed bodies.
+	key := matchKey(req.Method, req.URL.Path, []byte(r.scrubBody(body)))
+
+	if r.Mode != ModeRecord {
+		if in := r.match(key); in != nil {
+			return in.Response.toHTTP(req), nil
+		}
+		if r.Mode == ModeReplay {
+			return nil, &UnmatchedError{Method: req.Method, Path: req.URL.Path, Body: r.scrubBody(body)}
+		}
+	}
+
+	resp, err := r.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	in := &Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			Path:   req.URL.Path,
+			Header: r.scrubHeader(req.Header),
+			Body:   r.scrubBody(body),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.scrubHeader(resp.Header),
+			Body:       r.scrubBody(respBody),
+		},
+		key: key,
+	}
+	if err := r.record(in); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// match returns the first unused interaction matching key, or the first
+// used one if all matches were replayed already.
+func (r *Recorder) match(key string) *Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var fallback *Interaction
+	for i, in := range r.cassette.Interactions {
+		if in.matchKey() != key {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return in
+		}
+		if fallback == nil {
+			fallback = in
+		}
+	}
+	return fallback
+}
+
+// record appends in to the cassette and saves it.
+func (r *Recorder) record(in *Interaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.used = append(r.used, true)
+	return r.cassette.Save(r.Path)
+}
+
+// scrubHeader returns a copy of h with scrubbed headers and secrets redacted.
+func (r *Recorder) scrubHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range r.ScrubHeaders {
+		if _, ok := out[http.CanonicalHeaderKey(name)]; ok {
+			out.Set(name, Redacted)
+		}
+	}
+	for name, values := range out {
+		for i, v := range values {
+			values[i] = r.scrubString(v)
+		}
+		out[name] = values
+	}
+	return out
+}
+
+// scrubBody returns b with secrets redacted.
+func (r *Recorder) scrubBody(b []byte) string {
+	return r.scrubString(string(b))
+}
+
+func (r *Recorder) scrubString(s string) string {
+	for _, secret := range r.Secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, Redacted)
+		}
+	}
+	return s
+}
+
+// readBody reads and closes the request body. It returns the body and a
+// clone of req that carries it again, so that it can be sent on without
+// modifying the caller's request.
+func readBody(req *http.Request) (*http.Request, []byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil, nil
+	}
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	clone := req.Clone(req.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(b))
+	clone.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	return clone, b, nil
+}
+
+// matchKey builds the key requests are matched on.
+func matchKey(method, path string, body []byte) string {
+	return method + " " + path + "\n" + normalizeBody(body)
+}
+
+// normalizeBody returns JSON bodies re-encoded with sorted keys and no
+// insignificant whitespace, and other bodies unchanged.
+func normalizeBody(body []byte) string {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return string(body)
+	}
+	return string(b)
+}
+
+// CassetteVersion is the cassette file format version written by this
+// package.
+const CassetteVersion = 1
+
+// Cassette is the on-disk list of recorded interactions.
+type Cassette struct {
+	Version      int            `json:"version"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// LoadCassette reads the cassette at path.
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("vcr: invalid cassette %s: %w", path, err)
+	}
+	if c.Version != CassetteVersion {
+		return nil, fmt.Errorf("vcr: unsupported cassette version %d in %s", c.Version, path)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path, creating parent directories as needed.
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+
+	key string // key caches the match key of Request.
+}
+
+func (in *Interaction) matchKey() string {
+	if in.key == "" {
+		in.key = matchKey(in.Request.Method, in.Request.Path, []byte(in.Request.Body))
+	}
+	return in.key
+}
+
+// RecordedRequest is the scrubbed request of an interaction.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the scrubbed response of an interaction.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// toHTTP builds an *http.Response for req from the recorded response.
+func (rr *RecordedResponse) toHTTP(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rr.StatusCode, http.StatusText(rr.StatusCode)),
+		StatusCode:    rr.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rr.Header.Clone(),
+		Body:          io.NopCloser(strings.NewReader(rr.Body)),
+		ContentLength: int64(len(rr.Body)),
+		Request:       req,
+	}
+}
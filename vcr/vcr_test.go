@@ -0,0 +1,134 @@
+package vcr
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cerberius.com/go-client/auth"
+)
+
+func newServer(t *testing.T, hits *int) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*hits++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[{"ip_address":"8.8.8.8","isp":"Google"}],"echo":` + string(body) + `}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func post(t *testing.T, rt http.RoundTripper, url, body string) (*http.Response, error) {
+	t.Helper()
+	req, err := http.NewRequest("POST", url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rt.RoundTrip(req)
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	var hits int
+	srv := newServer(t, &hits)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	rec, err := New(path, ModeRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Secrets = []string{"testSecret"}
+	signer := auth.NewHMACAuthTransport("testKey", "testSecret", rec)
+
+	resp, err := post(t, signer, srv.URL+"/api/ip-lookup", `{"data": ["8.8.8.8"], "note": "testSecret"}`)
+	if err != nil {
+		t.Fatalf("Recording failed: %v", err)
+	}
+	resp.Body.Close()
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Cassette not written: %v", err)
+	}
+	for _, leaked := range []string{"testKey", "testSecret"} {
+		if strings.Contains(string(raw), leaked) {
+			t.Errorf("Cassette contains %q:\n%s", leaked, raw)
+		}
+	}
+
+	// Replay with the server gone and a differently formatted body.
+	srv.Close()
+	replay, err := New(path, ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay.Secrets = []string{"testSecret"}
+	signer = auth.NewHMACAuthTransport("otherKey", "otherSecret", replay)
+	resp, err = post(t, signer, srv.URL+"/api/ip-lookup", `{"note":"testSecret","data":["8.8.8.8"]}`)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"isp":"Google"`) {
+		t.Errorf("Unexpected replayed response %d: %s", resp.StatusCode, body)
+	}
+	if hits != 1 {
+		t.Errorf("Expected 1 request to reach the server, got %d", hits)
+	}
+
+	// The request is sent to the recorder directly to check that the
+	// caller's request is left alone.
+	req, err := http.NewRequest("POST", srv.URL+"/api/ip-lookup", strings.NewReader(`{"data":["1.1.1.1"],"note":"testSecret"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqBody := req.Body
+	_, err = replay.RoundTrip(req)
+	var unmatched *UnmatchedError
+	if !errors.As(err, &unmatched) || !errors.Is(err, ErrUnmatched) {
+		t.Fatalf("Expected *UnmatchedError, got %v", err)
+	}
+	if unmatched.Path != "/api/ip-lookup" {
+		t.Errorf("Unexpected unmatched path %q", unmatched.Path)
+	}
+	if strings.Contains(unmatched.Body, "testSecret") || !strings.Contains(unmatched.Body, Redacted) {
+		t.Errorf("Unmatched body is not scrubbed: %s", unmatched.Body)
+	}
+	if req.Body != reqBody {
+		t.Error("RoundTrip replaced the body of the caller's request")
+	}
+}
+
+func TestNewEpisodesRecordsOnlyMisses(t *testing.T) {
+	var hits int
+	srv := newServer(t, &hits)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	rec, err := New(path, ModeNewEpisodes, nil)
+	if err != nil {
+		t.Fatalf("New without cassette failed: %v", err)
+	}
+	for _, body := range []string{`{"data":["8.8.8.8"]}`, `{"data":["8.8.8.8"]}`, `{"data":["1.1.1.1"]}`} {
+		resp, err := post(t, rec, srv.URL+"/api/ip-lookup", body)
+		if err != nil {
+			t.Fatalf("RoundTrip failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if hits != 2 {
+		t.Errorf("Expected 2 requests to reach the server, got %d", hits)
+	}
+
+	c, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 2 {
+		t.Errorf("Expected 2 recorded interactions, got %d", len(c.Interactions))
+	}
+}